@@ -1,9 +1,14 @@
 package ndp
 
 import (
+	"bufio"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
 	"net/netip"
+	"strconv"
+	"strings"
 )
 
 // An Addr is an IPv6 unicast address.
@@ -83,3 +88,50 @@ func findAddr(addrs []net.Addr, addr Addr, zone string, match func(ip netip.Addr
 	// No matching address on this interface.
 	return netip.Addr{}, fmt.Errorf("ndp: address %q not found on interface %q", addr, zone)
 }
+
+// Linux IPv6 address flags which indicate an address cannot be used, from
+// include/uapi/linux/if_addr.h.
+const (
+	ifaFlagDADFailed = 0x08
+	ifaFlagTentative = 0x40
+)
+
+// parseIfInet6 parses the contents of the Linux /proc/net/if_inet6 file and
+// returns the addresses of the interface with index which are tentative or
+// have failed duplicate address detection. Each line contains an address,
+// interface index, prefix length, scope, flags, and interface name.
+func parseIfInet6(r io.Reader, index int) (map[netip.Addr]bool, error) {
+	addrs := make(map[netip.Addr]bool)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("ndp: malformed if_inet6 line: %q", s.Text())
+		}
+
+		b, err := hex.DecodeString(fields[0])
+		if err != nil || len(b) != 16 {
+			return nil, fmt.Errorf("ndp: malformed if_inet6 address: %q", fields[0])
+		}
+
+		idx, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ndp: malformed if_inet6 index: %q", fields[1])
+		}
+
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ndp: malformed if_inet6 flags: %q", fields[4])
+		}
+
+		if int(idx) == index && flags&(ifaFlagDADFailed|ifaFlagTentative) != 0 {
+			addrs[netip.AddrFrom16([16]byte(b))] = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package ndp
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+)
+
+// unusableAddrs returns the IPv6 addresses of ifi which are tentative or have
+// failed duplicate address detection, as reported by procfs.
+func unusableAddrs(ifi *net.Interface) (map[netip.Addr]bool, error) {
+	f, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// IPv6 is disabled or procfs is not mounted; assume all
+			// addresses are usable.
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseIfInet6(f, ifi.Index)
+}
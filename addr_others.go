@@ -0,0 +1,12 @@
+//go:build !linux
+
+package ndp
+
+import (
+	"net"
+	"net/netip"
+)
+
+// unusableAddrs is not implemented on this platform, so all addresses are
+// assumed to be usable.
+func unusableAddrs(_ *net.Interface) (map[netip.Addr]bool, error) { return nil, nil }
@@ -3,6 +3,7 @@ package ndp
 import (
 	"net"
 	"net/netip"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -126,3 +127,30 @@ func mustIPv6(s string) net.IP {
 
 	return ip
 }
+
+func Test_parseIfInet6(t *testing.T) {
+	const ifInet6 = `fe80000000000000000000fffe000001 04 40 20 80     eth0
+fe80000000000000000000fffe000002 04 40 20 c0     eth0
+fd000000000000000000000000000002 04 40 00 88     eth0
+00000000000000000000000000000001 01 80 10 c0       lo
+`
+
+	got, err := parseIfInet6(strings.NewReader(ifInet6), 4)
+	if err != nil {
+		t.Fatalf("failed to parse if_inet6: %v", err)
+	}
+
+	// Only the tentative and DAD failed addresses of eth0 are unusable.
+	want := map[netip.Addr]bool{
+		netip.MustParseAddr("fe80::ff:fe00:2"): true,
+		netip.MustParseAddr("fd00::2"):         true,
+	}
+
+	if diff := cmp.Diff(want, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected addresses (-want +got):\n%s", diff)
+	}
+
+	if _, err := parseIfInet6(strings.NewReader("fe80 04\n"), 4); err == nil {
+		t.Fatal("expected an error for malformed line, but none occurred")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package ndp
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToDevice returns a net.ListenConfig control function which binds a
+// socket to ifi with SO_BINDTODEVICE.
+func bindToDevice(ifi *net.Interface) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(_, _ string, c syscall.RawConn) error {
+		var serr error
+		if err := c.Control(func(fd uintptr) {
+			serr = syscall.BindToDevice(int(fd), ifi.Name)
+		}); err != nil {
+			return err
+		}
+
+		return serr
+	}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package ndp
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// bindToDevice is not implemented on this platform.
+func bindToDevice(_ *net.Interface) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, errors.New("ndp: binding to a device is not supported on this platform")
+}
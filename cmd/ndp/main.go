@@ -21,6 +21,8 @@ func main() {
 		ifiFlag    = flag.String("i", "", "network interface to use for NDP communication (default: automatic)")
 		addrFlag   = flag.String("a", string(ndp.LinkLocal), "address to use for NDP communication (unspecified, linklocal, uniquelocal, global, or a literal IPv6 address)")
 		targetFlag = flag.String("t", "", "IPv6 target address for neighbor solicitation NDP messages")
+		nameFlag   = flag.Bool("r", false, "resolve IPv6 addresses to host names using reverse DNS and hosts file lookups")
+		ouiFlag    = flag.String("oui", "", "path to an IEEE oui.txt or Wireshark manuf file used to display link-layer address vendors")
 	)
 
 	flag.Usage = func() {
@@ -46,6 +48,14 @@ func main() {
 	}
 	defer c.Close()
 
+	var oui *ndp.OUIDatabase
+	if *ouiFlag != "" {
+		oui, err = parseOUIDatabase(*ouiFlag)
+		if err != nil {
+			ll.Fatalf("failed to load OUI database: %v", err)
+		}
+	}
+
 	var target netip.Addr
 	if t := *targetFlag; t != "" {
 		target, err = netip.ParseAddr(t)
@@ -76,7 +86,7 @@ func main() {
 	ll.Printf("interface: %s, link-layer address: %s, IPv6 address: %s",
 		ifi.Name, mac, ip)
 
-	if err := ndpcmd.Run(ctx, c, ifi, flag.Arg(0), target); err != nil {
+	if err := ndpcmd.Run(ctx, c, ifi, flag.Arg(0), target, *nameFlag, oui); err != nil {
 		// Context cancel means a signal was sent, so no need to log an error.
 		if err == context.Canceled {
 			os.Exit(1)
@@ -86,6 +96,17 @@ func main() {
 	}
 }
 
+// parseOUIDatabase parses an OUI database from the file at path.
+func parseOUIDatabase(path string) (*ndp.OUIDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ndp.ParseOUIDatabase(f)
+}
+
 // findInterface attempts to find the specified interface.  If name is empty,
 // it attempts to find a usable, up and ready, network interface.
 func findInterface(name string) (*net.Interface, error) {
@@ -156,7 +177,15 @@ Examples:
 
   Send neighbor solicitations on the default interface until a neighbor advertisement is received.
 
-    $ ndp -t fe80::1 ns`
+    $ ndp -t fe80::1 ns
+
+  Listen for incoming NDP messages, annotating addresses with host names where possible.
+
+    $ ndp -r
+
+  Listen for incoming NDP messages, annotating link-layer addresses with vendor names.
+
+    $ ndp -oui /usr/share/ieee-data/oui.txt`
 
 func panicf(format string, a ...any) {
 	panic(fmt.Sprintf(format, a...))
@@ -1,14 +1,19 @@
 package ndp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"net/netip"
 	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"golang.org/x/net/icmp"
+	"golang.org/x/net/bpf"
 	"golang.org/x/net/ipv6"
 )
 
@@ -17,16 +22,85 @@ const HopLimit = 255
 
 // A Conn is a Neighbor Discovery Protocol connection.
 type Conn struct {
-	pc *ipv6.PacketConn
+	pc PacketConn
+
+	// sc is the underlying socket of a Conn created by Listen.
+	sc syscall.Conn
 	cm *ipv6.ControlMessage
 
 	ifi  *net.Interface
 	addr netip.Addr
 
+	// icmpErrors enables the reception of ICMPv6 errors in ReadFrom.
+	icmpErrors atomic.Bool
+
+	// malformed enables the reception of unparseable messages in ReadFrom.
+	malformed atomic.Bool
+
+	// groups counts the joins of each multicast group by the Conn.
+	groupsMu sync.Mutex
+	groups   map[netip.Addr]int
+
+	// hopLimitCheck enables the hop limit check in ReadFrom.
+	hopLimitCheck atomic.Bool
+
+	// cmPolicy is the ControlMessagePolicy applied to writes which do not
+	// specify a control message.
+	cmPolicy atomic.Int32
+
+	// tclass is the traffic class applied to writes which do not specify a
+	// control message.
+	tclass atomic.Int32
+
+	// parser parses Messages in ReadFrom. A nil parser applies the default
+	// policies of ParseMessage.
+	parser atomic.Pointer[Parser]
+
+	// tap mirrors packets to a pcapng writer if not nil.
+	tap atomic.Pointer[tap]
+
+	// dedup suppresses duplicate packets in ReadFrom, if enabled.
+	dedup dedup
+
+	// sendFaults and receiveFaults inject faults into packets if not nil.
+	sendFaults, receiveFaults atomic.Pointer[faulter]
+
 	// icmpTest disables the self-filtering mechanism in ReadFrom.
 	icmpTest bool
 }
 
+// A ListenConfig contains options for creating a Conn. The zero value is
+// valid and creates a Conn in the same way as Listen.
+type ListenConfig struct {
+	// TentativeTimeout specifies how long to wait for a tentative address to
+	// become usable when no other address of the requested type is usable,
+	// such as on an interface which was just brought up. If zero, Listen does
+	// not wait.
+	TentativeTimeout time.Duration
+
+	// HopLimit specifies the IPv6 hop limit of outgoing unicast and multicast
+	// messages, and of the default control message used by WriteTo. If zero,
+	// HopLimit (255) is used, as required by RFC 4861; other values are only
+	// useful for testing how peers handle non-compliant messages.
+	HopLimit int
+
+	// ICMPFilter, if not nil, is applied to the connection before it is
+	// returned, so that no unwanted ICMPv6 messages are queued between
+	// creating the connection and calling SetICMPFilter. If nil, Listen
+	// applies DefaultICMPFilter on platforms which support ICMPv6 filters,
+	// and NewConn leaves the filter of the socket unchanged.
+	ICMPFilter *ipv6.ICMPFilter
+
+	// BindToDevice binds the socket created by Listen to the network
+	// interface with SO_BINDTODEVICE, rather than only scoping its address to
+	// the interface, so that packets received on other interfaces are never
+	// delivered to the Conn. This prevents the weak host model from
+	// delivering traffic for overlapping link-local addresses on multi-homed
+	// hosts. BindToDevice is only supported on Linux, and typically requires
+	// the CAP_NET_RAW capability.
+	BindToDevice bool
+}
+
 // Listen creates a NDP connection using the specified interface and address
 // type.
 //
@@ -34,31 +108,146 @@ type Conn struct {
 // specific address for an interface. If the IPv6 address does not exist on the
 // interface, an error will be returned.
 //
+// On Linux, addresses which are tentative because the operating system has not
+// yet completed duplicate address detection, or for which duplicate address
+// detection failed, are never chosen. Use a ListenConfig to wait for a
+// tentative address to become usable.
+//
+// Except on Windows, Listen installs DefaultICMPFilter so that ICMPv6 messages
+// which cannot be parsed, such as Echo Replies and MLD reports, are discarded
+// by the kernel. Use SetICMPFilter to widen or narrow the filter, for example
+// when the Parser set by SetParser enables RawMessages.
+//
 // Listen returns a Conn and the chosen IPv6 address of the interface.
 func Listen(ifi *net.Interface, addr Addr) (*Conn, netip.Addr, error) {
-	addrs, err := ifi.Addrs()
+	var lc ListenConfig
+	return lc.Listen(ifi, addr)
+}
+
+// Listen creates a NDP connection in the same way as the package-level Listen,
+// using the options of lc.
+func (lc *ListenConfig) Listen(ifi *net.Interface, addr Addr) (*Conn, netip.Addr, error) {
+	ip, err := lc.chooseAddr(ifi, addr)
 	if err != nil {
 		return nil, netip.Addr{}, err
 	}
 
-	ip, err := chooseAddr(addrs, ifi.Name, addr)
+	c, err := lc.listen(ifi, ip)
 	if err != nil {
 		return nil, netip.Addr{}, err
 	}
 
-	ic, err := icmp.ListenPacket("ip6:ipv6-icmp", ip.String())
+	return c, ip, nil
+}
+
+// listen creates a NDP connection bound to ip, an address of ifi chosen by
+// chooseAddr.
+func (lc *ListenConfig) listen(ifi *net.Interface, ip netip.Addr) (*Conn, error) {
+	conn, err := lc.listenPacket(ifi, ip)
 	if err != nil {
-		return nil, netip.Addr{}, err
+		return nil, err
 	}
 
-	pc := ic.IPv6PacketConn()
+	lcc := *lc
+	if lcc.ICMPFilter == nil && runtime.GOOS != "windows" {
+		// Filter unrecognized ICMPv6 messages in the kernel (not implemented
+		// by golang.org/x/net/ipv6 on Windows).
+		lcc.ICMPFilter = DefaultICMPFilter()
+	}
 
-	// Hop limit is always 255, per RFC 4861.
-	if err := pc.SetHopLimit(HopLimit); err != nil {
-		return nil, netip.Addr{}, err
+	c, err := lcc.NewConn(ipv6.NewPacketConn(conn), ifi, ip)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
 	}
-	if err := pc.SetMulticastHopLimit(HopLimit); err != nil {
-		return nil, netip.Addr{}, err
+
+	if sc, ok := conn.(syscall.Conn); ok {
+		c.sc = sc
+	}
+
+	return c, nil
+}
+
+// listenPacket creates an ICMPv6 socket bound to ip, and to ifi if
+// BindToDevice is set.
+func (lc *ListenConfig) listenPacket(ifi *net.Interface, ip netip.Addr) (net.PacketConn, error) {
+	var nlc net.ListenConfig
+	if lc.BindToDevice {
+		control, err := bindToDevice(ifi)
+		if err != nil {
+			return nil, err
+		}
+
+		nlc.Control = control
+	}
+
+	return nlc.ListenPacket(context.Background(), "ip6:ipv6-icmp", ip.String())
+}
+
+// NewConn creates a NDP connection from an existing ICMPv6 socket, such as one
+// created with custom socket options, or from one end of a Pipe. The socket
+// should be bound to addr on ifi; an *icmp.PacketConn can be converted using
+// its IPv6PacketConn method.
+//
+// If pc is an *ipv6.PacketConn, NewConn configures it in the same way as
+// Listen: the hop limit of outgoing messages is set to 255 and, where
+// supported, the kernel computes ICMPv6 checksums. Closing the returned Conn
+// closes pc.
+func NewConn(pc PacketConn, ifi *net.Interface, addr netip.Addr) (*Conn, error) {
+	var lc ListenConfig
+	return lc.NewConn(pc, ifi, addr)
+}
+
+// NewConn creates a NDP connection in the same way as the package-level
+// NewConn, using the options of lc.
+func (lc *ListenConfig) NewConn(pc PacketConn, ifi *net.Interface, addr netip.Addr) (*Conn, error) {
+	if pc == nil {
+		return nil, errors.New("ndp: nil PacketConn")
+	}
+	if ifi == nil {
+		return nil, errors.New("ndp: nil network interface")
+	}
+	if err := checkIPv6(addr); err != nil {
+		return nil, err
+	}
+
+	hopLimit := lc.HopLimit
+	switch {
+	case hopLimit == 0:
+		hopLimit = HopLimit
+	case hopLimit < 0 || hopLimit > HopLimit:
+		return nil, fmt.Errorf("ndp: invalid hop limit: %d", lc.HopLimit)
+	}
+
+	if pc, ok := pc.(*ipv6.PacketConn); ok {
+		if err := configureSocket(pc, hopLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if lc.ICMPFilter != nil {
+		if err := pc.SetICMPFilter(lc.ICMPFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	c, _, err := newConn(pc, addr, ifi)
+	if err != nil {
+		return nil, err
+	}
+	c.cm.HopLimit = hopLimit
+
+	return c, nil
+}
+
+// configureSocket applies the socket options required for NDP to pc.
+func configureSocket(pc *ipv6.PacketConn, hopLimit int) error {
+	// Hop limit is always 255 unless overridden, per RFC 4861.
+	if err := pc.SetHopLimit(hopLimit); err != nil {
+		return err
+	}
+	if err := pc.SetMulticastHopLimit(hopLimit); err != nil {
+		return err
 	}
 
 	if runtime.GOOS != "windows" {
@@ -66,16 +255,67 @@ func Listen(ifi *net.Interface, addr Addr) (*Conn, netip.Addr, error) {
 		// messages (not implemented by golang.org/x/net/ipv6 on Windows).
 		const chkOff = 2
 		if err := pc.SetChecksum(true, chkOff); err != nil {
-			return nil, netip.Addr{}, err
+			return err
 		}
 	}
 
-	return newConn(pc, ip, ifi)
+	return nil
+}
+
+// chooseAddr chooses a usable address of ifi which matches addr, waiting for
+// tentative addresses to become usable if configured to do so.
+func (lc *ListenConfig) chooseAddr(ifi *net.Interface, addr Addr) (netip.Addr, error) {
+	deadline := time.Now().Add(lc.TentativeTimeout)
+	for {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return netip.Addr{}, err
+		}
+
+		unusable, err := unusableAddrs(ifi)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+
+		// Remove any unusable addresses before choosing.
+		usable := make([]net.Addr, 0, len(addrs))
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				if ip, ok := netip.AddrFromSlice(ipn.IP); ok && unusable[ip] {
+					continue
+				}
+			}
+
+			usable = append(usable, a)
+		}
+
+		ip, err := chooseAddr(usable, ifi.Name, addr)
+		if err == nil || len(unusable) == 0 || !time.Now().Before(deadline) {
+			return ip, err
+		}
+
+		time.Sleep(readyInterval)
+	}
+}
+
+// requiresHopLimit reports whether messages of type t must be received with a
+// hop limit of 255.
+func requiresHopLimit(t ipv6.ICMPType) bool {
+	switch t {
+	case ipv6.ICMPTypeNeighborAdvertisement, ipv6.ICMPTypeNeighborSolicitation,
+		ipv6.ICMPTypeRouterAdvertisement, ipv6.ICMPTypeRouterSolicitation,
+		ipv6.ICMPTypeRedirect,
+		ipv6.ICMPTypeInverseNeighborDiscoverySolicitation,
+		ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement:
+		return true
+	default:
+		return false
+	}
 }
 
 // newConn is an internal test constructor used for creating a Conn from an
-// arbitrary ipv6.PacketConn.
-func newConn(pc *ipv6.PacketConn, src netip.Addr, ifi *net.Interface) (*Conn, netip.Addr, error) {
+// arbitrary PacketConn.
+func newConn(pc PacketConn, src netip.Addr, ifi *net.Interface) (*Conn, netip.Addr, error) {
 	c := &Conn{
 		pc: pc,
 
@@ -93,8 +333,91 @@ func newConn(pc *ipv6.PacketConn, src netip.Addr, ifi *net.Interface) (*Conn, ne
 	return c, src, nil
 }
 
-// Close closes the Conn's underlying connection.
-func (c *Conn) Close() error { return c.pc.Close() }
+// Close leaves any multicast groups joined by the Conn and closes its
+// underlying connection.
+func (c *Conn) Close() error {
+	c.groupsMu.Lock()
+	for group := range c.groups {
+		// The operating system also leaves groups when a socket is closed, so
+		// errors are not fatal.
+		_ = c.pc.LeaveGroup(c.ifi, c.ipAddr(group))
+	}
+	c.groups = nil
+	c.groupsMu.Unlock()
+
+	return c.pc.Close()
+}
+
+// PacketConn returns the PacketConn used by the Conn for I/O. For a Conn
+// created by Listen, it is an *ipv6.PacketConn which may be used to set IPv6
+// socket options which the Conn does not expose. Reading from or writing to
+// the PacketConn directly bypasses the Conn.
+func (c *Conn) PacketConn() PacketConn { return c.pc }
+
+// SyscallConn returns a raw network connection for the Conn's socket, which
+// may be used to set platform-specific socket options such as SO_RCVBUFFORCE
+// or SO_PRIORITY. SyscallConn returns an error for a Conn created by NewConn,
+// whose caller already owns the socket.
+func (c *Conn) SyscallConn() (syscall.RawConn, error) {
+	if c.sc == nil {
+		return nil, errors.New("ndp: Conn was not created by Listen")
+	}
+
+	return c.sc.SyscallConn()
+}
+
+// readyInterval is the interval at which Ready checks whether the address of
+// a Conn is usable.
+const readyInterval = 100 * time.Millisecond
+
+// A NotReadyError is returned by Conn.Ready when the address of a Conn cannot
+// be used to send messages, such as while it is tentative during the
+// operating system's duplicate address detection.
+type NotReadyError struct {
+	Addr netip.Addr
+	Err  error
+}
+
+// Error implements error.
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("ndp: address %s is not ready: %v", e.Addr, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NotReadyError) Unwrap() error { return e.Err }
+
+// Ready blocks until the address of the Conn can be used to send messages, or
+// until ctx is canceled, in which case a *NotReadyError is returned. A Conn
+// using the unspecified address is always ready.
+//
+// An address is not usable while it is tentative, which is the case until the
+// operating system completes duplicate address detection for it, and writes
+// from the address fail. Ready determines whether the address is usable by
+// binding a UDP socket to it, which fails for tentative addresses.
+func (c *Conn) Ready(ctx context.Context) error {
+	if c.addr.IsUnspecified() {
+		return nil
+	}
+
+	t := time.NewTicker(readyInterval)
+	defer t.Stop()
+
+	for {
+		conn, err := net.ListenUDP("udp6", &net.UDPAddr{
+			IP:   c.addr.AsSlice(),
+			Zone: c.ifi.Name,
+		})
+		if err == nil {
+			return conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return &NotReadyError{Addr: c.addr, Err: err}
+		case <-t.C:
+		}
+	}
+}
 
 // SetDeadline sets the read and write deadlines for Conn.  It is
 // equivalent to calling both SetReadDeadline and SetWriteDeadline.
@@ -109,64 +432,595 @@ func (c *Conn) SetWriteDeadline(t time.Time) error { return c.pc.SetWriteDeadlin
 // JoinGroup joins the specified multicast group. If group contains an IPv6
 // zone, it is overwritten by the zone of the network interface which backs
 // Conn.
+//
+// Joins are counted: if the Conn has already joined group, JoinGroup succeeds
+// without joining it again, and the group is only left once LeaveGroup has been
+// called for each join. All groups are left when the Conn is closed.
 func (c *Conn) JoinGroup(group netip.Addr) error {
-	return c.pc.JoinGroup(c.ifi, &net.IPAddr{
-		IP:   group.AsSlice(),
-		Zone: c.ifi.Name,
-	})
+	group = group.WithZone("")
+
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	if c.groups[group] == 0 {
+		if err := c.pc.JoinGroup(c.ifi, c.ipAddr(group)); err != nil {
+			return err
+		}
+	}
+
+	if c.groups == nil {
+		c.groups = make(map[netip.Addr]int)
+	}
+	c.groups[group]++
+
+	return nil
 }
 
 // LeaveGroup leaves the specified multicast group. If group contains an IPv6
 // zone, it is overwritten by the zone of the network interface which backs
-// Conn.
+// Conn. See JoinGroup for details on how joins are counted.
 func (c *Conn) LeaveGroup(group netip.Addr) error {
-	return c.pc.LeaveGroup(c.ifi, &net.IPAddr{
-		IP:   group.AsSlice(),
-		Zone: c.ifi.Name,
-	})
+	group = group.WithZone("")
+
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	switch n := c.groups[group]; n {
+	case 0:
+		// Not joined by this Conn; defer to the operating system.
+		return c.pc.LeaveGroup(c.ifi, c.ipAddr(group))
+	case 1:
+		if err := c.pc.LeaveGroup(c.ifi, c.ipAddr(group)); err != nil {
+			return err
+		}
+
+		delete(c.groups, group)
+	default:
+		c.groups[group] = n - 1
+	}
+
+	return nil
+}
+
+// JoinSolicitedNodeMulticast joins the solicited-node multicast group of ip,
+// as computed by SolicitedNodeMulticast. Addresses which share a
+// solicited-node multicast group are counted as separate joins of that group.
+func (c *Conn) JoinSolicitedNodeMulticast(ip netip.Addr) error {
+	snm, err := SolicitedNodeMulticast(ip)
+	if err != nil {
+		return err
+	}
+
+	return c.JoinGroup(snm)
+}
+
+// LeaveSolicitedNodeMulticast leaves the solicited-node multicast group of
+// ip which was joined by JoinSolicitedNodeMulticast.
+func (c *Conn) LeaveSolicitedNodeMulticast(ip netip.Addr) error {
+	snm, err := SolicitedNodeMulticast(ip)
+	if err != nil {
+		return err
+	}
+
+	return c.LeaveGroup(snm)
+}
+
+// Groups returns the multicast groups joined by the Conn, sorted by address.
+func (c *Conn) Groups() []netip.Addr {
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	groups := make([]netip.Addr, 0, len(c.groups))
+	for group := range c.groups {
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Less(groups[j]) })
+	return groups
 }
 
 // SetICMPFilter applies the specified ICMP filter. This option can be used
 // to ensure a Conn only accepts certain kinds of NDP messages.
 func (c *Conn) SetICMPFilter(f *ipv6.ICMPFilter) error { return c.pc.SetICMPFilter(f) }
 
+// bpfSetter is implemented by PacketConns which can attach BPF programs, such
+// as *ipv6.PacketConn.
+type bpfSetter interface {
+	SetBPF(filter []bpf.RawInstruction) error
+}
+
+// SetBPF attaches a classic BPF program to the Conn's socket, so that the
+// kernel discards packets which are not needed before they are read. The
+// program is applied to the ICMPv6 message: the IPv6 header is not included,
+// so offset 0 is the ICMPv6 type. Packets discarded by the program are never
+// seen by ReadFrom or a tap.
+//
+// SetBPF is only supported on Linux, and returns an error for PacketConns
+// which cannot attach BPF programs.
+func (c *Conn) SetBPF(filter []bpf.RawInstruction) error {
+	bs, ok := c.pc.(bpfSetter)
+	if !ok {
+		return fmt.Errorf("ndp: %T does not support BPF programs", c.pc)
+	}
+
+	return bs.SetBPF(filter)
+}
+
+// DefaultICMPFilter returns an ICMP filter which accepts only the types of
+// MessageTypes, including those added by RegisterMessage before it is called.
+func DefaultICMPFilter() *ipv6.ICMPFilter {
+	var f ipv6.ICMPFilter
+	f.SetAll(true)
+	for _, mt := range MessageTypes() {
+		f.Accept(mt.Type)
+	}
+
+	return &f
+}
+
+// SetICMPErrors enables or disables the reception of ICMPv6 error messages as
+// *ICMPError Messages in ReadFrom. ICMPv6 errors are filtered by default.
+func (c *Conn) SetICMPErrors(on bool) { c.icmpErrors.Store(on) }
+
+// SetMalformedMessages enables or disables the reception of ICMPv6 messages
+// which cannot be parsed in ReadFrom. When enabled, such messages are returned
+// as *RawMessages with Err set to the parsing error, rather than being
+// filtered. Malformed messages are filtered by default.
+func (c *Conn) SetMalformedMessages(on bool) { c.malformed.Store(on) }
+
+// SetParser sets the Parser used to parse Messages in ReadFrom. If p is nil,
+// Messages are parsed as by ParseMessage. p must not be modified after it is
+// passed to SetParser.
+//
+// If p has RawMessages set, ICMPv6 messages of unrecognized types are returned
+// as *RawMessages rather than being filtered.
+func (c *Conn) SetParser(p *Parser) { c.parser.Store(p) }
+
+// SetDuplicateWindow enables the suppression of duplicate messages in
+// ReadFrom, such as multicast messages which are flooded more than once by a
+// switch. If d is greater than zero, a message which is identical to one
+// received from the same source address within the previous d is filtered.
+// If d is zero, duplicate suppression is disabled, which is the default.
+//
+// Retransmissions which are expected to be identical, such as repeated
+// neighbor solicitations, are also suppressed if they arrive within d, so d
+// should be much shorter than any retransmission interval.
+func (c *Conn) SetDuplicateWindow(d time.Duration) { c.dedup.setWindow(d) }
+
 // SetControlMessage enables the reception of *ipv6.ControlMessages based on
 // the specified flags.
+//
+// If the hop limit check is enabled by SetHopLimitCheck, the reception of
+// ipv6.FlagHopLimit cannot be disabled.
 func (c *Conn) SetControlMessage(cf ipv6.ControlFlags, on bool) error {
+	if !on && c.hopLimitCheck.Load() {
+		cf &^= ipv6.FlagHopLimit
+	}
+
 	return c.pc.SetControlMessage(cf, on)
 }
 
+// SetHopLimitCheck enables or disables the hop limit check in ReadFrom. When
+// enabled, Neighbor Discovery messages which were not received with a hop
+// limit of 255 are discarded, as described in RFC 4861, Section 6.1, because
+// they must have been forwarded by a router from off-link. This check is
+// disabled by default.
+//
+// Enabling the check enables the reception of control messages with
+// ipv6.FlagHopLimit. The check is performed by the Conn rather than the
+// kernel: setting IPV6_MINHOPCOUNT on Linux is accepted for raw sockets but
+// is only enforced for TCP.
+func (c *Conn) SetHopLimitCheck(on bool) error {
+	if on {
+		if err := c.pc.SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+			return err
+		}
+	}
+
+	c.hopLimitCheck.Store(on)
+	return nil
+}
+
+// A ControlMessagePolicy specifies the control message which a Conn applies
+// to writes which do not specify one.
+type ControlMessagePolicy int
+
+// Possible ControlMessagePolicy values.
+const (
+	// ControlMessageSource sets the hop limit, the interface index, and the
+	// source address of the Conn. This is the default policy.
+	ControlMessageSource ControlMessagePolicy = iota
+
+	// ControlMessageInterface sets the hop limit and the interface index of
+	// the Conn, allowing the operating system to choose a source address.
+	ControlMessageInterface
+
+	// ControlMessageNone sends no control message, relying on the socket
+	// options and binding of the Conn.
+	ControlMessageNone
+)
+
+// SetControlMessagePolicy sets the control message policy applied by WriteTo
+// when its control message is nil, and by WriteToFrom.
+func (c *Conn) SetControlMessagePolicy(p ControlMessagePolicy) error {
+	switch p {
+	case ControlMessageSource, ControlMessageInterface, ControlMessageNone:
+	default:
+		return fmt.Errorf("ndp: invalid control message policy: %d", p)
+	}
+
+	c.cmPolicy.Store(int32(p))
+	return nil
+}
+
+// controlMessage returns the default control message for a write according to
+// the control message policy, using src as the source address if valid.
+func (c *Conn) controlMessage(src netip.Addr) *ipv6.ControlMessage {
+	tclass := int(c.tclass.Load())
+
+	var cm *ipv6.ControlMessage
+	switch ControlMessagePolicy(c.cmPolicy.Load()) {
+	case ControlMessageSource:
+		if !src.IsValid() && tclass == 0 {
+			return c.cm
+		}
+
+		cmc := *c.cm
+		cm = &cmc
+	case ControlMessageInterface:
+		cm = &ipv6.ControlMessage{
+			HopLimit: c.cm.HopLimit,
+			IfIndex:  c.cm.IfIndex,
+		}
+	case ControlMessageNone:
+		if !src.IsValid() {
+			return nil
+		}
+
+		cm = &ipv6.ControlMessage{}
+	}
+
+	if src.IsValid() {
+		cm.Src = src.AsSlice()
+	}
+	cm.TrafficClass = tclass
+
+	return cm
+}
+
+// trafficClassSetter is implemented by PacketConns which can set the IPv6
+// traffic class of outgoing packets, such as *ipv6.PacketConn.
+type trafficClassSetter interface {
+	SetTrafficClass(tclass int) error
+}
+
+// SetTrafficClass sets the IPv6 traffic class of outgoing messages, such as
+// 0xc0 to mark messages with the DSCP class selector CS6. The traffic class is
+// set on the Conn's socket, where supported, and in the default control
+// messages chosen by WriteTo. To override the traffic class of a single
+// message, pass a control message with TrafficClass set to WriteTo.
+func (c *Conn) SetTrafficClass(tclass int) error {
+	if tclass < 0 || tclass > 255 {
+		return fmt.Errorf("ndp: invalid traffic class: %d", tclass)
+	}
+
+	if ts, ok := c.pc.(trafficClassSetter); ok {
+		if err := ts.SetTrafficClass(tclass); err != nil {
+			return err
+		}
+	}
+
+	c.tclass.Store(int32(tclass))
+	return nil
+}
+
 // ReadFrom reads a Message from the Conn and returns its control message and
 // source network address. Messages sourced from this machine and malformed or
-// unrecognized ICMPv6 messages are filtered.
+// unrecognized ICMPv6 messages are filtered, as are ICMPv6 errors unless
+// enabled by SetICMPErrors. Messages are parsed according to the Parser set
+// by SetParser.
 //
 // If more control and/or a more efficient low-level API are required, see
 // ReadRaw.
 func (c *Conn) ReadFrom() (Message, *ipv6.ControlMessage, netip.Addr, error) {
-	b := make([]byte, c.ifi.MTU)
+	_, m, cm, ip, err := c.ReadFromBuffer(make([]byte, c.ifi.MTU))
+	return m, cm, ip, err
+}
+
+// ReadFromBuffer reads a Message from the Conn in the same way as ReadFrom,
+// using b to receive the message, and also returns the number of bytes of b
+// which hold the message as it was received. This allows callers to retain the
+// exact bytes of a Message without copying them; b should be at least as large
+// as the MTU of the Conn's interface. The Message may refer to b.
+func (c *Conn) ReadFromBuffer(b []byte) (int, Message, *ipv6.ControlMessage, netip.Addr, error) {
 	for {
 		n, cm, ip, err := c.ReadRaw(b)
 		if err != nil {
-			return nil, nil, netip.Addr{}, err
+			return 0, nil, nil, netip.Addr{}, err
 		}
 
-		// Filter if this address sent this message, but allow toggling that
-		// behavior in tests.
-		if !c.icmpTest && ip == c.addr {
-			continue
+		m, ok, err := c.accept(b[:n], cm, ip)
+		if err != nil {
+			return 0, nil, nil, netip.Addr{}, err
+		}
+		if ok {
+			return n, m, cm, ip, nil
+		}
+	}
+}
+
+// accept applies the filters of ReadFrom to the packet b received from ip, and
+// parses it into a Message. It reports false if the packet was filtered.
+func (c *Conn) accept(b []byte, cm *ipv6.ControlMessage, ip netip.Addr) (Message, bool, error) {
+	// Filter if this address sent this message, but allow toggling that
+	// behavior in tests.
+	if !c.icmpTest && ip == c.addr {
+		return nil, false, nil
+	}
+
+	if c.dedup.duplicate(time.Now(), ip, b) {
+		return nil, false, nil
+	}
+
+	// Drop off-link Neighbor Discovery messages if requested.
+	if c.hopLimitCheck.Load() && len(b) > 0 && requiresHopLimit(ipv6.ICMPType(b[0])) &&
+		(cm == nil || cm.HopLimit != HopLimit) {
+		return nil, false, nil
+	}
+
+	m, err := c.parser.Load().ParseMessage(b)
+	if err != nil {
+		// Filter parsing errors on the caller's behalf, unless they are
+		// requested.
+		if errors.Is(err, errParseMessage) {
+			if !c.malformed.Load() {
+				return nil, false, nil
+			}
+
+			return malformedMessage(b, err), true, nil
+		}
+
+		return nil, false, err
+	}
+
+	if _, ok := m.(*ICMPError); ok && !c.icmpErrors.Load() {
+		return nil, false, nil
+	}
+
+	return m, true, nil
+}
+
+// malformedMessage returns a RawMessage for the unparseable message b.
+func malformedMessage(b []byte, err error) *RawMessage {
+	rm := &RawMessage{Err: err}
+	if len(b) > 0 {
+		rm.MessageType = ipv6.ICMPType(b[0])
+	}
+	if len(b) > 1 {
+		rm.Code = b[1]
+	}
+	if len(b) > icmpLen {
+		rm.Body = b[icmpLen:]
+	}
+
+	return rm
+}
+
+// A MessageBuffer holds a Message read by ReadBatch.
+type MessageBuffer struct {
+	// Buf is the buffer used to receive the message. If it is empty,
+	// ReadBatch allocates a buffer of the MTU of the Conn's interface.
+	// The parsed Message may refer to Buf.
+	Buf []byte
+
+	// Message, ControlMessage, and From are set by ReadBatch to the
+	// values which ReadFrom would return.
+	Message        Message
+	ControlMessage *ipv6.ControlMessage
+	From           netip.Addr
+}
+
+// batchReader is implemented by PacketConns which can read multiple packets
+// at once, such as *ipv6.PacketConn.
+type batchReader interface {
+	ReadBatch(ms []ipv6.Message, flags int) (int, error)
+}
+
+// batchFlags are the control message flags which ReadBatch allocates space
+// for.
+const batchFlags = ipv6.FlagTrafficClass | ipv6.FlagHopLimit | ipv6.FlagSrc |
+	ipv6.FlagDst | ipv6.FlagInterface | ipv6.FlagPathMTU
+
+// ReadBatch reads one or more Messages from the Conn into ms, blocking until
+// at least one Message is received, and returns the number of elements of ms
+// which were filled. Messages are filtered and parsed in the same way as
+// ReadFrom.
+//
+// On Linux, ReadBatch drains multiple packets from the socket with a single
+// recvmmsg system call. Elsewhere, and for PacketConns which cannot read
+// batches of packets, ReadBatch reads a single Message. The buffers of ms may
+// be reordered so that each filled element owns the buffer its Message was
+// received in.
+func (c *Conn) ReadBatch(ms []MessageBuffer) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+
+	for i := range ms {
+		if len(ms[i].Buf) == 0 {
+			ms[i].Buf = make([]byte, c.ifi.MTU)
+		}
+	}
+
+	br, ok := c.pc.(batchReader)
+	if !ok {
+		for {
+			n, cm, ip, err := c.ReadRaw(ms[0].Buf)
+			if err != nil {
+				return 0, err
+			}
+
+			m, ok, err := c.accept(ms[0].Buf[:n], cm, ip)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				ms[0].Message, ms[0].ControlMessage, ms[0].From = m, cm, ip
+				return 1, nil
+			}
+		}
+	}
+
+	bms := make([]ipv6.Message, len(ms))
+	for {
+		for i := range bms {
+			bms[i] = ipv6.Message{
+				Buffers: [][]byte{ms[i].Buf},
+				OOB:     ipv6.NewControlMessage(batchFlags),
+			}
 		}
 
-		m, err := ParseMessage(b[:n])
+		n, err := br.ReadBatch(bms, 0)
 		if err != nil {
-			// Filter parsing errors on the caller's behalf.
-			if errors.Is(err, errParseMessage) {
+			return 0, err
+		}
+
+		var filled int
+		for i, bm := range bms[:n] {
+			var cm *ipv6.ControlMessage
+			if bm.NN > 0 {
+				cm = new(ipv6.ControlMessage)
+				if err := cm.Parse(bm.OOB[:bm.NN]); err != nil {
+					return 0, err
+				}
+			}
+
+			b := ms[i].Buf
+			bn, ip, ok, err := c.receive(b[:bm.N], cm, bm.Addr)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
 				continue
 			}
 
-			return nil, nil, netip.Addr{}, err
+			m, ok, err := c.accept(b[:bn], cm, ip)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				continue
+			}
+
+			// Compact the filled elements, swapping buffers so each Message
+			// keeps the buffer it was received in.
+			ms[filled].Buf, ms[i].Buf = b, ms[filled].Buf
+			ms[filled].Message, ms[filled].ControlMessage, ms[filled].From = m, cm, ip
+			filled++
 		}
 
-		return m, cm, ip, nil
+		if filled > 0 {
+			return filled, nil
+		}
+	}
+}
+
+// ReadFromUntil reads a Message from the Conn in the same way as ReadFrom, but
+// returns a timeout error if no Message is received before t. The read
+// deadline is cleared before ReadFromUntil returns, so it does not affect
+// subsequent reads. ReadFromUntil must not be called concurrently with other
+// reads or read deadline changes on the Conn.
+func (c *Conn) ReadFromUntil(t time.Time) (Message, *ipv6.ControlMessage, netip.Addr, error) {
+	if err := c.SetReadDeadline(t); err != nil {
+		return nil, nil, netip.Addr{}, err
+	}
+
+	m, cm, from, err := c.ReadFrom()
+
+	if derr := c.SetReadDeadline(time.Time{}); err == nil && derr != nil {
+		return nil, nil, netip.Addr{}, derr
+	}
+
+	return m, cm, from, err
+}
+
+// ReadFromTimeout reads a Message from the Conn in the same way as
+// ReadFromUntil, but returns a timeout error if no Message is received within
+// d.
+func (c *Conn) ReadFromTimeout(d time.Duration) (Message, *ipv6.ControlMessage, netip.Addr, error) {
+	return c.ReadFromUntil(time.Now().Add(d))
+}
+
+// ReadFromContext reads a Message from the Conn in the same way as ReadFrom,
+// but returns ctx.Err if ctx is canceled or its deadline expires before a
+// Message is received. The read deadline is cleared before ReadFromContext
+// returns, so it does not affect subsequent reads. ReadFromContext must not be
+// called concurrently with other reads or read deadline changes on the Conn.
+func (c *Conn) ReadFromContext(ctx context.Context) (Message, *ipv6.ControlMessage, netip.Addr, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, netip.Addr{}, err
+	}
+
+	stop := c.watchContext(ctx)
+	m, cm, from, err := c.ReadFrom()
+	stop()
+
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, nil, netip.Addr{}, cerr
+		}
+
+		return nil, nil, netip.Addr{}, err
+	}
+
+	return m, cm, from, nil
+}
+
+// readMatch reads Messages from the Conn until match reports true for a
+// Message, or an error occurs.
+func (c *Conn) readMatch(match func(m Message, cm *ipv6.ControlMessage, from netip.Addr) bool) (Message, netip.Addr, error) {
+	for {
+		m, cm, from, err := c.ReadFrom()
+		if err != nil {
+			return nil, netip.Addr{}, err
+		}
+
+		if match(m, cm, from) {
+			return m, from, nil
+		}
+	}
+}
+
+// watchContext interrupts any pending reads on the Conn when ctx is canceled.
+// The returned function must be called to stop watching ctx, and it also
+// clears the read deadline so the Conn can be reused.
+//
+// Callers which set their own read deadlines must check ctx.Err after each
+// deadline is set, so that a concurrent cancelation is not overwritten.
+func (c *Conn) watchContext(ctx context.Context) func() {
+	return watchDeadline(ctx, c.SetReadDeadline)
+}
+
+// watchDeadline interrupts pending operations when ctx is canceled by setting
+// a deadline in the past with set. The returned function must be called to
+// stop watching ctx, and it also clears the deadline.
+func watchDeadline(ctx context.Context, set func(t time.Time) error) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			_ = set(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+		_ = set(time.Time{})
 	}
 }
 
@@ -176,27 +1030,60 @@ func (c *Conn) ReadFrom() (Message, *ipv6.ControlMessage, netip.Addr, error) {
 // Most callers should use ReadFrom instead, which parses bytes into Messages
 // and also handles malformed and unrecognized ICMPv6 messages.
 func (c *Conn) ReadRaw(b []byte) (int, *ipv6.ControlMessage, netip.Addr, error) {
-	n, cm, src, err := c.pc.ReadFrom(b)
-	if err != nil {
-		return n, nil, netip.Addr{}, err
+	for {
+		n, cm, src, err := c.pc.ReadFrom(b)
+		if err != nil {
+			return n, nil, netip.Addr{}, err
+		}
+
+		n, ip, ok, err := c.receive(b[:n], cm, src)
+		if err != nil {
+			return n, nil, netip.Addr{}, err
+		}
+		if ok {
+			return n, cm, ip, nil
+		}
 	}
+}
 
-	// We fully control the underlying ipv6.PacketConn, so panic if the
-	// conversions fail.
-	ip, ok := netip.AddrFromSlice(src.(*net.IPAddr).IP)
+// receive applies receive faults to the packet b read from src and mirrors
+// the result. It returns the length of the packet after faults are applied
+// and its source IP address, or false if the packet was dropped.
+func (c *Conn) receive(b []byte, cm *ipv6.ControlMessage, src net.Addr) (int, netip.Addr, bool, error) {
+	b, ok := c.receiveFaults.Load().apply(b)
 	if !ok {
-		panicf("ndp: invalid source IP address: %s", src)
+		return 0, netip.Addr{}, false, nil
+	}
+
+	ipa, ok := src.(*net.IPAddr)
+	if !ok {
+		return len(b), netip.Addr{}, false, fmt.Errorf("ndp: invalid source address: %v", src)
+	}
+	ip, ok := netip.AddrFromSlice(ipa.IP)
+	if !ok {
+		return len(b), netip.Addr{}, false, fmt.Errorf("ndp: invalid source IP address: %s", src)
 	}
 
 	// Always apply the IPv6 zone of this interface.
-	return n, cm, ip.WithZone(c.ifi.Name), nil
+	ip = ip.WithZone(c.ifi.Name)
+
+	dst := c.addr
+	if cm != nil {
+		if addr, ok := netip.AddrFromSlice(cm.Dst); ok {
+			dst = addr
+		}
+	}
+	c.mirror(b, cm, ip, dst)
+
+	return len(b), ip, true, nil
 }
 
 // WriteTo writes a Message to the Conn, with an optional control message and
 // destination network address. If dst contains an IPv6 zone, it is overwritten
 // by the zone of the network interface which backs Conn.
 //
-// If cm is nil, a default control message will be sent.
+// If cm is nil, a default control message is chosen according to the policy
+// set by SetControlMessagePolicy.
 func (c *Conn) WriteTo(m Message, cm *ipv6.ControlMessage, dst netip.Addr) error {
 	b, err := MarshalMessage(m)
 	if err != nil {
@@ -206,18 +1093,176 @@ func (c *Conn) WriteTo(m Message, cm *ipv6.ControlMessage, dst netip.Addr) error
 	return c.writeRaw(b, cm, dst)
 }
 
+// WriteToContext writes a Message to the Conn in the same way as WriteTo, but
+// returns ctx.Err if ctx is canceled or its deadline expires before the
+// Message is written. The write deadline is cleared before WriteToContext
+// returns. WriteToContext must not be called concurrently with other writes or
+// write deadline changes on the Conn.
+func (c *Conn) WriteToContext(ctx context.Context, m Message, cm *ipv6.ControlMessage, dst netip.Addr) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stop := watchDeadline(ctx, c.SetWriteDeadline)
+	err := c.WriteTo(m, cm, dst)
+	stop()
+
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// WriteToFrom writes a Message to the Conn in the same way as WriteTo with a
+// nil control message, but overrides the source address of the default
+// control message with src.
+func (c *Conn) WriteToFrom(m Message, src, dst netip.Addr) error {
+	if err := checkIPv6(src); err != nil {
+		return err
+	}
+
+	b, err := MarshalMessage(m)
+	if err != nil {
+		return err
+	}
+
+	return c.writeRaw(b, c.controlMessage(src.WithZone("")), dst)
+}
+
 // writeRaw allows writing raw bytes with a Conn.
 func (c *Conn) writeRaw(b []byte, cm *ipv6.ControlMessage, dst netip.Addr) error {
 	// Set reasonable defaults if control message is nil.
 	if cm == nil {
-		cm = c.cm
+		cm = c.controlMessage(netip.Addr{})
+	}
+
+	b, ok := c.sendFaults.Load().apply(b)
+	if !ok {
+		return nil
 	}
 
-	_, err := c.pc.WriteTo(b, cm, &net.IPAddr{
-		IP:   dst.AsSlice(),
+	if _, err := c.pc.WriteTo(b, cm, c.ipAddr(dst)); err != nil {
+		return err
+	}
+
+	c.mirrorSent(b, cm, dst)
+	return nil
+}
+
+// ipAddr returns a net.IPAddr for ip with the zone of the Conn's interface.
+func (c *Conn) ipAddr(ip netip.Addr) *net.IPAddr {
+	return &net.IPAddr{
+		IP:   ip.AsSlice(),
 		Zone: c.ifi.Name,
-	})
-	return err
+	}
+}
+
+// mirrorSent mirrors the packet b which was sent to dst with control message
+// cm.
+func (c *Conn) mirrorSent(b []byte, cm *ipv6.ControlMessage, dst netip.Addr) {
+	src := c.addr
+	if cm != nil {
+		if addr, ok := netip.AddrFromSlice(cm.Src); ok {
+			src = addr
+		}
+	}
+	c.mirror(b, cm, src, dst)
+}
+
+// An OutgoingMessage is a Message to be written by WriteBatch.
+type OutgoingMessage struct {
+	// Message, ControlMessage, and Dst are the arguments which WriteTo
+	// accepts. If ControlMessage is nil, a default control message is chosen.
+	Message        Message
+	ControlMessage *ipv6.ControlMessage
+	Dst            netip.Addr
+}
+
+// batchWriter is implemented by PacketConns which can write multiple packets
+// at once, such as *ipv6.PacketConn.
+type batchWriter interface {
+	WriteBatch(ms []ipv6.Message, flags int) (int, error)
+}
+
+// WriteBatch writes the Messages of ms to the Conn in the same way as WriteTo,
+// and returns the number of elements of ms which were written. All Messages
+// are marshaled before any are written, so a marshaling error writes none of
+// them.
+//
+// On Linux, WriteBatch sends multiple packets with a single sendmmsg system
+// call. Elsewhere, and for PacketConns which cannot write batches of packets,
+// WriteBatch writes each Message in turn.
+func (c *Conn) WriteBatch(ms []OutgoingMessage) (int, error) {
+	var (
+		bms  = make([]ipv6.Message, 0, len(ms))
+		cms  = make([]*ipv6.ControlMessage, 0, len(ms))
+		dsts = make([]netip.Addr, 0, len(ms))
+
+		// idx maps bms to the indices of ms, as faults may drop messages.
+		idx = make([]int, 0, len(ms))
+	)
+
+	for i, m := range ms {
+		b, err := MarshalMessage(m.Message)
+		if err != nil {
+			return 0, err
+		}
+
+		cm := m.ControlMessage
+		if cm == nil {
+			cm = c.controlMessage(netip.Addr{})
+		}
+
+		b, ok := c.sendFaults.Load().apply(b)
+		if !ok {
+			continue
+		}
+
+		bms = append(bms, ipv6.Message{Buffers: [][]byte{b}, Addr: c.ipAddr(m.Dst)})
+		cms = append(cms, cm)
+		dsts = append(dsts, m.Dst)
+		idx = append(idx, i)
+	}
+
+	bw, ok := c.pc.(batchWriter)
+	if !ok {
+		for i, bm := range bms {
+			if _, err := c.pc.WriteTo(bm.Buffers[0], cms[i], bm.Addr); err != nil {
+				return idx[i], err
+			}
+
+			c.mirrorSent(bm.Buffers[0], cms[i], dsts[i])
+		}
+
+		return len(ms), nil
+	}
+
+	for i := range bms {
+		bms[i].OOB = cms[i].Marshal()
+	}
+
+	for sent := 0; sent < len(bms); {
+		n, err := bw.WriteBatch(bms[sent:], 0)
+		for i := sent; i < sent+n; i++ {
+			c.mirrorSent(bms[i].Buffers[0], cms[i], dsts[i])
+		}
+		sent += n
+
+		if err != nil {
+			if sent == len(bms) {
+				return len(ms), err
+			}
+
+			return idx[sent], err
+		}
+	}
+
+	return len(ms), nil
 }
 
 // SolicitedNodeMulticast returns the solicited-node multicast address for
@@ -241,6 +1286,12 @@ func SolicitedNodeMulticast(ip netip.Addr) (netip.Addr, error) {
 	return netip.AddrFrom16(snm), nil
 }
 
+// isTimeout reports whether err is a net.Error timeout.
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
 func panicf(format string, a ...any) {
 	panic(fmt.Sprintf(format, a...))
 }
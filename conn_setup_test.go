@@ -25,6 +25,41 @@ func testICMPConn(t *testing.T) (*Conn, *Conn, netip.Addr) {
 	return c1, c2, addr
 }
 
+func testPipeConn(t *testing.T) (*Conn, *Conn, netip.Addr) {
+	t.Helper()
+
+	var (
+		ifi = &net.Interface{
+			Index:        1,
+			Name:         "ndp0",
+			MTU:          1500,
+			HardwareAddr: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		}
+		addr   = netip.MustParseAddr("fe80::1")
+		p1, p2 = Pipe()
+	)
+
+	// Create two in-memory connections which share an address, like those of
+	// testICMPConn.
+	c1, err := NewConn(p1, ifi, addr)
+	if err != nil {
+		t.Fatalf("failed to create c1: %v", err)
+	}
+	c2, err := NewConn(p2, ifi, addr)
+	if err != nil {
+		t.Fatalf("failed to create c2: %v", err)
+	}
+	c1.icmpTest = true
+	c2.icmpTest = true
+
+	t.Cleanup(func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	})
+
+	return c1, c2, addr.WithZone(ifi.Name)
+}
+
 func icmpConn(t *testing.T, ifi *net.Interface) (*Conn, netip.Addr) {
 	t.Helper()
 
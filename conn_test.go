@@ -2,20 +2,30 @@ package ndp
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"net"
 	"net/netip"
+	"os"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
 )
 
 func TestConn(t *testing.T) {
 	tests := []struct {
 		name string
 		fn   func(t *testing.T, c1, c2 *Conn, addr netip.Addr)
+
+		// icmpOnly skips tests which require a real network interface.
+		icmpOnly bool
 	}{
 		{
 			name: "echo",
@@ -25,6 +35,80 @@ func TestConn(t *testing.T) {
 			name: "filter invalid",
 			fn:   testConnFilterInvalid,
 		},
+		{
+			name: "wait for router",
+			fn:   testConnWaitForRouter,
+		},
+		{
+			name: "probe neighbor",
+			fn:   testConnProbeNeighbor,
+		},
+		{
+			name: "ICMP errors",
+			fn:   testConnICMPErrors,
+		},
+		{
+			name: "raw messages",
+			fn:   testConnRawMessages,
+		},
+		{
+			name: "tap",
+			fn:   testConnTap,
+		},
+		{
+			name: "read timeout",
+			fn:   testConnReadTimeout,
+		},
+		{
+			name:     "ready",
+			fn:       testConnReady,
+			icmpOnly: true,
+		},
+		{
+			name: "duplicates",
+			fn:   testConnDuplicates,
+		},
+		{
+			name: "faults",
+			fn:   testConnFaults,
+		},
+		{
+			name: "hop limit check",
+			fn:   testConnHopLimitCheck,
+		},
+		{
+			name: "read batch",
+			fn:   testConnReadBatch,
+		},
+		{
+			name: "write batch",
+			fn:   testConnWriteBatch,
+		},
+		{
+			name: "context",
+			fn:   testConnContext,
+		},
+		{
+			name: "read from buffer",
+			fn:   testConnReadFromBuffer,
+		},
+		{
+			name: "malformed messages",
+			fn:   testConnMalformedMessages,
+		},
+		{
+			name: "groups",
+			fn:   testConnGroups,
+		},
+		{
+			name: "traffic class",
+			fn:   testConnTrafficClass,
+		},
+		{
+			name:     "BPF",
+			fn:       testConnBPF,
+			icmpOnly: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -32,6 +116,14 @@ func TestConn(t *testing.T) {
 			c1, c2, addr := testICMPConn(t)
 			tt.fn(t, c1, c2, addr)
 		})
+		t.Run(tt.name+" pipe", func(t *testing.T) {
+			if tt.icmpOnly {
+				t.Skip("skipping, test requires a network interface")
+			}
+
+			c1, c2, addr := testPipeConn(t)
+			tt.fn(t, c1, c2, addr)
+		})
 	}
 }
 
@@ -146,6 +238,1420 @@ func testConnFilterInvalid(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
 	}
 }
 
+func testConnWaitForRouter(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	// Act as a router by joining the all-routers group and replying to the
+	// first router solicitation.
+	if err := c2.JoinGroup(netip.MustParseAddr("ff02::2")); err != nil {
+		t.Fatalf("failed to join all-routers group: %v", err)
+	}
+
+	want := &RouterAdvertisement{
+		CurrentHopLimit: 64,
+		RouterLifetime:  30 * time.Minute,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			m, _, _, err := c2.ReadFrom()
+			if err != nil {
+				panicf("failed to read from c2: %v", err)
+			}
+			if _, ok := m.(*RouterSolicitation); !ok {
+				continue
+			}
+
+			if err := c2.WriteTo(want, nil, addr); err != nil {
+				panicf("failed to write from c2: %v", err)
+			}
+			return
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ra, from, err := WaitForRouter(ctx, c1, nil)
+	if err != nil {
+		t.Fatalf("failed to wait for router: %v", err)
+	}
+
+	wg.Wait()
+
+	if diff := cmp.Diff(want, ra); diff != "" {
+		t.Fatalf("unexpected router advertisement (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(addr, from, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected router address (-want +got):\n%s", diff)
+	}
+}
+
+func TestWaitForRouterPassive(t *testing.T) {
+	c1, c2, addr := testPipeConn(t)
+
+	// Send an unsolicited router advertisement before c1 begins waiting.
+	want := &RouterAdvertisement{
+		CurrentHopLimit: 64,
+		RouterLifetime:  30 * time.Minute,
+	}
+	if err := c2.WriteTo(want, nil, allNodes); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ra, from, err := WaitForRouter(ctx, c1, &RouterWaitConfig{Passive: true})
+	if err != nil {
+		t.Fatalf("failed to wait for router: %v", err)
+	}
+
+	if diff := cmp.Diff(want, ra); diff != "" {
+		t.Fatalf("unexpected router advertisement (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(addr, from, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected router address (-want +got):\n%s", diff)
+	}
+
+	// The all-nodes group is left on return.
+	if groups := c1.Groups(); len(groups) != 0 {
+		t.Fatalf("unexpected groups after wait: %v", groups)
+	}
+
+	// No router solicitations were sent.
+	if m, _, _, err := c2.ReadFromTimeout(100 * time.Millisecond); !isTimeout(err) {
+		t.Fatalf("expected timeout reading from c2, but got message %v: %v", m, err)
+	}
+}
+
+func testConnProbeNeighbor(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	// The first probe has no answer, and the second is answered by c2.
+	target := netip.MustParseAddr("fe80::dead:beef")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := ProbeNeighbor(ctx, c1, target, &ProbeConfig{
+		Count:    2,
+		Interval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to probe neighbor: %v", err)
+	}
+	if res.Answered() || len(res.Sent) != 2 {
+		t.Fatalf("unexpected probe result: %+v", res)
+	}
+
+	// Now act as the target by joining its solicited-node multicast group.
+	snm, err := SolicitedNodeMulticast(target)
+	if err != nil {
+		t.Fatalf("failed to compute solicited-node multicast address: %v", err)
+	}
+	if err := c2.JoinGroup(snm); err != nil {
+		t.Fatalf("failed to join solicited-node multicast group: %v", err)
+	}
+
+	na := &NeighborAdvertisement{
+		Solicited:     true,
+		TargetAddress: target,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			m, _, _, err := c2.ReadFrom()
+			if err != nil {
+				panicf("failed to read from c2: %v", err)
+			}
+			if ns, ok := m.(*NeighborSolicitation); !ok || ns.TargetAddress != target {
+				continue
+			}
+
+			if err := c2.WriteTo(na, nil, addr); err != nil {
+				panicf("failed to write from c2: %v", err)
+			}
+			return
+		}
+	}()
+
+	res, err = ProbeNeighbor(ctx, c1, target, &ProbeConfig{Interval: time.Second})
+	if err != nil {
+		t.Fatalf("failed to probe neighbor: %v", err)
+	}
+
+	wg.Wait()
+
+	if !res.Answered() || res.Elapsed() <= 0 {
+		t.Fatalf("neighbor did not answer: %+v", res)
+	}
+	if diff := cmp.Diff(na, res.Advertisement, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected neighbor advertisement (-want +got):\n%s", diff)
+	}
+}
+
+func testConnICMPErrors(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	// ICMPv6 errors are filtered by default, so only the second error sent is
+	// received after errors are enabled.
+	errs := []*ICMPError{
+		{
+			ErrorType: ipv6.ICMPTypeDestinationUnreachable,
+			Code:      3,
+		},
+		{
+			ErrorType: ipv6.ICMPTypePacketTooBig,
+			Param:     1280,
+		},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	sigC := make(chan struct{})
+	go func() {
+		defer wg.Done()
+
+		if err := c2.WriteTo(errs[0], nil, addr); err != nil {
+			panicf("failed to write from c2: %v", err)
+		}
+
+		// Wait for the consumer to enable errors before the second write.
+		<-sigC
+		if err := c2.WriteTo(errs[1], nil, addr); err != nil {
+			panicf("failed to write from c2: %v", err)
+		}
+	}()
+
+	// Verify the first error is filtered by timing out.
+	if _, _, _, err := c1.ReadFromTimeout(100 * time.Millisecond); !isTimeout(err) {
+		t.Fatalf("expected a timeout, but got: %v", err)
+	}
+
+	c1.SetICMPErrors(true)
+	sigC <- struct{}{}
+
+	m, _, _, err := c1.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	wg.Wait()
+
+	if diff := cmp.Diff(errs[1], m); diff != "" {
+		t.Fatalf("unexpected ICMP error (-want +got):\n%s", diff)
+	}
+}
+
+func testConnRawMessages(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	// Use a type reserved for private experimentation which will not be
+	// answered by the kernel.
+	want := &RawMessage{
+		MessageType: 201,
+		Code:        1,
+		Body:        []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+
+	c1.SetParser(&Parser{RawMessages: true})
+
+	// Unrecognized types are blocked by the default ICMPv6 filter.
+	var f ipv6.ICMPFilter
+	f.SetAll(false)
+	if err := c1.SetICMPFilter(&f); err != nil {
+		t.Fatalf("failed to set ICMP filter: %v", err)
+	}
+
+	if err := c2.WriteTo(want, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	m, _, _, err := c1.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(want, m); diff != "" {
+		t.Fatalf("unexpected raw message (-want +got):\n%s", diff)
+	}
+}
+
+func testConnTap(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	var buf bytes.Buffer
+	if err := c1.Tap(&buf); err != nil {
+		t.Fatalf("failed to tap c1: %v", err)
+	}
+
+	// Capture one sent and one received message, then stop the tap so the
+	// buffer is no longer modified.
+	testConnEcho(t, c1, c2, addr)
+	if err := c1.Tap(nil); err != nil {
+		t.Fatalf("failed to stop tap: %v", err)
+	}
+
+	want, err := MarshalMessage(&RouterSolicitation{})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	// Expect a section header, interface description, and two packets.
+	b := buf.Bytes()
+	var blocks []uint32
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("truncated pcapng block: %x", b)
+		}
+
+		typ := binary.LittleEndian.Uint32(b[0:4])
+		l := int(binary.LittleEndian.Uint32(b[4:8]))
+		if l > len(b) || l%4 != 0 || binary.LittleEndian.Uint32(b[l-4:l]) != uint32(l) {
+			t.Fatalf("malformed pcapng block of type %#x and length %d", typ, l)
+		}
+
+		if typ == pcapngEPB {
+			n := int(binary.LittleEndian.Uint32(b[20:24]))
+			pkt := b[28 : 28+n]
+
+			if pkt[0]>>4 != 6 || pkt[6] != 58 || pkt[7] != HopLimit {
+				t.Fatalf("unexpected IPv6 header: %x", pkt[:ipv6Len])
+			}
+			if src := netip.AddrFrom16([16]byte(pkt[8:24])); src != addr.WithZone("") {
+				t.Fatalf("unexpected source address: %s", src)
+			}
+
+			icmp := pkt[ipv6Len:]
+			if icmp[2] == 0 && icmp[3] == 0 {
+				t.Fatal("ICMPv6 checksum was not set")
+			}
+
+			// Ignore the checksum for comparison.
+			icmp[2], icmp[3] = 0, 0
+			if diff := cmp.Diff(want, icmp); diff != "" {
+				t.Fatalf("unexpected ICMPv6 message (-want +got):\n%s", diff)
+			}
+		}
+
+		blocks = append(blocks, typ)
+		b = b[l:]
+	}
+
+	if diff := cmp.Diff([]uint32{pcapngSHB, pcapngIDB, pcapngEPB, pcapngEPB}, blocks); diff != "" {
+		t.Fatalf("unexpected pcapng blocks (-want +got):\n%s", diff)
+	}
+}
+
+func testConnReadTimeout(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if _, _, _, err := c1.ReadFromTimeout(50 * time.Millisecond); !isTimeout(err) {
+		t.Fatalf("expected a timeout, but got: %v", err)
+	}
+
+	// The deadline must be cleared so that it cannot affect a later read,
+	// even one which begins after the deadline has passed.
+	time.Sleep(100 * time.Millisecond)
+
+	rs := &RouterSolicitation{}
+	if err := c2.WriteTo(rs, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	m, _, _, err := c1.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(rs, m); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+}
+
+func testConnReady(t *testing.T, c1, _ *Conn, _ netip.Addr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c1.Ready(ctx); err != nil {
+		t.Fatalf("failed to wait for ready: %v", err)
+	}
+
+	// An address which is not assigned to the interface can never be used.
+	c := &Conn{
+		ifi:  c1.ifi,
+		addr: netip.MustParseAddr("2001:db8::1"),
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	var nerr *NotReadyError
+	if err := c.Ready(ctx); !errors.As(err, &nerr) {
+		t.Fatalf("expected a not ready error, but got: %v", err)
+	}
+	if nerr.Addr != c.addr {
+		t.Fatalf("unexpected not ready address: %s", nerr.Addr)
+	}
+}
+
+func testConnDuplicates(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	c1.SetDuplicateWindow(5 * time.Second)
+
+	// The second router solicitation is a duplicate and must be filtered, so
+	// the next message read must be the neighbor solicitation.
+	msgs := []Message{
+		&RouterSolicitation{},
+		&RouterSolicitation{},
+		&NeighborSolicitation{TargetAddress: addr.WithZone("")},
+	}
+
+	for _, m := range msgs {
+		if err := c2.WriteTo(m, nil, addr); err != nil {
+			t.Fatalf("failed to write from c2: %v", err)
+		}
+	}
+
+	for i, want := range []Message{msgs[0], msgs[2]} {
+		m, _, _, err := c1.ReadFromTimeout(5 * time.Second)
+		if err != nil {
+			t.Fatalf("failed to read message %d from c1: %v", i, err)
+		}
+
+		if diff := cmp.Diff(want, m); diff != "" {
+			t.Fatalf("unexpected message %d (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func testConnFaults(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if err := c1.SetFaults(&Faults{DropRate: 2}, nil); err == nil {
+		t.Fatal("expected an error for invalid drop rate, but none occurred")
+	}
+
+	// Every packet sent by c2 is dropped.
+	if err := c2.SetFaults(&Faults{DropRate: 1}, nil); err != nil {
+		t.Fatalf("failed to set faults: %v", err)
+	}
+
+	rs := &RouterSolicitation{
+		Options: []Option{&LinkLayerAddress{
+			Direction: Source,
+			Addr:      c2.ifi.HardwareAddr,
+		}},
+	}
+	if err := c2.WriteTo(rs, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	if _, _, _, err := c1.ReadFromTimeout(100 * time.Millisecond); !isTimeout(err) {
+		t.Fatalf("expected a timeout, but got: %v", err)
+	}
+
+	// Every packet received by c1 is truncated and corrupted.
+	if err := c2.SetFaults(nil, nil); err != nil {
+		t.Fatalf("failed to clear faults: %v", err)
+	}
+	if err := c1.SetFaults(nil, &Faults{CorruptRate: 1, TruncateRate: 1, Seed: 1}); err != nil {
+		t.Fatalf("failed to set faults: %v", err)
+	}
+
+	want, err := MarshalMessage(rs)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if err := c2.WriteTo(rs, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	b := make([]byte, 1500)
+	n, _, _, err := c1.ReadRaw(b)
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if n >= len(want) || n < icmpLen {
+		t.Fatalf("unexpected truncated packet length: %d", n)
+	}
+	// Ignore the checksum computed by the kernel, which the seeded source of
+	// randomness does not corrupt.
+	b[2], b[3] = 0, 0
+	if bytes.Equal(want[:n], b[:n]) {
+		t.Fatal("packet was not corrupted")
+	}
+}
+
+func testConnHopLimitCheck(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if err := c1.SetHopLimitCheck(true); err != nil {
+		t.Fatalf("failed to enable hop limit check: %v", err)
+	}
+
+	// The check must continue to work even if the caller attempts to disable
+	// hop limit control messages.
+	if err := c1.SetControlMessage(ipv6.FlagHopLimit, false); err != nil {
+		t.Fatalf("failed to set control message: %v", err)
+	}
+
+	// The first message appears to have been forwarded and must be dropped,
+	// and the second is accepted.
+	forwarded := &ipv6.ControlMessage{
+		HopLimit: 64,
+		Src:      c2.addr.AsSlice(),
+		IfIndex:  c2.ifi.Index,
+	}
+
+	ns := &NeighborSolicitation{TargetAddress: addr.WithZone("")}
+	if err := c2.WriteTo(&RouterSolicitation{}, forwarded, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+	if err := c2.WriteTo(ns, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	m, cm, _, err := c1.ReadFromTimeout(5 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(ns, m); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+	if cm == nil || cm.HopLimit != HopLimit {
+		t.Fatalf("unexpected control message: %v", cm)
+	}
+}
+
+func testConnReadBatch(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if n, err := c1.ReadBatch(nil); n != 0 || err != nil {
+		t.Fatalf("unexpected empty batch result: %d, %v", n, err)
+	}
+
+	// Send distinct messages so none are filtered as duplicates.
+	var want []Message
+	for i := 1; i <= 3; i++ {
+		ns := &NeighborSolicitation{
+			TargetAddress: netip.AddrFrom16([16]byte{0: 0xfe, 1: 0x80, 15: byte(i)}),
+		}
+		want = append(want, ns)
+
+		if err := c2.WriteTo(ns, nil, addr); err != nil {
+			t.Fatalf("failed to write from c2: %v", err)
+		}
+	}
+
+	var (
+		got []Message
+		ms  = make([]MessageBuffer, 2)
+	)
+	for len(got) < len(want) {
+		n, err := c1.ReadBatch(ms)
+		if err != nil {
+			t.Fatalf("failed to read batch from c1: %v", err)
+		}
+
+		for _, m := range ms[:n] {
+			if diff := cmp.Diff(addr, m.From, cmp.Comparer(addrEqual)); diff != "" {
+				t.Fatalf("unexpected source address (-want +got):\n%s", diff)
+			}
+
+			got = append(got, m.Message)
+		}
+	}
+
+	if diff := cmp.Diff(want, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected messages (-want +got):\n%s", diff)
+	}
+}
+
+func testConnWriteBatch(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if _, err := c2.WriteBatch([]OutgoingMessage{{
+		Message: &NeighborSolicitation{TargetAddress: netip.MustParseAddr("192.0.2.1")},
+		Dst:     addr,
+	}}); err == nil {
+		t.Fatal("expected an error for invalid message, but none occurred")
+	}
+
+	// Send distinct messages so none are filtered as duplicates.
+	var (
+		want []Message
+		ms   []OutgoingMessage
+	)
+	for i := 1; i <= 3; i++ {
+		ns := &NeighborSolicitation{
+			TargetAddress: netip.AddrFrom16([16]byte{0: 0xfe, 1: 0x80, 15: byte(i)}),
+		}
+		want = append(want, ns)
+		ms = append(ms, OutgoingMessage{Message: ns, Dst: addr})
+	}
+
+	n, err := c2.WriteBatch(ms)
+	if err != nil {
+		t.Fatalf("failed to write batch from c2: %v", err)
+	}
+	if diff := cmp.Diff(len(ms), n); diff != "" {
+		t.Fatalf("unexpected number of messages written (-want +got):\n%s", diff)
+	}
+
+	var got []Message
+	for len(got) < len(want) {
+		m, _, _, err := c1.ReadFrom()
+		if err != nil {
+			t.Fatalf("failed to read from c1: %v", err)
+		}
+
+		got = append(got, m)
+	}
+
+	if diff := cmp.Diff(want, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected messages (-want +got):\n%s", diff)
+	}
+}
+
+func TestDefaultICMPFilter(t *testing.T) {
+	f := DefaultICMPFilter()
+
+	for _, mt := range MessageTypes() {
+		if f.WillBlock(mt.Type) {
+			t.Fatalf("filter blocks parseable type %s", mt.Type)
+		}
+	}
+
+	for _, typ := range []ipv6.ICMPType{
+		ipv6.ICMPTypeEchoRequest,
+		ipv6.ICMPTypeEchoReply,
+		ipv6.ICMPTypeMulticastListenerReport,
+		ipv6.ICMPTypeVersion2MulticastListenerReport,
+	} {
+		if !f.WillBlock(typ) {
+			t.Fatalf("filter does not block type %s", typ)
+		}
+	}
+}
+
+func testConnContext(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rs := &RouterSolicitation{}
+	if err := c2.WriteToContext(ctx, rs, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	m, _, _, err := c1.ReadFromContext(ctx)
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+	if diff := cmp.Diff(rs, m); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	// Cancel a pending read.
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, _, _, err := c1.ReadFromContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, but got: %v", err)
+	}
+	if err := c2.WriteToContext(ctx, rs, nil, addr); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, but got: %v", err)
+	}
+
+	// The Conn remains usable after cancelation.
+	if err := c2.WriteTo(rs, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+	if _, _, _, err := c1.ReadFromTimeout(time.Second); err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+}
+
+func testConnReadFromBuffer(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	ra := &RouterAdvertisement{
+		CurrentHopLimit: 64,
+		RouterLifetime:  30 * time.Minute,
+		Options:         []Option{NewMTU(1500)},
+	}
+
+	want, err := MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if err := c2.WriteTo(ra, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	b := make([]byte, c1.ifi.MTU)
+	n, m, _, _, err := c1.ReadFromBuffer(b)
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(ra, m); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	// Ignore the checksum computed by the kernel.
+	b[2], b[3] = 0, 0
+	if diff := cmp.Diff(want, b[:n]); diff != "" {
+		t.Fatalf("unexpected message bytes (-want +got):\n%s", diff)
+	}
+}
+
+func testConnMalformedMessages(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	c1.SetMalformedMessages(true)
+
+	// A Neighbor Advertisement which is too short to be parsed.
+	if err := c2.writeRaw([]byte{136, 0, 0, 0, 0xde, 0xad}, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	m, _, _, err := c1.ReadFromTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	rm, ok := m.(*RawMessage)
+	if !ok {
+		t.Fatalf("expected *RawMessage, but got: %#v", m)
+	}
+	if !errors.Is(rm.Err, errParseMessage) {
+		t.Fatalf("expected a parsing error, but got: %v", rm.Err)
+	}
+
+	want := &RawMessage{
+		MessageType: ipv6.ICMPTypeNeighborAdvertisement,
+		Body:        []byte{0xde, 0xad},
+	}
+	if diff := cmp.Diff(want, rm); diff != "" {
+		t.Fatalf("unexpected raw message (-want +got):\n%s", diff)
+	}
+}
+
+func testConnGroups(t *testing.T, c1, _ *Conn, _ netip.Addr) {
+	var (
+		// Both addresses share a solicited-node multicast group.
+		ip1 = netip.MustParseAddr("fe80::1")
+		ip2 = netip.MustParseAddr("2001:db8::1")
+
+		snm      = netip.MustParseAddr("ff02::1:ff00:1")
+		allNodes = netip.MustParseAddr("ff02::1")
+	)
+
+	for _, ip := range []netip.Addr{ip1, ip2} {
+		if err := c1.JoinSolicitedNodeMulticast(ip); err != nil {
+			t.Fatalf("failed to join solicited-node multicast group: %v", err)
+		}
+	}
+	if err := c1.JoinGroup(allNodes); err != nil {
+		t.Fatalf("failed to join all-nodes group: %v", err)
+	}
+
+	if diff := cmp.Diff([]netip.Addr{allNodes, snm}, c1.Groups(), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected groups (-want +got):\n%s", diff)
+	}
+
+	// The group remains joined until every address leaves it.
+	if err := c1.LeaveSolicitedNodeMulticast(ip1); err != nil {
+		t.Fatalf("failed to leave solicited-node multicast group: %v", err)
+	}
+	if diff := cmp.Diff([]netip.Addr{allNodes, snm}, c1.Groups(), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected groups (-want +got):\n%s", diff)
+	}
+
+	if err := c1.LeaveSolicitedNodeMulticast(ip2); err != nil {
+		t.Fatalf("failed to leave solicited-node multicast group: %v", err)
+	}
+	if diff := cmp.Diff([]netip.Addr{allNodes}, c1.Groups(), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected groups (-want +got):\n%s", diff)
+	}
+
+	if err := c1.JoinSolicitedNodeMulticast(netip.MustParseAddr("192.0.2.1")); err == nil {
+		t.Fatal("expected an error for IPv4 address, but none occurred")
+	}
+
+	// Closing the Conn leaves all groups.
+	if err := c1.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if diff := cmp.Diff(0, len(c1.Groups())); diff != "" {
+		t.Fatalf("unexpected number of groups (-want +got):\n%s", diff)
+	}
+}
+
+func testConnTrafficClass(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if err := c2.SetTrafficClass(256); err == nil {
+		t.Fatal("expected an error for invalid traffic class, but none occurred")
+	}
+
+	// Mark messages with DSCP CS6.
+	const cs6 = 0xc0
+	if err := c2.SetTrafficClass(cs6); err != nil {
+		t.Fatalf("failed to set traffic class: %v", err)
+	}
+	if err := c1.SetControlMessage(ipv6.FlagTrafficClass, true); err != nil {
+		t.Fatalf("failed to set control message: %v", err)
+	}
+
+	if err := c2.WriteTo(&RouterSolicitation{}, nil, addr); err != nil {
+		t.Fatalf("failed to write from c2: %v", err)
+	}
+
+	_, cm, _, err := c1.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(cs6, cm.TrafficClass); diff != "" {
+		t.Fatalf("unexpected traffic class (-want +got):\n%s", diff)
+	}
+}
+
+func testConnBPF(t *testing.T, c1, c2 *Conn, addr netip.Addr) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping, BPF programs are only supported on Linux")
+	}
+
+	// Accept only Neighbor Advertisements.
+	prog, err := bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 0, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(ipv6.ICMPTypeNeighborAdvertisement), SkipFalse: 1},
+		bpf.RetConstant{Val: 0xffff},
+		bpf.RetConstant{Val: 0},
+	})
+	if err != nil {
+		t.Fatalf("failed to assemble BPF program: %v", err)
+	}
+
+	if err := c1.SetBPF(prog); err != nil {
+		t.Fatalf("failed to set BPF program: %v", err)
+	}
+
+	target := netip.MustParseAddr("fe80::1")
+	na := &NeighborAdvertisement{TargetAddress: target}
+	for _, m := range []Message{&NeighborSolicitation{TargetAddress: target}, na} {
+		if err := c2.WriteTo(m, nil, addr); err != nil {
+			t.Fatalf("failed to write from c2: %v", err)
+		}
+	}
+
+	m, _, _, err := c1.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read from c1: %v", err)
+	}
+
+	if diff := cmp.Diff(na, m, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+}
+
+func TestListenConfigBindToDevice(t *testing.T) {
+	var (
+		ifi = testInterface(t)
+		lc  = &ListenConfig{BindToDevice: true}
+	)
+
+	c1, addr, err := lc.Listen(ifi, LinkLocal)
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			t.Fatal("expected an error on non-Linux platform, but none occurred")
+		}
+
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("failed to listen: %v", err)
+		}
+
+		t.Skipf("skipping, permission denied, cannot bind to device: %v", err)
+	}
+	defer c1.Close()
+	c1.icmpTest = true
+
+	c2, _ := icmpConn(t, ifi)
+	defer c2.Close()
+
+	testConnEcho(t, c1, c2, addr)
+}
+
+func TestConnSyscallConn(t *testing.T) {
+	c1, _, _ := testICMPConn(t)
+
+	if _, ok := c1.PacketConn().(*ipv6.PacketConn); !ok {
+		t.Fatalf("unexpected PacketConn type: %T", c1.PacketConn())
+	}
+
+	rc, err := c1.SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get syscall conn: %v", err)
+	}
+
+	var called bool
+	if err := rc.Control(func(_ uintptr) { called = true }); err != nil {
+		t.Fatalf("failed to control socket: %v", err)
+	}
+	if !called {
+		t.Fatal("control function was not called")
+	}
+
+	p1, _ := Pipe()
+	c2, err := NewConn(p1, &net.Interface{Index: 1, Name: "ndp0", MTU: 1500}, netip.MustParseAddr("fe80::1"))
+	if err != nil {
+		t.Fatalf("failed to create conn: %v", err)
+	}
+	defer c2.Close()
+
+	if c2.PacketConn() != p1 {
+		t.Fatal("unexpected PacketConn for pipe")
+	}
+	if _, err := c2.SyscallConn(); err == nil {
+		t.Fatal("expected an error for pipe, but none occurred")
+	}
+}
+
+func Test_ethernetFrame(t *testing.T) {
+	var (
+		dstMAC = multicastHardwareAddr(netip.MustParseAddr("ff02::1:ff00:2"))
+		srcMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+		src    = netip.MustParseAddr("fe80::1")
+		dst    = netip.MustParseAddr("ff02::1:ff00:2")
+	)
+
+	if diff := cmp.Diff(net.HardwareAddr{0x33, 0x33, 0xff, 0x00, 0x00, 0x02}, dstMAC); diff != "" {
+		t.Fatalf("unexpected multicast hardware address (-want +got):\n%s", diff)
+	}
+
+	b, err := MarshalMessage(&NeighborSolicitation{
+		TargetAddress: netip.MustParseAddr("fe80::2"),
+		Options:       []Option{&LinkLayerAddress{Direction: Source, Addr: srcMAC}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	f, err := appendEthernetFrame(nil, dstMAC, srcMAC, src, dst, &ipv6.ControlMessage{TrafficClass: 0xc0}, b)
+	if err != nil {
+		t.Fatalf("failed to build frame: %v", err)
+	}
+
+	p, ok := parseEthernetFrame(f)
+	if !ok {
+		t.Fatal("failed to parse frame")
+	}
+
+	// The checksum was placed in the frame.
+	if p.b[2] == 0 && p.b[3] == 0 {
+		t.Fatal("checksum was not computed")
+	}
+	want := append([]byte(nil), b...)
+	want[2], want[3] = p.b[2], p.b[3]
+
+	if diff := cmp.Diff(ethernetPacket{
+		src:      src,
+		dst:      dst,
+		hopLimit: HopLimit,
+		tclass:   0xc0,
+		b:        want,
+	}, p, cmp.AllowUnexported(ethernetPacket{}), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected packet (-want +got):\n%s", diff)
+	}
+
+	// Corrupt the message to invalidate its checksum.
+	f[len(f)-1] ^= 0xff
+	if _, ok := parseEthernetFrame(f); ok {
+		t.Fatal("parsed frame with invalid checksum")
+	}
+
+	// A frame which is not IPv6 is ignored.
+	f[len(f)-1] ^= 0xff
+	f[12], f[13] = 0x08, 0x00
+	if _, ok := parseEthernetFrame(f); ok {
+		t.Fatal("parsed frame which is not IPv6")
+	}
+
+	if _, err := (&EthernetConfig{}).destinationHardwareAddr(src); err == nil {
+		t.Fatal("expected an error for unresolved unicast destination, but none occurred")
+	}
+}
+
+func TestListenEthernet(t *testing.T) {
+	ifi := testInterface(t)
+
+	pc, err := ListenEthernet(ifi, nil)
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			t.Fatal("expected an error on non-Linux platform, but none occurred")
+		}
+
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("failed to listen: %v", err)
+		}
+
+		t.Skipf("skipping, permission denied, cannot open Ethernet socket: %v", err)
+	}
+
+	// Use an address which is not assigned to the interface.
+	c, err := NewConn(pc, ifi, netip.MustParseAddr("fe80::ffff:1"))
+	if err != nil {
+		t.Fatalf("failed to create conn: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.JoinGroup(netip.MustParseAddr("ff02::1")); err != nil {
+		t.Fatalf("failed to join group: %v", err)
+	}
+	if err := c.WriteTo(&RouterSolicitation{}, nil, netip.MustParseAddr("ff02::2")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := c.WriteTo(&RouterSolicitation{}, nil, netip.MustParseAddr("fe80::1")); err == nil {
+		t.Fatal("expected an error for unresolved unicast destination, but none occurred")
+	}
+	if err := c.LeaveGroup(netip.MustParseAddr("ff02::1")); err != nil {
+		t.Fatalf("failed to leave group: %v", err)
+	}
+}
+
+func TestNewConn(t *testing.T) {
+	uc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("skipping, failed to create IPv6 socket: %v", err)
+	}
+	defer uc.Close()
+
+	var (
+		pc   = ipv6.NewPacketConn(uc)
+		ifi  = &net.Interface{Index: 1, Name: "lo"}
+		addr = netip.MustParseAddr("::1")
+	)
+
+	tests := []struct {
+		name string
+		pc   PacketConn
+		ifi  *net.Interface
+		addr netip.Addr
+	}{
+		{
+			name: "nil PacketConn",
+			ifi:  ifi,
+			addr: addr,
+		},
+		{
+			name: "nil interface",
+			pc:   pc,
+			addr: addr,
+		},
+		{
+			name: "zero address",
+			pc:   pc,
+			ifi:  ifi,
+		},
+		{
+			name: "IPv4 address",
+			pc:   pc,
+			ifi:  ifi,
+			addr: netip.MustParseAddr("127.0.0.1"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewConn(tt.pc, tt.ifi, tt.addr); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+
+	t.Run("echo", func(t *testing.T) {
+		// Wrap a caller-created socket and communicate with a Conn created by
+		// Listen.
+		c1, c2, addr := testICMPConn(t)
+		_ = c1.Close()
+
+		ic, err := icmp.ListenPacket("ip6:ipv6-icmp", addr.String())
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+
+		c, err := NewConn(ic.IPv6PacketConn(), c2.ifi, addr)
+		if err != nil {
+			t.Fatalf("failed to create conn: %v", err)
+		}
+		defer c.Close()
+		c.icmpTest = true
+
+		testConnEcho(t, c, c2, addr)
+	})
+}
+
+func TestListenConfigNewConn(t *testing.T) {
+	var (
+		ifi  = &net.Interface{Index: 1, Name: "ndp0", MTU: 1500}
+		addr = netip.MustParseAddr("fe80::1")
+	)
+
+	for _, hl := range []int{-1, 256} {
+		p, _ := Pipe()
+		lc := &ListenConfig{HopLimit: hl}
+		if _, err := lc.NewConn(p, ifi, addr); err == nil {
+			t.Fatalf("expected an error for hop limit %d, but none occurred", hl)
+		}
+	}
+
+	p1, p2 := Pipe()
+	lc := &ListenConfig{
+		HopLimit:   64,
+		ICMPFilter: &ipv6.ICMPFilter{},
+	}
+
+	c1, err := lc.NewConn(p1, ifi, addr)
+	if err != nil {
+		t.Fatalf("failed to create c1: %v", err)
+	}
+	defer c1.Close()
+
+	c2, err := NewConn(p2, ifi, addr)
+	if err != nil {
+		t.Fatalf("failed to create c2: %v", err)
+	}
+	defer c2.Close()
+	c2.icmpTest = true
+
+	if err := c2.SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+		t.Fatalf("failed to set control message: %v", err)
+	}
+
+	if err := c1.WriteTo(&RouterSolicitation{}, nil, addr); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	_, cm, _, err := c2.ReadFrom()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	if diff := cmp.Diff(64, cm.HopLimit); diff != "" {
+		t.Fatalf("unexpected hop limit (-want +got):\n%s", diff)
+	}
+}
+
+func TestConnControlMessagePolicy(t *testing.T) {
+	c1, c2, addr := testPipeConn(t)
+
+	if err := c1.SetControlMessagePolicy(ControlMessagePolicy(-1)); err == nil {
+		t.Fatal("expected an error for invalid policy, but none occurred")
+	}
+	if err := c2.SetControlMessage(ipv6.FlagHopLimit|ipv6.FlagInterface, true); err != nil {
+		t.Fatalf("failed to set control message: %v", err)
+	}
+
+	var (
+		unspecified = netip.IPv6Unspecified().WithZone(c2.ifi.Name)
+		other       = netip.MustParseAddr("fe80::2")
+	)
+
+	tests := []struct {
+		name   string
+		policy ControlMessagePolicy
+		src    netip.Addr
+		from   netip.Addr
+		cm     *ipv6.ControlMessage
+	}{
+		{
+			name:   "source",
+			policy: ControlMessageSource,
+			from:   addr,
+			cm:     &ipv6.ControlMessage{HopLimit: HopLimit, IfIndex: 1},
+		},
+		{
+			name:   "source override",
+			policy: ControlMessageSource,
+			src:    other,
+			from:   other.WithZone(c2.ifi.Name),
+			cm:     &ipv6.ControlMessage{HopLimit: HopLimit, IfIndex: 1},
+		},
+		{
+			name:   "interface",
+			policy: ControlMessageInterface,
+			from:   unspecified,
+			cm:     &ipv6.ControlMessage{HopLimit: HopLimit, IfIndex: 1},
+		},
+		{
+			name:   "interface override",
+			policy: ControlMessageInterface,
+			src:    other,
+			from:   other.WithZone(c2.ifi.Name),
+			cm:     &ipv6.ControlMessage{HopLimit: HopLimit, IfIndex: 1},
+		},
+		{
+			name:   "none",
+			policy: ControlMessageNone,
+			from:   unspecified,
+			cm:     &ipv6.ControlMessage{},
+		},
+		{
+			name:   "none override",
+			policy: ControlMessageNone,
+			src:    other,
+			from:   other.WithZone(c2.ifi.Name),
+			cm:     &ipv6.ControlMessage{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c1.SetControlMessagePolicy(tt.policy); err != nil {
+				t.Fatalf("failed to set policy: %v", err)
+			}
+
+			var (
+				rs  = &RouterSolicitation{}
+				err error
+			)
+			if tt.src.IsValid() {
+				err = c1.WriteToFrom(rs, tt.src, addr)
+			} else {
+				err = c1.WriteTo(rs, nil, addr)
+			}
+			if err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+
+			_, cm, from, err := c2.ReadFrom()
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.from, from, cmp.Comparer(addrEqual)); diff != "" {
+				t.Fatalf("unexpected source address (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.cm, cm); diff != "" {
+				t.Fatalf("unexpected control message (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if err := c1.WriteToFrom(&RouterSolicitation{}, netip.MustParseAddr("192.0.2.1"), addr); err == nil {
+		t.Fatal("expected an error for IPv4 source, but none occurred")
+	}
+}
+
+func TestMultiConn(t *testing.T) {
+	if _, err := NewMultiConn(); err == nil {
+		t.Fatal("expected an error for no Conns, but none occurred")
+	}
+
+	var (
+		addr = netip.MustParseAddr("fe80::1")
+		ifis = []*net.Interface{
+			{Index: 2, Name: "ndp1", MTU: 1500},
+			{Index: 1, Name: "ndp0", MTU: 1500},
+		}
+	)
+
+	// Create a pipe for each interface, keeping one end of each to send
+	// messages to the MultiConn.
+	var conns, peers []*Conn
+	for _, ifi := range ifis {
+		p1, p2 := Pipe()
+		c1, err := NewConn(p1, ifi, addr)
+		if err != nil {
+			t.Fatalf("failed to create c1: %v", err)
+		}
+		c2, err := NewConn(p2, ifi, addr)
+		if err != nil {
+			t.Fatalf("failed to create c2: %v", err)
+		}
+		c1.icmpTest = true
+		defer c2.Close()
+
+		conns = append(conns, c1)
+		peers = append(peers, c2)
+	}
+
+	if _, err := NewMultiConn(conns[0], conns[0]); err == nil {
+		t.Fatal("expected an error for duplicate interfaces, but none occurred")
+	}
+
+	mc, err := NewMultiConn(conns...)
+	if err != nil {
+		t.Fatalf("failed to create MultiConn: %v", err)
+	}
+	defer mc.Close()
+
+	var names []string
+	for _, ifi := range mc.Interfaces() {
+		names = append(names, ifi.Name)
+	}
+	if diff := cmp.Diff([]string{"ndp0", "ndp1"}, names); diff != "" {
+		t.Fatalf("unexpected interfaces (-want +got):\n%s", diff)
+	}
+
+	if c, ok := mc.Conn("ndp1"); !ok || c != conns[0] {
+		t.Fatalf("unexpected Conn for ndp1: %p, %v", c, ok)
+	}
+	if _, ok := mc.Conn("ndp2"); ok {
+		t.Fatal("unexpected Conn for ndp2")
+	}
+
+	if err := mc.JoinSolicitedNodeMulticast(); err != nil {
+		t.Fatalf("failed to join solicited-node multicast group: %v", err)
+	}
+	snm, err := SolicitedNodeMulticast(addr)
+	if err != nil {
+		t.Fatalf("failed to compute solicited-node multicast group: %v", err)
+	}
+	if err := mc.JoinGroup(allNodes); err != nil {
+		t.Fatalf("failed to join group: %v", err)
+	}
+
+	want := []netip.Addr{allNodes, snm}
+	for _, c := range conns {
+		if diff := cmp.Diff(want, c.Groups(), cmp.Comparer(addrEqual)); diff != "" {
+			t.Fatalf("unexpected groups for %q (-want +got):\n%s", c.ifi.Name, diff)
+		}
+	}
+
+	if err := mc.LeaveGroup(allNodes); err != nil {
+		t.Fatalf("failed to leave group: %v", err)
+	}
+	for _, c := range conns {
+		if diff := cmp.Diff([]netip.Addr{snm}, c.Groups(), cmp.Comparer(addrEqual)); diff != "" {
+			t.Fatalf("unexpected groups for %q (-want +got):\n%s", c.ifi.Name, diff)
+		}
+	}
+
+	// Send a distinct message on each interface and verify each is annotated
+	// with the interface which received it.
+	got := make(map[string]Message)
+	for i, c := range peers {
+		m := &NeighborSolicitation{TargetAddress: netip.AddrFrom16([16]byte{15: byte(i + 1)})}
+		if err := c.WriteTo(m, nil, allNodes); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	for range peers {
+		select {
+		case im := <-mc.Messages():
+			if im.Err != nil {
+				t.Fatalf("failed to read: %v", im.Err)
+			}
+			if im.From != addr.WithZone(im.Interface.Name) {
+				t.Fatalf("unexpected source address: %v", im.From)
+			}
+
+			got[im.Interface.Name] = im.Message
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	wantMsgs := map[string]Message{
+		"ndp1": &NeighborSolicitation{TargetAddress: netip.MustParseAddr("::1")},
+		"ndp0": &NeighborSolicitation{TargetAddress: netip.MustParseAddr("::2")},
+	}
+	if diff := cmp.Diff(wantMsgs, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected messages (-want +got):\n%s", diff)
+	}
+
+	if err := mc.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, ok := <-mc.Messages(); ok {
+		t.Fatal("expected messages channel to be closed")
+	}
+	for _, c := range conns {
+		if groups := c.Groups(); len(groups) != 0 {
+			t.Fatalf("unexpected groups after close: %v", groups)
+		}
+	}
+}
+
+func TestListenInterfaces(t *testing.T) {
+	if _, err := ListenInterfaces("[", LinkLocal); err == nil {
+		t.Fatal("expected an error for invalid pattern, but none occurred")
+	}
+	if _, err := ListenInterfaces("ndp-nonexistent*", LinkLocal); err == nil {
+		t.Fatal("expected an error for no matching interfaces, but none occurred")
+	}
+
+	ifi := testInterface(t)
+
+	mc, err := ListenInterfaces(ifi.Name, LinkLocal)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			t.Skipf("skipping, permission denied: %v", err)
+		}
+
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer mc.Close()
+
+	ifis := mc.Interfaces()
+	if len(ifis) != 1 || ifis[0].Name != ifi.Name {
+		t.Fatalf("unexpected interfaces: %v", ifis)
+	}
+
+	if err := mc.JoinGroup(allNodes); err != nil {
+		t.Fatalf("failed to join group: %v", err)
+	}
+	if err := mc.JoinSolicitedNodeMulticast(); err != nil {
+		t.Fatalf("failed to join solicited-node multicast group: %v", err)
+	}
+
+	if err := mc.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, ok := <-mc.Messages(); ok {
+		t.Fatal("expected messages channel to be closed")
+	}
+}
+
+func Test_dedup(t *testing.T) {
+	var (
+		d   dedup
+		now = time.Unix(0, 0)
+		a1  = netip.MustParseAddr("fe80::1")
+		a2  = netip.MustParseAddr("fe80::2")
+		b   = []byte{133, 0, 0, 0}
+	)
+
+	if d.duplicate(now, a1, b) || d.duplicate(now, a1, b) {
+		t.Fatal("duplicate detected while disabled")
+	}
+
+	d.setWindow(time.Second)
+
+	tests := []struct {
+		offset time.Duration
+		addr   netip.Addr
+		b      []byte
+		dup    bool
+	}{
+		{offset: 0, addr: a1, b: b},
+		{offset: 100 * time.Millisecond, addr: a1, b: b, dup: true},
+		{offset: 200 * time.Millisecond, addr: a2, b: b},
+		{offset: 300 * time.Millisecond, addr: a1, b: []byte{134, 0, 0, 0}},
+		{offset: 999 * time.Millisecond, addr: a1, b: b, dup: true},
+		{offset: time.Second, addr: a1, b: b},
+		{offset: 1500 * time.Millisecond, addr: a1, b: b, dup: true},
+		{offset: 3 * time.Second, addr: a1, b: b},
+	}
+
+	for i, tt := range tests {
+		if got := d.duplicate(now.Add(tt.offset), tt.addr, tt.b); got != tt.dup {
+			t.Fatalf("%d: unexpected duplicate: want %v, got %v", i, tt.dup, got)
+		}
+	}
+
+	// Only the most recent packet remains after pruning.
+	if diff := cmp.Diff(1, len(d.seen)); diff != "" {
+		t.Fatalf("unexpected number of seen packets (-want +got):\n%s", diff)
+	}
+}
+
 func TestSolicitedNodeMulticast(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,72 @@
+package ndp
+
+import (
+	"hash/maphash"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// A dedup detects duplicate packets received within a window of time.
+type dedup struct {
+	mu        sync.Mutex
+	window    time.Duration
+	seed      maphash.Seed
+	seen      map[uint64]time.Time
+	lastPrune time.Time
+}
+
+// setWindow sets the deduplication window, disabling deduplication if d is
+// zero or negative.
+func (d *dedup) setWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if window <= 0 {
+		d.window = 0
+		d.seen = nil
+		return
+	}
+
+	if d.seen == nil {
+		d.seed = maphash.MakeSeed()
+		d.seen = make(map[uint64]time.Time)
+	}
+	d.window = window
+}
+
+// duplicate reports whether the packet b from src was already seen within
+// the window before now, and records it otherwise.
+func (d *dedup) duplicate(now time.Time, src netip.Addr, b []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.window == 0 {
+		return false
+	}
+
+	// Periodically forget packets which are outside of the window so the
+	// set does not grow without bound.
+	if now.Sub(d.lastPrune) >= d.window {
+		for k, t := range d.seen {
+			if now.Sub(t) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	var h maphash.Hash
+	h.SetSeed(d.seed)
+	a := src.As16()
+	_, _ = h.Write(a[:])
+	_, _ = h.Write(b)
+	k := h.Sum64()
+
+	if t, ok := d.seen[k]; ok && now.Sub(t) < d.window {
+		return true
+	}
+
+	d.seen[k] = now
+	return false
+}
@@ -1,5 +1,10 @@
 // Package ndp implements the Neighbor Discovery Protocol, as described in
 // RFC 4861.
+//
+// The message and option types in this package do not depend on operating
+// system socket support, and can be used on any platform, including
+// GOOS=js and GOOS=wasip1. Conn is only functional on platforms which
+// support raw ICMPv6 sockets; elsewhere, Listen returns an error.
 package ndp
 
 //go:generate stringer -type=Preference -output=string.go
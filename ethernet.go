@@ -0,0 +1,190 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// An EthernetConfig configures a PacketConn created by ListenEthernet. The
+// zero value is valid.
+type EthernetConfig struct {
+	// Source is the source hardware address of outgoing frames, which need
+	// not be the hardware address of the interface. If nil, the hardware
+	// address of the interface is used.
+	Source net.HardwareAddr
+
+	// Resolve returns the destination hardware address of a frame sent to the
+	// unicast IPv6 address dst. Frames sent to multicast addresses use the
+	// mapping of RFC 2464, Section 7. If nil, writes to unicast destinations
+	// return an error.
+	Resolve func(dst netip.Addr) (net.HardwareAddr, error)
+}
+
+// Header lengths and constants for Ethernet frames carrying ICMPv6.
+const (
+	ethernetLen      = 14
+	ipv6HeaderLen    = 40
+	etherTypeIPv6    = 0x86dd
+	ipv6Version      = 6
+	ethernetAddrLen  = 6
+	ethernetOverhead = ethernetLen + ipv6HeaderLen
+)
+
+// errNoSource is returned when an Ethernet frame is written without a source
+// IPv6 address.
+var errNoSource = errors.New("ndp: source IPv6 address required in control message")
+
+// multicastHardwareAddr returns the Ethernet multicast address for the IPv6
+// multicast address ip, as described in RFC 2464, Section 7.
+func multicastHardwareAddr(ip netip.Addr) net.HardwareAddr {
+	b := ip.As16()
+	return net.HardwareAddr{0x33, 0x33, b[12], b[13], b[14], b[15]}
+}
+
+// destinationHardwareAddr returns the destination hardware address for a frame
+// sent to dst.
+func (cfg *EthernetConfig) destinationHardwareAddr(dst netip.Addr) (net.HardwareAddr, error) {
+	if dst.IsMulticast() {
+		return multicastHardwareAddr(dst), nil
+	}
+
+	if cfg.Resolve == nil {
+		return nil, fmt.Errorf("ndp: cannot resolve hardware address for unicast destination %s", dst)
+	}
+
+	mac, err := cfg.Resolve(dst)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != ethernetAddrLen {
+		return nil, fmt.Errorf("ndp: invalid hardware address for %s: %q", dst, mac)
+	}
+
+	return mac, nil
+}
+
+// appendEthernetFrame appends an Ethernet frame carrying the ICMPv6 message b
+// to dst. The IPv6 header is built from src, ip, and cm, and the ICMPv6
+// checksum is computed and placed in the frame.
+func appendEthernetFrame(dst []byte, dstMAC, srcMAC net.HardwareAddr, src, ip netip.Addr, cm *ipv6.ControlMessage, b []byte) ([]byte, error) {
+	if len(b) < icmpLen {
+		return nil, fmt.Errorf("ndp: ICMPv6 message too short: %d bytes", len(b))
+	}
+
+	hopLimit, tclass := HopLimit, 0
+	if cm != nil {
+		if cm.HopLimit != 0 {
+			hopLimit = cm.HopLimit
+		}
+		tclass = cm.TrafficClass
+	}
+
+	// The checksum covers a pseudo header of the addresses, so it must be
+	// computed here rather than by the operating system.
+	im := icmp.Message{
+		Type: ipv6.ICMPType(b[0]),
+		Code: int(b[1]),
+		Body: &icmp.RawBody{Data: b[icmpLen:]},
+	}
+	msg, err := im.Marshal(icmp.IPv6PseudoHeader(src.AsSlice(), ip.AsSlice()))
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, dstMAC...)
+	dst = append(dst, srcMAC...)
+	dst = binary.BigEndian.AppendUint16(dst, etherTypeIPv6)
+
+	// IPv6 header: version, traffic class, and a zero flow label.
+	dst = binary.BigEndian.AppendUint32(dst, ipv6Version<<28|uint32(tclass)<<20)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(msg)))
+	dst = append(dst, protocolICMPv6, byte(hopLimit))
+
+	s16, d16 := src.As16(), ip.As16()
+	dst = append(dst, s16[:]...)
+	dst = append(dst, d16[:]...)
+
+	return append(dst, msg...), nil
+}
+
+// An ethernetPacket is an ICMPv6 message parsed from an Ethernet frame.
+type ethernetPacket struct {
+	src, dst         netip.Addr
+	hopLimit, tclass int
+	b                []byte
+}
+
+// parseEthernetFrame parses an ICMPv6 message from the Ethernet frame f. It
+// reports false if f does not carry an ICMPv6 message directly following the
+// IPv6 header, or if the ICMPv6 checksum is invalid.
+func parseEthernetFrame(f []byte) (ethernetPacket, bool) {
+	if len(f) < ethernetOverhead+icmpLen ||
+		binary.BigEndian.Uint16(f[12:14]) != etherTypeIPv6 {
+		return ethernetPacket{}, false
+	}
+
+	h := f[ethernetLen:]
+	if h[0]>>4 != ipv6Version || h[6] != protocolICMPv6 {
+		return ethernetPacket{}, false
+	}
+
+	n := int(binary.BigEndian.Uint16(h[4:6]))
+	if n < icmpLen || n > len(h)-ipv6HeaderLen {
+		return ethernetPacket{}, false
+	}
+
+	p := ethernetPacket{
+		src:      netip.AddrFrom16([16]byte(h[8:24])),
+		dst:      netip.AddrFrom16([16]byte(h[24:40])),
+		hopLimit: int(h[7]),
+		tclass:   int(binary.BigEndian.Uint32(h[0:4]) >> 20 & 0xff),
+		b:        h[ipv6HeaderLen : ipv6HeaderLen+n],
+	}
+
+	// Verify the checksum by recomputing it over the message with a zero
+	// checksum field.
+	im := icmp.Message{
+		Type: ipv6.ICMPType(p.b[0]),
+		Code: int(p.b[1]),
+		Body: &icmp.RawBody{Data: p.b[icmpLen:]},
+	}
+	msg, err := im.Marshal(icmp.IPv6PseudoHeader(p.src.AsSlice(), p.dst.AsSlice()))
+	if err != nil || msg[2] != p.b[2] || msg[3] != p.b[3] {
+		return ethernetPacket{}, false
+	}
+
+	return p, true
+}
+
+// controlMessage returns a control message for p populated according to
+// flags, or nil if no flags are set.
+func (p *ethernetPacket) controlMessage(flags ipv6.ControlFlags, ifIndex int) *ipv6.ControlMessage {
+	if flags == 0 {
+		return nil
+	}
+
+	cm := &ipv6.ControlMessage{}
+	if flags&ipv6.FlagTrafficClass != 0 {
+		cm.TrafficClass = p.tclass
+	}
+	if flags&ipv6.FlagHopLimit != 0 {
+		cm.HopLimit = p.hopLimit
+	}
+	if flags&ipv6.FlagSrc != 0 {
+		cm.Src = p.src.AsSlice()
+	}
+	if flags&ipv6.FlagDst != 0 {
+		cm.Dst = p.dst.AsSlice()
+	}
+	if flags&ipv6.FlagInterface != 0 {
+		cm.IfIndex = ifIndex
+	}
+
+	return cm
+}
@@ -0,0 +1,301 @@
+//go:build linux
+
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/ipv6"
+)
+
+// ListenEthernet creates a PacketConn which sends and receives Ethernet
+// frames on ifi using an AF_PACKET socket, building the Ethernet and IPv6
+// headers of each packet itself. Pass the PacketConn to NewConn with any IPv6
+// address to create a Conn.
+//
+// Unlike Listen, ListenEthernet can send messages with arbitrary source
+// hardware and IPv6 addresses, as set by cfg and the Src field of each control
+// message, and can receive messages on interfaces which have no IPv6 address
+// configured. Frames sent by the host are not received. Only ICMPv6 messages
+// which directly follow the IPv6 header and have a valid checksum are
+// received.
+//
+// ListenEthernet is only supported on Linux, and requires the CAP_NET_RAW
+// capability. If cfg is nil, a default configuration is used.
+func ListenEthernet(ifi *net.Interface, cfg *EthernetConfig) (PacketConn, error) {
+	if ifi == nil {
+		return nil, fmt.Errorf("ndp: nil network interface")
+	}
+	if cfg == nil {
+		cfg = &EthernetConfig{}
+	}
+
+	src := cfg.Source
+	if src == nil {
+		src = ifi.HardwareAddr
+	}
+	if len(src) != ethernetAddrLen {
+		return nil, fmt.Errorf("ndp: invalid source hardware address: %q", src)
+	}
+
+	proto := htons(etherTypeIPv6)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, int(proto))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  ifi.Index,
+	}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "ndp-packet")
+	rc, err := f.SyscallConn()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &ethernetConn{
+		f:   f,
+		rc:  rc,
+		ifi: ifi,
+		cfg: *cfg,
+		src: src,
+	}, nil
+}
+
+var _ PacketConn = &ethernetConn{}
+
+// An ethernetConn is a PacketConn over an AF_PACKET socket.
+type ethernetConn struct {
+	f   *os.File
+	rc  syscall.RawConn
+	ifi *net.Interface
+	cfg EthernetConfig
+	src net.HardwareAddr
+
+	mu     sync.Mutex
+	flags  ipv6.ControlFlags
+	filter *ipv6.ICMPFilter
+}
+
+// ReadFrom implements PacketConn.
+func (c *ethernetConn) ReadFrom(b []byte) (int, *ipv6.ControlMessage, net.Addr, error) {
+	f := make([]byte, len(b)+ethernetOverhead)
+	for {
+		var (
+			n    int
+			from syscall.Sockaddr
+			rerr error
+		)
+		if err := c.rc.Read(func(fd uintptr) bool {
+			n, from, rerr = syscall.Recvfrom(int(fd), f, 0)
+			return rerr != syscall.EAGAIN
+		}); err != nil {
+			return 0, nil, nil, err
+		}
+		if rerr != nil {
+			return 0, nil, nil, os.NewSyscallError("recvfrom", rerr)
+		}
+
+		// Ignore frames sent by this host.
+		if sll, ok := from.(*syscall.SockaddrLinklayer); ok && sll.Pkttype == syscall.PACKET_OUTGOING {
+			continue
+		}
+
+		p, ok := parseEthernetFrame(f[:n])
+		if !ok {
+			continue
+		}
+
+		c.mu.Lock()
+		flags, filter := c.flags, c.filter
+		c.mu.Unlock()
+
+		if filter != nil && filter.WillBlock(ipv6.ICMPType(p.b[0])) {
+			continue
+		}
+
+		return copy(b, p.b), p.controlMessage(flags, c.ifi.Index), &net.IPAddr{IP: p.src.AsSlice()}, nil
+	}
+}
+
+// WriteTo implements PacketConn. The source IPv6 address of the packet is
+// taken from cm, which must not be nil.
+func (c *ethernetConn) WriteTo(b []byte, cm *ipv6.ControlMessage, dst net.Addr) (int, error) {
+	if cm == nil {
+		return 0, errNoSource
+	}
+	src, ok := netip.AddrFromSlice(cm.Src)
+	if !ok {
+		return 0, errNoSource
+	}
+
+	ipa, ok := dst.(*net.IPAddr)
+	if !ok {
+		return 0, fmt.Errorf("ndp: invalid destination address: %v", dst)
+	}
+	ip, ok := netip.AddrFromSlice(ipa.IP)
+	if !ok {
+		return 0, fmt.Errorf("ndp: invalid destination IP address: %s", dst)
+	}
+
+	mac, err := c.cfg.destinationHardwareAddr(ip)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := appendEthernetFrame(nil, mac, c.src, src.Unmap(), ip.Unmap(), cm, b)
+	if err != nil {
+		return 0, err
+	}
+
+	sa := &syscall.SockaddrLinklayer{
+		Protocol: htons(etherTypeIPv6),
+		Ifindex:  c.ifi.Index,
+		Halen:    ethernetAddrLen,
+	}
+	copy(sa.Addr[:], mac)
+
+	var werr error
+	if err := c.rc.Write(func(fd uintptr) bool {
+		werr = syscall.Sendto(int(fd), f, 0, sa)
+		return werr != syscall.EAGAIN
+	}); err != nil {
+		return 0, err
+	}
+	if werr != nil {
+		return 0, os.NewSyscallError("sendto", werr)
+	}
+
+	return len(b), nil
+}
+
+// Close implements PacketConn.
+func (c *ethernetConn) Close() error { return c.f.Close() }
+
+// SetDeadline implements PacketConn.
+func (c *ethernetConn) SetDeadline(t time.Time) error { return c.f.SetDeadline(t) }
+
+// SetReadDeadline implements PacketConn.
+func (c *ethernetConn) SetReadDeadline(t time.Time) error { return c.f.SetReadDeadline(t) }
+
+// SetWriteDeadline implements PacketConn.
+func (c *ethernetConn) SetWriteDeadline(t time.Time) error { return c.f.SetWriteDeadline(t) }
+
+// JoinGroup implements PacketConn by adding the Ethernet multicast address of
+// group to the interface's filter.
+func (c *ethernetConn) JoinGroup(_ *net.Interface, group net.Addr) error {
+	return c.membership(syscall.PACKET_ADD_MEMBERSHIP, group)
+}
+
+// LeaveGroup implements PacketConn.
+func (c *ethernetConn) LeaveGroup(_ *net.Interface, group net.Addr) error {
+	return c.membership(syscall.PACKET_DROP_MEMBERSHIP, group)
+}
+
+// packetMreq is the Linux packet_mreq structure.
+type packetMreq struct {
+	Ifindex int32
+	Type    uint16
+	Alen    uint16
+	Address [8]byte
+}
+
+// membership adds or drops membership of the multicast group with opt.
+func (c *ethernetConn) membership(opt int, group net.Addr) error {
+	ipa, ok := group.(*net.IPAddr)
+	if !ok {
+		return fmt.Errorf("ndp: invalid multicast group: %v", group)
+	}
+	ip, ok := netip.AddrFromSlice(ipa.IP)
+	if !ok || !ip.IsMulticast() {
+		return fmt.Errorf("ndp: invalid multicast group: %v", group)
+	}
+
+	mreq := packetMreq{
+		Ifindex: int32(c.ifi.Index),
+		Type:    syscall.PACKET_MR_MULTICAST,
+		Alen:    ethernetAddrLen,
+	}
+	copy(mreq.Address[:], multicastHardwareAddr(ip))
+
+	// The syscall package has no packet_mreq helper, so pass the raw bytes of
+	// the structure.
+	b := (*[unsafe.Sizeof(mreq)]byte)(unsafe.Pointer(&mreq))[:]
+
+	var serr error
+	if err := c.rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptString(int(fd), syscall.SOL_PACKET, opt, string(b))
+	}); err != nil {
+		return err
+	}
+
+	return os.NewSyscallError("setsockopt", serr)
+}
+
+// SetICMPFilter implements PacketConn. The filter is applied as frames are
+// read.
+func (c *ethernetConn) SetICMPFilter(f *ipv6.ICMPFilter) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f != nil {
+		fc := *f
+		f = &fc
+	}
+	c.filter = f
+	return nil
+}
+
+// SetControlMessage implements PacketConn.
+func (c *ethernetConn) SetControlMessage(cf ipv6.ControlFlags, on bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if on {
+		c.flags |= cf
+	} else {
+		c.flags &^= cf
+	}
+
+	return nil
+}
+
+// SetBPF attaches a classic BPF program to the socket. Unlike the ICMPv6
+// sockets created by Listen, the program is applied to the Ethernet frame.
+func (c *ethernetConn) SetBPF(filter []bpf.RawInstruction) error {
+	fs := make([]syscall.SockFilter, 0, len(filter))
+	for _, ins := range filter {
+		fs = append(fs, syscall.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K})
+	}
+
+	var serr error
+	if err := c.rc.Control(func(fd uintptr) {
+		serr = syscall.AttachLsf(int(fd), fs)
+	}); err != nil {
+		return err
+	}
+
+	return os.NewSyscallError("setsockopt", serr)
+}
+
+// htons converts v from host to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ndp
+
+import (
+	"errors"
+	"net"
+)
+
+// ListenEthernet is not implemented on this platform.
+func ListenEthernet(_ *net.Interface, _ *EthernetConfig) (PacketConn, error) {
+	return nil, errors.New("ndp: Ethernet sockets are not supported on this platform")
+}
@@ -0,0 +1,102 @@
+package ndp
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Faults configures the injection of faults into the packets sent or received
+// by a Conn, so that the resilience of code built on a Conn can be tested
+// without external tooling. Each rate is a probability in the range [0, 1].
+type Faults struct {
+	// DropRate is the probability that a packet is silently discarded.
+	DropRate float64
+
+	// CorruptRate is the probability that a random bit of a packet is
+	// flipped.
+	CorruptRate float64
+
+	// TruncateRate is the probability that a packet is truncated to a random
+	// shorter length which retains the ICMPv6 header.
+	TruncateRate float64
+
+	// Seed seeds the source of randomness used to inject faults, so that a
+	// sequence of faults can be reproduced.
+	Seed int64
+}
+
+// SetFaults injects faults into the packets sent by c as configured by send,
+// and into the packets received by c as configured by receive. A nil Faults
+// disables fault injection in that direction, which is the default.
+//
+// Faults are injected into packets sent by WriteTo before they are written
+// to the network, and into packets received by ReadRaw and ReadFrom before
+// they are parsed. Packets which are dropped on send are not reported as an
+// error, and packets which are dropped on receive are skipped.
+func (c *Conn) SetFaults(send, receive *Faults) error {
+	sf, err := newFaulter(send)
+	if err != nil {
+		return err
+	}
+
+	rf, err := newFaulter(receive)
+	if err != nil {
+		return err
+	}
+
+	c.sendFaults.Store(sf)
+	c.receiveFaults.Store(rf)
+	return nil
+}
+
+// A faulter injects faults into packets.
+type faulter struct {
+	mu sync.Mutex
+	f  Faults
+	r  *rand.Rand
+}
+
+// newFaulter creates a faulter from f, or returns nil if f is nil.
+func newFaulter(f *Faults) (*faulter, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	for _, r := range []float64{f.DropRate, f.CorruptRate, f.TruncateRate} {
+		if r < 0 || r > 1 {
+			return nil, fmt.Errorf("ndp: fault rate must be in the range [0, 1]: %v", r)
+		}
+	}
+
+	return &faulter{
+		f: *f,
+		r: rand.New(rand.NewSource(f.Seed)),
+	}, nil
+}
+
+// apply injects faults into b in place. It returns the modified packet, or
+// false if the packet should be dropped. apply is a no-op if f is nil.
+func (f *faulter) apply(b []byte) ([]byte, bool) {
+	if f == nil {
+		return b, true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.r.Float64() < f.f.DropRate {
+		return nil, false
+	}
+
+	if f.r.Float64() < f.f.TruncateRate && len(b) > icmpLen {
+		b = b[:icmpLen+f.r.Intn(len(b)-icmpLen)]
+	}
+
+	if f.r.Float64() < f.f.CorruptRate && len(b) > 0 {
+		i := f.r.Intn(len(b) * 8)
+		b[i/8] ^= 1 << (i % 8)
+	}
+
+	return b, true
+}
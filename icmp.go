@@ -0,0 +1,92 @@
+package ndp
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// The IANA protocol number for ICMPv6, as used by package icmp.
+const protocolICMPv6 = 58
+
+var _ icmp.MessageBody = &ICMPMessageBody{}
+
+// An ICMPMessageBody adapts a Message for use as the Body of an
+// *icmp.Message. Most callers should use NewICMPMessage.
+type ICMPMessageBody struct {
+	Message Message
+}
+
+// Len implements icmp.MessageBody. It returns 0 if the Message cannot be
+// marshaled.
+func (mb *ICMPMessageBody) Len(_ int) int {
+	n, err := MarshaledLength(mb.Message)
+	if err != nil {
+		return 0
+	}
+
+	return n - icmpLen
+}
+
+// Marshal implements icmp.MessageBody.
+func (mb *ICMPMessageBody) Marshal(_ int) ([]byte, error) { return mb.Message.MarshalBinary() }
+
+// NewICMPMessage creates an *icmp.Message with the type, code, and body of m.
+func NewICMPMessage(m Message) *icmp.Message {
+	var code uint8
+	if cm, ok := m.(codedMessage); ok {
+		code = cm.code()
+	}
+
+	return &icmp.Message{
+		Type: m.Type(),
+		Code: int(code),
+		Body: &ICMPMessageBody{Message: m},
+	}
+}
+
+// ParseICMPMessage parses a Message from an *icmp.Message, such as one
+// returned by icmp.ParseMessage, without parsing its ICMPv6 header again.
+func ParseICMPMessage(im *icmp.Message) (Message, error) {
+	return (*Parser)(nil).ParseICMPMessage(im)
+}
+
+// ParseICMPMessage parses a Message from an *icmp.Message in the same way as
+// the package-level ParseICMPMessage, according to the policies of p.
+func (p *Parser) ParseICMPMessage(im *icmp.Message) (Message, error) {
+	t, ok := im.Type.(ipv6.ICMPType)
+	if !ok {
+		return nil, fmt.Errorf("ndp: ICMP message type %v is not an ICMPv6 type", im.Type)
+	}
+	if im.Code < 0 || im.Code > 255 {
+		return nil, fmt.Errorf("ndp: invalid ICMPv6 code: %d", im.Code)
+	}
+
+	var body []byte
+	switch b := im.Body.(type) {
+	case *ICMPMessageBody:
+		// Already a Message, no parsing necessary.
+		if b.Message.Type() != t {
+			return nil, errors.New("ndp: ICMP message type does not match its body")
+		}
+
+		return b.Message, nil
+	case *icmp.RawBody:
+		body = b.Data
+	case nil:
+	default:
+		// Bodies parsed by package icmp, such as ICMPv6 errors, must be
+		// converted back to their binary form.
+		var err error
+		body, err = b.Marshal(protocolICMPv6)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, 0, icmpLen+len(body))
+	b = append(b, byte(t), byte(im.Code), 0x00, 0x00)
+	return p.ParseMessage(append(b, body...))
+}
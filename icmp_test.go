@@ -0,0 +1,122 @@
+package ndp_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/ndp"
+	"github.com/mdlayher/ndp/internal/ndptest"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestICMPMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		m    ndp.Message
+	}{
+		{
+			name: "neighbor solicitation",
+			m: &ndp.NeighborSolicitation{
+				TargetAddress: ndptest.IP,
+				Options: []ndp.Option{&ndp.LinkLayerAddress{
+					Direction: ndp.Source,
+					Addr:      ndptest.MAC,
+				}},
+			},
+		},
+		{
+			name: "router advertisement",
+			m:    testRouterAdvertisement(),
+		},
+		{
+			// Parsed by package icmp as an *icmp.DstUnreach.
+			name: "ICMP error",
+			m: &ndp.ICMPError{
+				ErrorType: ipv6.ICMPTypeDestinationUnreachable,
+				Code:      3,
+				Invoking:  []byte{0xde, 0xad, 0xbe, 0xef},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ndp.MarshalMessage(tt.m)
+			if err != nil {
+				t.Fatalf("failed to marshal message: %v", err)
+			}
+
+			// Marshaling through package icmp must produce identical bytes,
+			// other than the checksum which package icmp computes.
+			b, err := ndp.NewICMPMessage(tt.m).Marshal(nil)
+			if err != nil {
+				t.Fatalf("failed to marshal ICMP message: %v", err)
+			}
+			b[2], b[3] = 0, 0
+
+			if diff := cmp.Diff(want, b); diff != "" {
+				t.Fatalf("unexpected ICMP message bytes (-want +got):\n%s", diff)
+			}
+
+			im, err := icmp.ParseMessage(ipv6.ICMPTypeEchoRequest.Protocol(), b)
+			if err != nil {
+				t.Fatalf("failed to parse ICMP message: %v", err)
+			}
+
+			m, err := ndp.ParseICMPMessage(im)
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.m, m, cmp.Comparer(addrEqual)); diff != "" {
+				t.Fatalf("unexpected message (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseICMPMessageError(t *testing.T) {
+	tests := []struct {
+		name string
+		im   *icmp.Message
+	}{
+		{
+			name: "IPv4 type",
+			im:   &icmp.Message{Type: ipv4.ICMPTypeEcho},
+		},
+		{
+			name: "bad code",
+			im: &icmp.Message{
+				Type: ipv6.ICMPTypeRouterSolicitation,
+				Code: 256,
+			},
+		},
+		{
+			name: "mismatched body",
+			im: &icmp.Message{
+				Type: ipv6.ICMPTypeRouterSolicitation,
+				Body: &ndp.ICMPMessageBody{Message: &ndp.NeighborSolicitation{
+					TargetAddress: netip.MustParseAddr("::1"),
+				}},
+			},
+		},
+		{
+			name: "short body",
+			im: &icmp.Message{
+				Type: ipv6.ICMPTypeNeighborSolicitation,
+				Body: &icmp.RawBody{Data: []byte{0x00}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ndp.ParseICMPMessage(tt.im); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
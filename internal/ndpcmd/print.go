@@ -10,22 +10,26 @@ import (
 	"github.com/mdlayher/ndp"
 )
 
-func printMessage(ll *log.Logger, m ndp.Message, from netip.Addr) {
+func printMessage(ll *log.Logger, r *resolver, m ndp.Message, from netip.Addr) {
 	switch m := m.(type) {
 	case *ndp.NeighborAdvertisement:
-		printNA(ll, m, from)
+		printNA(ll, r, m, from)
 	case *ndp.NeighborSolicitation:
-		printNS(ll, m, from)
+		printNS(ll, r, m, from)
 	case *ndp.RouterAdvertisement:
-		printRA(ll, m, from)
+		printRA(ll, r, m, from)
 	case *ndp.RouterSolicitation:
-		printRS(ll, m, from)
+		printRS(ll, r, m, from)
+	case *ndp.InverseNeighborSolicitation:
+		ll.Print(fmt.Sprintf(insFormat, r.String(from)) + optionsString(r, m.Options))
+	case *ndp.InverseNeighborAdvertisement:
+		ll.Print(fmt.Sprintf(inaFormat, r.String(from)) + optionsString(r, m.Options))
 	default:
-		ll.Printf("%s %#v", from, m)
+		ll.Printf("%s %#v", r.String(from), m)
 	}
 }
 
-func printRA(ll *log.Logger, ra *ndp.RouterAdvertisement, from netip.Addr) {
+func printRA(ll *log.Logger, r *resolver, ra *ndp.RouterAdvertisement, from netip.Addr) {
 	var flags []string
 	if ra.ManagedConfiguration {
 		flags = append(flags, "managed")
@@ -41,7 +45,7 @@ func printRA(ll *log.Logger, ra *ndp.RouterAdvertisement, from netip.Addr) {
 	}
 
 	var s strings.Builder
-	writef(&s, "router advertisement from: %s:\n", from)
+	writef(&s, "router advertisement from: %s:\n", r.String(from))
 
 	if ra.CurrentHopLimit > 0 {
 		writef(&s, "  - hop limit:        %d\n", ra.CurrentHopLimit)
@@ -62,33 +66,38 @@ func printRA(ll *log.Logger, ra *ndp.RouterAdvertisement, from netip.Addr) {
 		writef(&s, "  - retransmit timer: %s\n", ra.RetransmitTimer)
 	}
 
-	_, _ = s.WriteString(optionsString(ra.Options))
+	_, _ = s.WriteString(optionsString(r, ra.Options))
 
 	ll.Print(s.String())
 }
 
-func printRS(ll *log.Logger, rs *ndp.RouterSolicitation, from netip.Addr) {
+func printRS(ll *log.Logger, r *resolver, rs *ndp.RouterSolicitation, from netip.Addr) {
 	s := fmt.Sprintf(
 		rsFormat,
-		from.String(),
+		r.String(from),
 	)
 
-	ll.Print(s + optionsString(rs.Options))
+	ll.Print(s + optionsString(r, rs.Options))
 }
 
 const rsFormat = "router solicitation from %s:\n"
 
-func printNA(ll *log.Logger, na *ndp.NeighborAdvertisement, from netip.Addr) {
+const (
+	insFormat = "inverse neighbor solicitation from %s:\n"
+	inaFormat = "inverse neighbor advertisement from %s:\n"
+)
+
+func printNA(ll *log.Logger, r *resolver, na *ndp.NeighborAdvertisement, from netip.Addr) {
 	s := fmt.Sprintf(
 		naFormat,
-		from.String(),
+		r.String(from),
 		na.Router,
 		na.Solicited,
 		na.Override,
-		na.TargetAddress.String(),
+		r.String(na.TargetAddress),
 	)
 
-	ll.Print(s + optionsString(na.Options))
+	ll.Print(s + optionsString(r, na.Options))
 }
 
 const naFormat = `neighbor advertisement from %s:
@@ -98,21 +107,21 @@ const naFormat = `neighbor advertisement from %s:
   - target address: %s
 `
 
-func printNS(ll *log.Logger, ns *ndp.NeighborSolicitation, from netip.Addr) {
+func printNS(ll *log.Logger, r *resolver, ns *ndp.NeighborSolicitation, from netip.Addr) {
 	s := fmt.Sprintf(
 		nsFormat,
-		from.String(),
-		ns.TargetAddress.String(),
+		r.String(from),
+		r.String(ns.TargetAddress),
 	)
 
-	ll.Print(s + optionsString(ns.Options))
+	ll.Print(s + optionsString(r, ns.Options))
 }
 
 const nsFormat = `neighbor solicitation from %s:
   - target address: %s
 `
 
-func optionsString(options []ndp.Option) string {
+func optionsString(r *resolver, options []ndp.Option) string {
 	if len(options) == 0 {
 		return ""
 	}
@@ -121,13 +130,13 @@ func optionsString(options []ndp.Option) string {
 	s.WriteString("  - options:\n")
 
 	for _, o := range options {
-		writef(&s, "    - %s\n", optStr(o))
+		writef(&s, "    - %s\n", optStr(r, o))
 	}
 
 	return s.String()
 }
 
-func optStr(o ndp.Option) string {
+func optStr(r *resolver, o ndp.Option) string {
 	switch o := o.(type) {
 	case *ndp.LinkLayerAddress:
 		dir := "source"
@@ -135,54 +144,13 @@ func optStr(o ndp.Option) string {
 			dir = "target"
 		}
 
-		return fmt.Sprintf("%s link-layer address: %s", dir, o.Addr.String())
-	case *ndp.MTU:
-		return fmt.Sprintf("MTU: %d", o.MTU)
-	case *ndp.PrefixInformation:
-		var flags []string
-		if o.OnLink {
-			flags = append(flags, "on-link")
-		}
-		if o.AutonomousAddressConfiguration {
-			flags = append(flags, "autonomous")
-		}
-
-		return fmt.Sprintf("prefix information: %s/%d, flags: [%s], valid: %s, preferred: %s",
-			o.Prefix.String(),
-			o.PrefixLength,
-			strings.Join(flags, ", "),
-			o.ValidLifetime,
-			o.PreferredLifetime,
-		)
-	case *ndp.RawOption:
-		return fmt.Sprintf("type: %03d, value: %v", o.Type, o.Value)
-	case *ndp.RouteInformation:
-		return fmt.Sprintf("route information: %s/%d, preference: %s, lifetime: %s",
-			o.Prefix.String(),
-			o.PrefixLength,
-			o.Preference.String(),
-			o.RouteLifetime,
-		)
-	case *ndp.RecursiveDNSServer:
-		var ss []string
-		for _, s := range o.Servers {
-			ss = append(ss, s.String())
-		}
-		servers := strings.Join(ss, ", ")
-
-		return fmt.Sprintf("recursive DNS servers: lifetime: %s, servers: %s", o.Lifetime, servers)
-	case *ndp.RAFlagsExtension:
-		return fmt.Sprintf("RA flags extension: [%# 02x]", o.Flags)
-	case *ndp.DNSSearchList:
-		return fmt.Sprintf("DNS search list: lifetime: %s, domain names: %s", o.Lifetime, strings.Join(o.DomainNames, ", "))
-	case *ndp.CaptivePortal:
-		return fmt.Sprintf("captive portal: %s", o.URI)
-	case *ndp.PREF64:
-		return fmt.Sprintf("pref64: %s, lifetime: %s", o.Prefix, o.Lifetime)
+		return fmt.Sprintf("%s link-layer address: %s", dir, r.HardwareAddr(o.Addr))
 	case *ndp.Nonce:
 		return fmt.Sprintf("nonce: %s", o)
+	case fmt.Stringer:
+		return o.String()
 	default:
-		panic(fmt.Sprintf("unrecognized option: %v", o))
+		return fmt.Sprintf("type: %03d, option: %T", o.Code(), o)
 	}
 }
 
@@ -0,0 +1,116 @@
+package ndpcmd
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/ndp"
+)
+
+// A resolver annotates IPv6 addresses with host names using reverse DNS and
+// hosts file lookups, and link-layer addresses with vendor names using an OUI
+// database. Host name results, including failed lookups, are cached for the
+// lifetime of the resolver so each address is only looked up once, and
+// concurrent lookups of the same address share a single query.
+//
+// A nil *resolver performs no lookups.
+type resolver struct {
+	// Optional: host name lookups are disabled if r is nil.
+	r       *net.Resolver
+	timeout time.Duration
+
+	mu    sync.Mutex
+	names map[netip.Addr]*nameEntry
+
+	// Optional: vendor lookups are disabled if oui is nil.
+	oui *ndp.OUIDatabase
+}
+
+// newResolver creates a resolver. If timeout is non-zero, host name lookups
+// are enabled and wait up to timeout for each address. If oui is not nil,
+// vendor lookups are enabled for link-layer addresses.
+func newResolver(timeout time.Duration, oui *ndp.OUIDatabase) *resolver {
+	if timeout == 0 && oui == nil {
+		return nil
+	}
+
+	r := &resolver{oui: oui}
+	if timeout != 0 {
+		r.r = net.DefaultResolver
+		r.timeout = timeout
+		r.names = make(map[netip.Addr]*nameEntry)
+	}
+
+	return r
+}
+
+// String returns the string form of addr, followed by its host name in
+// parentheses if one is known.
+func (r *resolver) String(addr netip.Addr) string {
+	s := addr.String()
+	if r == nil || r.r == nil || !addr.IsValid() {
+		return s
+	}
+
+	if name := r.lookup(addr); name != "" {
+		s += " (" + name + ")"
+	}
+
+	return s
+}
+
+// A nameEntry is the cached host name of an address. name is set before done
+// is closed.
+type nameEntry struct {
+	done chan struct{}
+	name string
+}
+
+// lookup returns the host name for addr, or the empty string if none is
+// found.
+func (r *resolver) lookup(addr netip.Addr) string {
+	// Zones have no bearing on names.
+	addr = addr.WithZone("")
+
+	r.mu.Lock()
+	if e, ok := r.names[addr]; ok {
+		// Cached or in flight; don't hold the lock while waiting so that
+		// lookups of other addresses can proceed.
+		r.mu.Unlock()
+		<-e.done
+		return e.name
+	}
+
+	e := &nameEntry{done: make(chan struct{})}
+	r.names[addr] = e
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if names, err := r.r.LookupAddr(ctx, addr.String()); err == nil && len(names) > 0 {
+		e.name = strings.TrimSuffix(names[0], ".")
+	}
+
+	close(e.done)
+	return e.name
+}
+
+// HardwareAddr returns the string form of addr, followed by its vendor name in
+// parentheses if one is known.
+func (r *resolver) HardwareAddr(addr net.HardwareAddr) string {
+	s := addr.String()
+	if r == nil {
+		return s
+	}
+
+	if vendor := r.oui.VendorOf(addr); vendor != "" {
+		s += " (" + vendor + ")"
+	}
+
+	return s
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/netip"
 	"os"
+	"time"
 
 	"github.com/mdlayher/ndp"
 )
@@ -22,25 +23,34 @@ func Run(
 	ifi *net.Interface,
 	op string,
 	target netip.Addr,
+	resolve bool,
+	oui *ndp.OUIDatabase,
 ) error {
 	if op != "ns" && target.IsValid() {
 		return errTargetOp
 	}
 
+	// Host name lookups are disabled by default.
+	var timeout time.Duration
+	if resolve {
+		timeout = 2 * time.Second
+	}
+	r := newResolver(timeout, oui)
+
 	switch op {
 	// listen is the default when no op is specified.
 	case "listen", "":
-		return listen(ctx, c)
+		return listen(ctx, c, r)
 	case "ns":
-		return sendNS(ctx, c, ifi.HardwareAddr, target)
+		return sendNS(ctx, c, r, ifi.HardwareAddr, target)
 	case "rs":
-		return sendRS(ctx, c, ifi.HardwareAddr)
+		return sendRS(ctx, c, r, ifi.HardwareAddr)
 	default:
 		return fmt.Errorf("unrecognized operation: %q", op)
 	}
 }
 
-func listen(ctx context.Context, c *ndp.Conn) error {
+func listen(ctx context.Context, c *ndp.Conn, r *resolver) error {
 	ll := log.New(os.Stderr, "ndp listen> ", 0)
 	ll.Println("listening for messages")
 
@@ -51,14 +61,14 @@ func listen(ctx context.Context, c *ndp.Conn) error {
 	}
 
 	// No filtering, print all messages.
-	if err := receiveLoop(ctx, c, ll, nil, nil); err != nil {
+	if err := receiveLoop(ctx, c, ll, r, nil, nil); err != nil {
 		return fmt.Errorf("failed to read message: %v", err)
 	}
 
 	return nil
 }
 
-func sendNS(ctx context.Context, c *ndp.Conn, addr net.HardwareAddr, target netip.Addr) error {
+func sendNS(ctx context.Context, c *ndp.Conn, r *resolver, addr net.HardwareAddr, target netip.Addr) error {
 	ll := log.New(os.Stderr, "ndp ns> ", 0)
 
 	ll.Printf("neighbor solicitation:\n    - source link-layer address: %s", addr.String())
@@ -90,7 +100,7 @@ func sendNS(ctx context.Context, c *ndp.Conn, addr net.HardwareAddr, target neti
 		return na.TargetAddress == target
 	}
 
-	if err := sendReceiveLoop(ctx, c, ll, m, snm, check); err != nil {
+	if err := sendReceiveLoop(ctx, c, ll, r, m, snm, check); err != nil {
 		if err == context.Canceled {
 			return err
 		}
@@ -101,7 +111,7 @@ func sendNS(ctx context.Context, c *ndp.Conn, addr net.HardwareAddr, target neti
 	return nil
 }
 
-func sendRS(ctx context.Context, c *ndp.Conn, addr net.HardwareAddr) error {
+func sendRS(ctx context.Context, c *ndp.Conn, r *resolver, addr net.HardwareAddr) error {
 	ll := log.New(os.Stderr, "ndp rs> ", 0)
 
 	// Non-Ethernet interfaces (such as PPPoE) may not have a MAC address, so
@@ -125,7 +135,7 @@ func sendRS(ctx context.Context, c *ndp.Conn, addr net.HardwareAddr) error {
 		return ok
 	}
 
-	if err := sendReceiveLoop(ctx, c, ll, m, netip.MustParseAddr("ff02::2"), check); err != nil {
+	if err := sendReceiveLoop(ctx, c, ll, r, m, netip.MustParseAddr("ff02::2"), check); err != nil {
 		if err == context.Canceled {
 			return err
 		}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net"
 	"net/netip"
 	"time"
 
@@ -16,6 +15,7 @@ func sendReceiveLoop(
 	ctx context.Context,
 	c *ndp.Conn,
 	ll *log.Logger,
+	r *resolver,
 	m ndp.Message,
 	dst netip.Addr,
 	check func(m ndp.Message) bool,
@@ -32,7 +32,7 @@ func sendReceiveLoop(
 			continue
 		case nil:
 			fmt.Println()
-			printMessage(ll, msg, from)
+			printMessage(ll, r, msg, from)
 			return nil
 		default:
 			return err
@@ -44,11 +44,14 @@ func receiveLoop(
 	ctx context.Context,
 	c *ndp.Conn,
 	ll *log.Logger,
+	r *resolver,
 	check func(m ndp.Message) bool,
 	recv func(ll *log.Logger, msg ndp.Message, from netip.Addr),
 ) error {
 	if recv == nil {
-		recv = printMessage
+		recv = func(ll *log.Logger, msg ndp.Message, from netip.Addr) {
+			printMessage(ll, r, msg, from)
+		}
 	}
 
 	var count int
@@ -82,7 +85,16 @@ func sendReceive(
 		return nil, netip.Addr{}, fmt.Errorf("failed to write message: %v", err)
 	}
 
-	return receive(ctx, c, check)
+	// Wait a short time for a reply before sending the message again.
+	rctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	msg, from, err := receive(rctx, c, check)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, netip.Addr{}, errRetry
+	}
+
+	return msg, from, err
 }
 
 func receive(
@@ -90,32 +102,20 @@ func receive(
 	c *ndp.Conn,
 	check func(m ndp.Message) bool,
 ) (ndp.Message, netip.Addr, error) {
-	if err := c.SetReadDeadline(time.Now().Add(1 * time.Second)); err != nil {
-		return nil, netip.Addr{}, fmt.Errorf("failed to set deadline: %v", err)
-	}
-
-	msg, _, from, err := c.ReadFrom()
-	if err == nil {
-		if check != nil && !check(msg) {
-			// Read a message, but it isn't the one we want.  Keep trying.
-			return nil, netip.Addr{}, errRetry
+	msg, _, from, err := c.ReadFromContext(ctx)
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, netip.Addr{}, cerr
 		}
 
-		// Got a message that passed the check, if check was not nil.
-		return msg, from, nil
-	}
-
-	// Was the context canceled already?
-	select {
-	case <-ctx.Done():
-		return nil, netip.Addr{}, ctx.Err()
-	default:
+		return nil, netip.Addr{}, fmt.Errorf("failed to read message: %v", err)
 	}
 
-	// Was the error caused by a read timeout, and should the loop continue?
-	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+	if check != nil && !check(msg) {
+		// Read a message, but it isn't the one we want.  Keep trying.
 		return nil, netip.Addr{}, errRetry
 	}
 
-	return nil, netip.Addr{}, fmt.Errorf("failed to read message: %v", err)
+	// Got a message that passed the check, if check was not nil.
+	return msg, from, nil
 }
@@ -0,0 +1,199 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// journalMagic is the header which begins every journal, followed by a one
+// byte format version.
+var journalMagic = [4]byte{'N', 'D', 'P', 'J'}
+
+const (
+	journalVersion = 1
+
+	// Length of the journal header, and of the fixed portion of a record
+	// following its length prefix: timestamp and address length.
+	journalHeaderLen = len(journalMagic) + 1
+	journalRecordLen = 8 + 1
+
+	// journalMaxRecordLen is the length of the largest valid record: the
+	// fixed portion, the longest encoded address, and the largest ICMPv6
+	// message which fits in an IPv6 packet.
+	journalMaxRecordLen = journalRecordLen + math.MaxUint8 + math.MaxUint16
+)
+
+// A JournalEntry is a single Message observed at a point in time, as stored
+// in a journal.
+type JournalEntry struct {
+	// Time is the time at which the Message was observed.
+	Time time.Time
+
+	// From is the address which sent the Message. It may be the zero value
+	// if the sender is unknown.
+	From netip.Addr
+
+	// Message is the observed Message.
+	Message Message
+}
+
+// A JournalWriter writes an append-only journal of JournalEntry records to
+// an io.Writer, which can later be replayed using a JournalReader.
+//
+// Each record is prefixed with its length, so a reader can detect truncated
+// records, such as those caused by a crash while writing.
+type JournalWriter struct {
+	w      io.Writer
+	b      []byte
+	header bool
+}
+
+// NewJournalWriter creates a JournalWriter which writes to w. The journal
+// header is written along with the first entry.
+func NewJournalWriter(w io.Writer) *JournalWriter {
+	return &JournalWriter{w: w}
+}
+
+// Write appends e to the journal. Each call results in a single call to the
+// underlying io.Writer.
+func (jw *JournalWriter) Write(e JournalEntry) error {
+	if e.Message == nil {
+		return errors.New("ndp: journal entry must contain a Message")
+	}
+
+	addr, err := e.From.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(addr) > math.MaxUint8 {
+		return fmt.Errorf("ndp: journal entry address %q is too long", e.From)
+	}
+
+	b := jw.b[:0]
+	if !jw.header {
+		b = append(b, journalMagic[:]...)
+		b = append(b, journalVersion)
+	}
+
+	// Reserve space for the length prefix, which is filled in once the
+	// record is complete.
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+
+	b = binary.BigEndian.AppendUint64(b, uint64(e.Time.UnixNano()))
+	b = append(b, uint8(len(addr)))
+	b = append(b, addr...)
+
+	b, err = AppendMessage(b, e.Message)
+	if err != nil {
+		return err
+	}
+
+	n := len(b) - start - 4
+	if n > journalMaxRecordLen {
+		return fmt.Errorf("ndp: journal record too long: %d bytes", n)
+	}
+
+	binary.BigEndian.PutUint32(b[start:start+4], uint32(n))
+	jw.b = b
+
+	if _, err := jw.w.Write(b); err != nil {
+		return err
+	}
+
+	jw.header = true
+	return nil
+}
+
+// A JournalReader reads JournalEntry records from a journal produced by a
+// JournalWriter.
+type JournalReader struct {
+	r      io.Reader
+	b      []byte
+	header bool
+}
+
+// NewJournalReader creates a JournalReader which reads from r.
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{r: r}
+}
+
+// Next reads the next JournalEntry from the journal. Next returns io.EOF when
+// no entries remain, or io.ErrUnexpectedEOF if the journal ends with a
+// truncated record.
+func (jr *JournalReader) Next() (JournalEntry, error) {
+	if !jr.header {
+		var h [journalHeaderLen]byte
+		if _, err := io.ReadFull(jr.r, h[:]); err != nil {
+			return JournalEntry{}, err
+		}
+
+		if !bytes.Equal(h[:len(journalMagic)], journalMagic[:]) {
+			return JournalEntry{}, errors.New("ndp: invalid journal header")
+		}
+		if v := h[len(journalMagic)]; v != journalVersion {
+			return JournalEntry{}, fmt.Errorf("ndp: unsupported journal version: %d", v)
+		}
+
+		jr.header = true
+	}
+
+	var l [4]byte
+	if _, err := io.ReadFull(jr.r, l[:]); err != nil {
+		// A clean io.EOF here indicates the end of the journal.
+		return JournalEntry{}, err
+	}
+
+	n := int(binary.BigEndian.Uint32(l[:]))
+	if n < journalRecordLen {
+		return JournalEntry{}, fmt.Errorf("ndp: journal record too short: %d bytes", n)
+	}
+	if n > journalMaxRecordLen {
+		// Don't allocate a buffer for a corrupt length.
+		return JournalEntry{}, fmt.Errorf("ndp: journal record too long: %d bytes", n)
+	}
+
+	if cap(jr.b) < n {
+		jr.b = make([]byte, n)
+	}
+	b := jr.b[:n]
+
+	if _, err := io.ReadFull(jr.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return JournalEntry{}, err
+	}
+
+	t := time.Unix(0, int64(binary.BigEndian.Uint64(b[0:8])))
+
+	al := int(b[8])
+	b = b[journalRecordLen:]
+	if len(b) < al {
+		return JournalEntry{}, errors.New("ndp: journal record address is truncated")
+	}
+
+	var from netip.Addr
+	if err := from.UnmarshalBinary(b[:al]); err != nil {
+		return JournalEntry{}, err
+	}
+
+	// ParseMessage copies any data it retains, so the Message does not
+	// reference the read buffer, which is reused by the next call.
+	m, err := ParseMessage(b[al:])
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	return JournalEntry{
+		Time:    t,
+		From:    from,
+		Message: m,
+	}, nil
+}
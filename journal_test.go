@@ -0,0 +1,89 @@
+package ndp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/ndp"
+)
+
+func TestJournal(t *testing.T) {
+	want := []ndp.JournalEntry{
+		{
+			Time:    time.Unix(1, 0),
+			From:    netip.MustParseAddr("fe80::1%eth0"),
+			Message: testRouterAdvertisement(),
+		},
+		{
+			Time:    time.Unix(2, 500),
+			Message: &ndp.RouterSolicitation{},
+		},
+	}
+
+	var buf bytes.Buffer
+	jw := ndp.NewJournalWriter(&buf)
+	for _, e := range want {
+		if err := jw.Write(e); err != nil {
+			t.Fatalf("failed to write entry: %v", err)
+		}
+	}
+
+	got := readJournal(t, bytes.NewReader(buf.Bytes()), io.EOF)
+	if diff := cmp.Diff(want, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected journal entries (-want +got):\n%s", diff)
+	}
+
+	// Truncate the final record, and verify that only the first entry is
+	// read before the error.
+	truncated := buf.Bytes()[:buf.Len()-1]
+	got = readJournal(t, bytes.NewReader(truncated), io.ErrUnexpectedEOF)
+	if diff := cmp.Diff(want[:1], got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected truncated journal entries (-want +got):\n%s", diff)
+	}
+}
+
+func TestJournalReaderBadHeader(t *testing.T) {
+	jr := ndp.NewJournalReader(bytes.NewReader([]byte{'N', 'D', 'P', 'X', 1}))
+	if _, err := jr.Next(); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestJournalReaderRecordTooLong(t *testing.T) {
+	// A valid header followed by a length prefix far larger than any record.
+	b := []byte{'N', 'D', 'P', 'J', 1, 0xff, 0xff, 0xff, 0xff}
+
+	jr := ndp.NewJournalReader(bytes.NewReader(b))
+	_, err := jr.Next()
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected record length to be rejected before reading: %v", err)
+	}
+}
+
+func readJournal(t *testing.T, r io.Reader, wantErr error) []ndp.JournalEntry {
+	t.Helper()
+
+	jr := ndp.NewJournalReader(r)
+
+	var es []ndp.JournalEntry
+	for {
+		e, err := jr.Next()
+		if err != nil {
+			if !errors.Is(err, wantErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			return es
+		}
+
+		es = append(es, e)
+	}
+}
@@ -1,11 +1,16 @@
 package ndp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/netip"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -17,40 +22,194 @@ const (
 	icmpLen = 4
 
 	// Minimum byte length values for each type of valid Message.
-	naLen = 20
-	nsLen = 20
-	raLen = 12
-	rsLen = 4
+	naLen  = 20
+	nsLen  = 20
+	raLen  = 12
+	rsLen  = 4
+	indLen = 4
+	niLen  = 12
+	rrLen  = 12
+	errLen = 4
+
+	// Length of an IPv6 header.
+	ipv6Len = 40
 )
 
 // A Message is a Neighbor Discovery Protocol message.
+//
+// Message may be implemented outside of this package to support additional
+// message types, which can then be decoded by ParseMessage once registered
+// using RegisterMessage.
 type Message interface {
 	// Type specifies the ICMPv6 type for a Message.
 	Type() ipv6.ICMPType
 
-	// Called via MarshalMessage and ParseMessage.
-	marshal() ([]byte, error)
-	unmarshal(b []byte) error
+	// Clone returns a deep copy of a Message which shares no memory with
+	// the original, so it may be retained after the original is reused.
+	Clone() Message
+
+	// MarshalBinary and UnmarshalBinary marshal and unmarshal the body of a
+	// Message: its binary form following the 4 byte ICMPv6 type, code, and
+	// checksum header.
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(b []byte) error
 }
 
-func marshalMessage(m Message, psh []byte) ([]byte, error) {
-	mb, err := m.marshal()
-	if err != nil {
-		return nil, err
+// A codedMessage is a Message which carries information in the ICMPv6 code
+// field, rather than always setting it to zero.
+type codedMessage interface {
+	code() uint8
+	setCode(code uint8)
+}
+
+// A parserUnmarshaler is a Message which can be unmarshaled according to the
+// policies of a Parser, as implemented by the Messages in this package.
+type parserUnmarshaler interface {
+	unmarshal(p *Parser, b []byte) error
+}
+
+// A binaryAppender is a Message which can append its body to an existing
+// buffer, as implemented by the Messages in this package.
+type binaryAppender interface {
+	appendBinary(b []byte) ([]byte, error)
+}
+
+// registry holds the Message constructors added by RegisterMessage.
+var registry struct {
+	mu sync.RWMutex
+	m  map[ipv6.ICMPType]func() Message
+}
+
+// RegisterMessage registers a constructor for Messages of ICMPv6 type t, so
+// that ParseMessage and Conn.ReadFrom can decode them. This enables decoding
+// of experimental or vendor NDP extensions outside of this package.
+//
+// RegisterMessage is typically called from an init function. It panics if fn
+// is nil, if t is already registered, or if t is a type which this package
+// already implements.
+func RegisterMessage(t ipv6.ICMPType, fn func() Message) {
+	if fn == nil {
+		panic("ndp: RegisterMessage constructor is nil")
+	}
+	if builtinMessage(t) != nil {
+		panic(fmt.Sprintf("ndp: RegisterMessage called for built-in type %s", t))
 	}
 
-	im := icmp.Message{
-		Type: m.Type(),
-		// Always zero.
-		Code: 0,
-		// Calculated by caller or OS.
-		Checksum: 0,
-		Body: &icmp.RawBody{
-			Data: mb,
-		},
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.m[t]; ok {
+		panic(fmt.Sprintf("ndp: RegisterMessage called twice for type %s", t))
+	}
+	if registry.m == nil {
+		registry.m = make(map[ipv6.ICMPType]func() Message)
+	}
+
+	registry.m[t] = fn
+}
+
+// A MessageType describes a type of Message which can be parsed by this
+// package.
+type MessageType struct {
+	// Type is the ICMPv6 type of the Message, and Name is its human-readable
+	// name.
+	Type ipv6.ICMPType
+	Name string
+
+	// New creates an empty Message of this type.
+	New func() Message
+}
+
+// Human-readable names of the Messages implemented by this package.
+var messageNames = map[ipv6.ICMPType]string{
+	ipv6.ICMPTypeDestinationUnreachable:                "Destination Unreachable",
+	ipv6.ICMPTypePacketTooBig:                          "Packet Too Big",
+	ipv6.ICMPTypeTimeExceeded:                          "Time Exceeded",
+	ipv6.ICMPTypeParameterProblem:                      "Parameter Problem",
+	ipv6.ICMPTypeRouterSolicitation:                    "Router Solicitation",
+	ipv6.ICMPTypeRouterAdvertisement:                   "Router Advertisement",
+	ipv6.ICMPTypeNeighborSolicitation:                  "Neighbor Solicitation",
+	ipv6.ICMPTypeNeighborAdvertisement:                 "Neighbor Advertisement",
+	ipv6.ICMPTypeRouterRenumbering:                     "Router Renumbering",
+	ipv6.ICMPTypeNodeInformationQuery:                  "Node Information Query",
+	ipv6.ICMPTypeNodeInformationResponse:               "Node Information Reply",
+	ipv6.ICMPTypeInverseNeighborDiscoverySolicitation:  "Inverse Neighbor Discovery Solicitation",
+	ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement: "Inverse Neighbor Discovery Advertisement",
+}
+
+// MessageTypes returns the types of Messages which can be parsed by
+// ParseMessage, including those added by RegisterMessage, sorted by ICMPv6
+// type. Registered Messages are named by their IANA ICMPv6 type names.
+func MessageTypes() []MessageType {
+	mts := make([]MessageType, 0, len(messageNames))
+	for t, name := range messageNames {
+		t := t
+		mts = append(mts, MessageType{
+			Type: t,
+			Name: name,
+			New:  func() Message { return builtinMessage(t) },
+		})
+	}
+
+	registry.mu.RLock()
+	for t, fn := range registry.m {
+		mts = append(mts, MessageType{
+			Type: t,
+			Name: t.String(),
+			New:  fn,
+		})
+	}
+	registry.mu.RUnlock()
+
+	sort.Slice(mts, func(i, j int) bool { return mts[i].Type < mts[j].Type })
+	return mts
+}
+
+// newMessage creates an empty Message for ICMPv6 type t, or returns nil if t
+// is not recognized.
+func newMessage(t ipv6.ICMPType) Message {
+	if m := builtinMessage(t); m != nil {
+		return m
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	if fn, ok := registry.m[t]; ok {
+		return fn()
 	}
 
-	return im.Marshal(psh)
+	return nil
+}
+
+// builtinMessage creates an empty Message for ICMPv6 type t if t is
+// implemented by this package, or returns nil otherwise.
+func builtinMessage(t ipv6.ICMPType) Message {
+	switch t {
+	case ipv6.ICMPTypeNeighborAdvertisement:
+		return new(NeighborAdvertisement)
+	case ipv6.ICMPTypeNeighborSolicitation:
+		return new(NeighborSolicitation)
+	case ipv6.ICMPTypeRouterAdvertisement:
+		return new(RouterAdvertisement)
+	case ipv6.ICMPTypeRouterSolicitation:
+		return new(RouterSolicitation)
+	case ipv6.ICMPTypeInverseNeighborDiscoverySolicitation:
+		return new(InverseNeighborSolicitation)
+	case ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement:
+		return new(InverseNeighborAdvertisement)
+	case ipv6.ICMPTypeNodeInformationQuery:
+		return new(NodeInformationQuery)
+	case ipv6.ICMPTypeNodeInformationResponse:
+		return new(NodeInformationReply)
+	case ipv6.ICMPTypeRouterRenumbering:
+		return new(RouterRenumbering)
+	case ipv6.ICMPTypeDestinationUnreachable, ipv6.ICMPTypePacketTooBig,
+		ipv6.ICMPTypeTimeExceeded, ipv6.ICMPTypeParameterProblem:
+		return &ICMPError{ErrorType: t}
+	default:
+		return nil
+	}
 }
 
 // MarshalMessage marshals a Message into its binary form and prepends an
@@ -59,8 +218,63 @@ func marshalMessage(m Message, psh []byte) ([]byte, error) {
 // It is assumed that the operating system or caller will calculate and place
 // the ICMPv6 checksum in the result.
 func MarshalMessage(m Message) ([]byte, error) {
-	// Pseudo-header always nil so checksum is calculated by caller or OS.
-	return marshalMessage(m, nil)
+	return AppendMessage(nil, m)
+}
+
+// AppendMessage appends the binary form of a Message, preceded by an ICMPv6
+// message with the correct type, to dst and returns the extended buffer.
+//
+// AppendMessage does not allocate if dst has sufficient capacity, which
+// enables reuse of a single buffer when sending many messages. As with
+// MarshalMessage, it is assumed that the operating system or caller will
+// calculate and place the ICMPv6 checksum in the result.
+func AppendMessage(dst []byte, m Message) ([]byte, error) {
+	// ICMPv6 type, code (zero unless used by the message), and checksum
+	// (calculated by caller or OS).
+	var code uint8
+	if cm, ok := m.(codedMessage); ok {
+		code = cm.code()
+	}
+
+	b := append(dst, byte(m.Type()), code, 0x00, 0x00)
+
+	if ba, ok := m.(binaryAppender); ok {
+		return ba.appendBinary(b)
+	}
+
+	body, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, body...), nil
+}
+
+// lengthBuffers are reused by MarshaledLength to avoid allocations.
+var lengthBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1280)
+		return &b
+	},
+}
+
+// MarshaledLength returns the length in bytes of the binary form of a
+// Message, including its ICMPv6 message header, as produced by MarshalMessage.
+// It returns an error if m cannot be marshaled. The length does not include
+// the 40 byte IPv6 header, which must also fit within the link MTU when m is
+// sent.
+func MarshaledLength(m Message) (int, error) {
+	bp := lengthBuffers.Get().(*[]byte)
+	defer lengthBuffers.Put(bp)
+
+	b, err := AppendMessage((*bp)[:0], m)
+	if err != nil {
+		return 0, err
+	}
+
+	// Retain any growth of the buffer for future calls.
+	*bp = b
+	return len(b), nil
 }
 
 // MarshalMessageChecksum marshals a Message into its binary form and prepends
@@ -69,46 +283,256 @@ func MarshalMessage(m Message) ([]byte, error) {
 // The source and destination IP addresses are used to compute an IPv6 pseudo
 // header for checksum calculation.
 func MarshalMessageChecksum(m Message, source, destination netip.Addr) ([]byte, error) {
-	return marshalMessage(
-		m,
-		icmp.IPv6PseudoHeader(source.AsSlice(), destination.AsSlice()),
-	)
+	b, err := MarshalMessage(m)
+	if err != nil {
+		return nil, err
+	}
+
+	im := icmp.Message{
+		Type: m.Type(),
+		Code: int(b[1]),
+		Body: &icmp.RawBody{
+			Data: b[icmpLen:],
+		},
+	}
+
+	return im.Marshal(icmp.IPv6PseudoHeader(source.AsSlice(), destination.AsSlice()))
 }
 
 // errParseMessage is a sentinel which indicates an error from ParseMessage.
 var errParseMessage = errors.New("failed to parse message")
 
+// A Parser parses Messages according to a set of policies. The zero value
+// is ready to use, and parses Messages in the same way as ParseMessage.
+type Parser struct {
+	// StrictPreference rejects Messages which contain the reserved router
+	// selection or route Preference value, rather than applying the rules of
+	// RFC 4191: a RouterAdvertisement with the reserved value is treated as
+	// if it were Medium (Section 2.2), and a RouteInformation option with the
+	// reserved value is ignored (Section 2.3).
+	//
+	// The reserved value is always rejected when marshaling.
+	StrictPreference bool
+
+	// RawMessages parses well-formed ICMPv6 messages of types which are not
+	// recognized by this package or registered with RegisterMessage as
+	// *RawMessages, rather than returning an error.
+	RawMessages bool
+
+	// TolerantOptions parses malformed NDP options as *RawOptions, rather
+	// than rejecting the entire Message. Options whose lengths do not fit
+	// within the Message cannot be skipped, and are always rejected.
+	//
+	// If OptionError is not nil, it is called with an *OptionError for each
+	// malformed option before the parsing method returns.
+	TolerantOptions bool
+	OptionError     func(err *OptionError)
+
+	// Limits, if not nil, bounds the resources which may be consumed while
+	// parsing a single Message from an untrusted source. A Message which
+	// exceeds a limit is rejected with an error which wraps a *LimitError,
+	// even if TolerantOptions is set.
+	Limits *ParseLimits
+}
+
+// ParseLimits are limits applied by a Parser while parsing a Message. A zero
+// value for any field indicates that the field is not limited.
+type ParseLimits struct {
+	// MaxSize is the maximum length in bytes of a Message, including its
+	// ICMPv6 header.
+	MaxSize int
+
+	// MaxOptions is the maximum number of options in a Message.
+	MaxOptions int
+
+	// MaxOptionSize is the maximum length in bytes of a single option,
+	// including its type and length fields.
+	MaxOptionSize int
+
+	// MaxRDNSSServers is the maximum total number of servers in the
+	// RecursiveDNSServer options of a Message.
+	MaxRDNSSServers int
+
+	// MaxDNSSLDomains and MaxDNSSLLabels are the maximum total number of
+	// domain names and domain name labels in the DNSSearchList options of a
+	// Message.
+	MaxDNSSLDomains int
+	MaxDNSSLLabels  int
+
+	// MaxCost is the maximum estimated cost of parsing the options of a
+	// Message, which bounds the CPU time spent on a single Message. Parsing
+	// stops before the first option which would exceed the budget.
+	//
+	// Each option costs 16 units plus 1 unit per byte, except for options
+	// which contain domain names, such as DNSSearchList, which cost 64 units
+	// per byte because each label must be validated and decoded. The weights
+	// are derived from BenchmarkParseMessageWorstCase: a Router Advertisement
+	// in a 1500 byte frame which is filled with MTU options costs 4320 units,
+	// while one filled with domain name labels costs over 90000 units and
+	// takes correspondingly longer to parse. A MaxCost of 5000 admits any
+	// such Router Advertisement which does not carry domain names.
+	MaxCost int
+}
+
+// DefaultParseLimits returns ParseLimits which are suitable for a long-running
+// listener which parses Messages from untrusted sources. The limits are
+// generous enough to admit any well-formed Message seen in practice, including
+// a RouterAdvertisement with hundreds of prefixes, while bounding the memory
+// allocated for a single Message. The returned ParseLimits may be tightened
+// further before use.
+//
+// A Parser without Limits applies no limits, for compatibility.
+func DefaultParseLimits() *ParseLimits {
+	return &ParseLimits{
+		MaxOptions:      1024,
+		MaxRDNSSServers: 256,
+		MaxDNSSLDomains: 256,
+		MaxDNSSLLabels:  2048,
+	}
+}
+
+// A LimitError is an error which occurs when a Parser rejects a Message
+// which exceeds one of its ParseLimits.
+type LimitError struct {
+	// Limit describes the limit which was exceeded, and Max is its value.
+	Limit string
+	Max   int
+}
+
+// Error implements error.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ndp: message exceeds limit of %d %s", e.Max, e.Limit)
+}
+
+// checkLimit returns a *LimitError if n exceeds max, unless max is zero.
+func checkLimit(limit string, max, n int) error {
+	if max > 0 && n > max {
+		return &LimitError{Limit: limit, Max: max}
+	}
+
+	return nil
+}
+
+// strictPreference reports whether p rejects the reserved Preference value.
+func (p *Parser) strictPreference() bool { return p != nil && p.StrictPreference }
+
+// tolerantOptions reports whether p parses malformed options as RawOptions.
+func (p *Parser) tolerantOptions() bool { return p != nil && p.TolerantOptions }
+
+// optionError reports a malformed option to p, if requested.
+func (p *Parser) optionError(err *OptionError) {
+	if p != nil && p.OptionError != nil {
+		p.OptionError(err)
+	}
+}
+
+// rawMessages reports whether p parses unrecognized types as RawMessages.
+func (p *Parser) rawMessages() bool { return p != nil && p.RawMessages }
+
+// limits returns the ParseLimits of p, if any.
+func (p *Parser) limits() ParseLimits {
+	if p == nil || p.Limits == nil {
+		return ParseLimits{}
+	}
+
+	return *p.Limits
+}
+
 // ParseMessage parses a Message from its binary form after determining its
 // type from a leading ICMPv6 message.
 func ParseMessage(b []byte) (Message, error) {
+	return (*Parser)(nil).ParseMessage(b)
+}
+
+// ParseMessage parses a Message from its binary form after determining its
+// type from a leading ICMPv6 message, according to the policies of p.
+func (p *Parser) ParseMessage(b []byte) (Message, error) {
 	if len(b) < icmpLen {
 		return nil, fmt.Errorf("ndp: ICMPv6 message too short: %w", errParseMessage)
 	}
 
 	// TODO(mdlayher): verify checksum?
 
-	var m Message
 	t := ipv6.ICMPType(b[0])
-	switch t {
-	case ipv6.ICMPTypeNeighborAdvertisement:
-		m = new(NeighborAdvertisement)
-	case ipv6.ICMPTypeNeighborSolicitation:
-		m = new(NeighborSolicitation)
-	case ipv6.ICMPTypeRouterAdvertisement:
-		m = new(RouterAdvertisement)
-	case ipv6.ICMPTypeRouterSolicitation:
-		m = new(RouterSolicitation)
-	default:
+	m := newMessage(t)
+	if m == nil && p.rawMessages() {
+		m = &RawMessage{MessageType: t}
+	}
+	if m == nil {
 		return nil, fmt.Errorf("ndp: unrecognized ICMPv6 type %d: %w", t, errParseMessage)
 	}
 
-	if err := m.unmarshal(b[icmpLen:]); err != nil {
-		return nil, fmt.Errorf("ndp: failed to unmarshal %s: %w", t, errParseMessage)
+	if err := p.unmarshalMessage(b, m); err != nil {
+		return nil, unmarshalError(t, err)
 	}
 
 	return m, nil
 }
 
+// UnmarshalMessage parses the binary form of a Message into m, after verifying
+// that the type of the leading ICMPv6 message matches the type of m.
+//
+// UnmarshalMessage reuses the Options slice of m and any of its Options which
+// are of the same type as the Options being parsed. This enables decoding many
+// messages into the same Message without allocating a new Message and Options
+// for each, but also means that m and its Options must not be retained by the
+// caller between calls. If an error is returned, the contents of m are
+// unspecified.
+func UnmarshalMessage(b []byte, m Message) error {
+	return (*Parser)(nil).UnmarshalMessage(b, m)
+}
+
+// UnmarshalMessage parses the binary form of a Message into m in the same way
+// as the package-level UnmarshalMessage, according to the policies of p.
+func (p *Parser) UnmarshalMessage(b []byte, m Message) error {
+	if len(b) < icmpLen {
+		return fmt.Errorf("ndp: ICMPv6 message too short: %w", errParseMessage)
+	}
+
+	t := ipv6.ICMPType(b[0])
+	if t != m.Type() {
+		return fmt.Errorf("ndp: cannot unmarshal ICMPv6 type %d into %s: %w", t, m.Type(), errParseMessage)
+	}
+
+	if err := p.unmarshalMessage(b, m); err != nil {
+		return unmarshalError(t, err)
+	}
+
+	return nil
+}
+
+// unmarshalError returns the error for a failure to unmarshal a message of type
+// t. A *LimitError is exposed to the caller, but other errors are not.
+func unmarshalError(t ipv6.ICMPType, err error) error {
+	var lerr *LimitError
+	if errors.As(err, &lerr) {
+		return fmt.Errorf("ndp: failed to unmarshal %s: %w: %w", t, errParseMessage, lerr)
+	}
+
+	return fmt.Errorf("ndp: failed to unmarshal %s: %w", t, errParseMessage)
+}
+
+// unmarshalMessage unmarshals the body of the ICMPv6 message b into m. If m
+// makes use of the ICMPv6 code, it is stored first so that it may be used to
+// interpret the body.
+func (p *Parser) unmarshalMessage(b []byte, m Message) error {
+	if err := checkLimit("bytes", p.limits().MaxSize, len(b)); err != nil {
+		return err
+	}
+
+	if cm, ok := m.(codedMessage); ok {
+		cm.setCode(b[1])
+	}
+
+	// Messages implemented by this package apply the policies of p, while
+	// all others only implement UnmarshalBinary.
+	if pu, ok := m.(parserUnmarshaler); ok {
+		return pu.unmarshal(p, b[icmpLen:])
+	}
+
+	return m.UnmarshalBinary(b[icmpLen:])
+}
+
 var _ Message = &NeighborAdvertisement{}
 
 // A NeighborAdvertisement is a Neighbor Advertisement message as
@@ -124,36 +548,58 @@ type NeighborAdvertisement struct {
 // Type implements Message.
 func (na *NeighborAdvertisement) Type() ipv6.ICMPType { return ipv6.ICMPTypeNeighborAdvertisement }
 
-func (na *NeighborAdvertisement) marshal() ([]byte, error) {
+// Clone implements Message.
+func (na *NeighborAdvertisement) Clone() Message {
+	c := *na
+	c.Options = cloneOptions(na.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (na *NeighborAdvertisement) MarshalBinary() ([]byte, error) { return na.appendBinary(nil) }
+
+// UnmarshalBinary implements Message.
+func (na *NeighborAdvertisement) UnmarshalBinary(b []byte) error { return na.unmarshal(nil, b) }
+
+// Equal reports whether na and x are the same NeighborAdvertisement.
+func (na *NeighborAdvertisement) Equal(x *NeighborAdvertisement) bool {
+	if na == nil || x == nil {
+		return na == x
+	}
+
+	return na.Router == x.Router &&
+		na.Solicited == x.Solicited &&
+		na.Override == x.Override &&
+		na.TargetAddress == x.TargetAddress &&
+		optionsEqual(na.Options, x.Options)
+}
+
+func (na *NeighborAdvertisement) appendBinary(b []byte) ([]byte, error) {
 	if err := checkIPv6(na.TargetAddress); err != nil {
 		return nil, err
 	}
 
-	b := make([]byte, naLen)
-
+	var flags uint8
 	if na.Router {
-		b[0] |= (1 << 7)
+		flags |= (1 << 7)
 	}
 	if na.Solicited {
-		b[0] |= (1 << 6)
+		flags |= (1 << 6)
 	}
 	if na.Override {
-		b[0] |= (1 << 5)
+		flags |= (1 << 5)
 	}
 
-	copy(b[4:], na.TargetAddress.AsSlice())
-
-	ob, err := marshalOptions(na.Options)
-	if err != nil {
-		return nil, err
-	}
+	// Flags and reserved area.
+	b = append(b, flags, 0x00, 0x00, 0x00)
 
-	b = append(b, ob...)
+	target := na.TargetAddress.As16()
+	b = append(b, target[:]...)
 
-	return b, nil
+	return AppendOptions(b, na.Options)
 }
 
-func (na *NeighborAdvertisement) unmarshal(b []byte) error {
+func (na *NeighborAdvertisement) unmarshal(p *Parser, b []byte) error {
 	if len(b) < naLen {
 		return io.ErrUnexpectedEOF
 	}
@@ -168,7 +614,7 @@ func (na *NeighborAdvertisement) unmarshal(b []byte) error {
 		return err
 	}
 
-	options, err := parseOptions(b[naLen:])
+	options, err := parseOptions(p, na.Options[:0], b[naLen:])
 	if err != nil {
 		return err
 	}
@@ -197,25 +643,43 @@ type NeighborSolicitation struct {
 // Type implements Message.
 func (ns *NeighborSolicitation) Type() ipv6.ICMPType { return ipv6.ICMPTypeNeighborSolicitation }
 
-func (ns *NeighborSolicitation) marshal() ([]byte, error) {
-	if err := checkIPv6(ns.TargetAddress); err != nil {
-		return nil, err
+// Clone implements Message.
+func (ns *NeighborSolicitation) Clone() Message {
+	c := *ns
+	c.Options = cloneOptions(ns.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (ns *NeighborSolicitation) MarshalBinary() ([]byte, error) { return ns.appendBinary(nil) }
+
+// UnmarshalBinary implements Message.
+func (ns *NeighborSolicitation) UnmarshalBinary(b []byte) error { return ns.unmarshal(nil, b) }
+
+// Equal reports whether ns and x are the same NeighborSolicitation.
+func (ns *NeighborSolicitation) Equal(x *NeighborSolicitation) bool {
+	if ns == nil || x == nil {
+		return ns == x
 	}
 
-	b := make([]byte, nsLen)
-	copy(b[4:], ns.TargetAddress.AsSlice())
+	return ns.TargetAddress == x.TargetAddress && optionsEqual(ns.Options, x.Options)
+}
 
-	ob, err := marshalOptions(ns.Options)
-	if err != nil {
+func (ns *NeighborSolicitation) appendBinary(b []byte) ([]byte, error) {
+	if err := checkIPv6(ns.TargetAddress); err != nil {
 		return nil, err
 	}
 
-	b = append(b, ob...)
+	// Reserved area.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
 
-	return b, nil
+	target := ns.TargetAddress.As16()
+	b = append(b, target[:]...)
+
+	return AppendOptions(b, ns.Options)
 }
 
-func (ns *NeighborSolicitation) unmarshal(b []byte) error {
+func (ns *NeighborSolicitation) unmarshal(p *Parser, b []byte) error {
 	if len(b) < nsLen {
 		return io.ErrUnexpectedEOF
 	}
@@ -230,7 +694,7 @@ func (ns *NeighborSolicitation) unmarshal(b []byte) error {
 		return err
 	}
 
-	options, err := parseOptions(b[nsLen:])
+	options, err := parseOptions(p, ns.Options[:0], b[nsLen:])
 	if err != nil {
 		return err
 	}
@@ -272,60 +736,116 @@ const (
 	Low         Preference = 3
 )
 
+// ParsePreference parses a Preference from its textual form: "low", "medium",
+// or "high". The comparison is case-insensitive.
+func ParsePreference(s string) (Preference, error) {
+	for _, prf := range []Preference{Low, Medium, High} {
+		if strings.EqualFold(s, prf.String()) {
+			return prf, nil
+		}
+	}
+
+	return 0, fmt.Errorf("ndp: invalid preference %q: must be low, medium, or high", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, producing "low", "medium",
+// or "high". The reserved Preference value cannot be marshaled.
+func (p Preference) MarshalText() ([]byte, error) {
+	if err := checkPreference(p); err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.ToLower(p.String())), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParsePreference.
+func (p *Preference) UnmarshalText(b []byte) error {
+	prf, err := ParsePreference(string(b))
+	if err != nil {
+		return err
+	}
+
+	*p = prf
+	return nil
+}
+
 // Type implements Message.
 func (ra *RouterAdvertisement) Type() ipv6.ICMPType { return ipv6.ICMPTypeRouterAdvertisement }
 
-func (ra *RouterAdvertisement) marshal() ([]byte, error) {
-	if err := checkPreference(ra.RouterSelectionPreference); err != nil {
-		return nil, err
+// Clone implements Message.
+func (ra *RouterAdvertisement) Clone() Message {
+	c := *ra
+	c.Options = cloneOptions(ra.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (ra *RouterAdvertisement) MarshalBinary() ([]byte, error) { return ra.appendBinary(nil) }
+
+// UnmarshalBinary implements Message.
+func (ra *RouterAdvertisement) UnmarshalBinary(b []byte) error { return ra.unmarshal(nil, b) }
+
+// Equal reports whether ra and x are the same RouterAdvertisement.
+func (ra *RouterAdvertisement) Equal(x *RouterAdvertisement) bool {
+	if ra == nil || x == nil {
+		return ra == x
 	}
 
-	b := make([]byte, raLen)
+	return ra.CurrentHopLimit == x.CurrentHopLimit &&
+		ra.ManagedConfiguration == x.ManagedConfiguration &&
+		ra.OtherConfiguration == x.OtherConfiguration &&
+		ra.MobileIPv6HomeAgent == x.MobileIPv6HomeAgent &&
+		ra.RouterSelectionPreference == x.RouterSelectionPreference &&
+		ra.NeighborDiscoveryProxy == x.NeighborDiscoveryProxy &&
+		ra.RouterLifetime == x.RouterLifetime &&
+		ra.ReachableTime == x.ReachableTime &&
+		ra.RetransmitTimer == x.RetransmitTimer &&
+		optionsEqual(ra.Options, x.Options)
+}
 
-	b[0] = ra.CurrentHopLimit
+func (ra *RouterAdvertisement) appendBinary(b []byte) ([]byte, error) {
+	if err := checkPreference(ra.RouterSelectionPreference); err != nil {
+		return nil, err
+	}
 
+	var flags uint8
 	if ra.ManagedConfiguration {
-		b[1] |= (1 << 7)
+		flags |= (1 << 7)
 	}
 	if ra.OtherConfiguration {
-		b[1] |= (1 << 6)
+		flags |= (1 << 6)
 	}
 	if ra.MobileIPv6HomeAgent {
-		b[1] |= (1 << 5)
+		flags |= (1 << 5)
 	}
 	if prf := uint8(ra.RouterSelectionPreference); prf != 0 {
-		b[1] |= (prf << 3)
+		flags |= (prf << 3)
 	}
 	if ra.NeighborDiscoveryProxy {
-		b[1] |= (1 << 2)
+		flags |= (1 << 2)
 	}
 
+	b = append(b, ra.CurrentHopLimit, flags)
+
 	lifetime := ra.RouterLifetime.Seconds()
-	binary.BigEndian.PutUint16(b[2:4], uint16(lifetime))
+	b = binary.BigEndian.AppendUint16(b, uint16(lifetime))
 
 	reach := ra.ReachableTime / time.Millisecond
-	binary.BigEndian.PutUint32(b[4:8], uint32(reach))
+	b = binary.BigEndian.AppendUint32(b, uint32(reach))
 
 	retrans := ra.RetransmitTimer / time.Millisecond
-	binary.BigEndian.PutUint32(b[8:12], uint32(retrans))
-
-	ob, err := marshalOptions(ra.Options)
-	if err != nil {
-		return nil, err
-	}
+	b = binary.BigEndian.AppendUint32(b, uint32(retrans))
 
-	b = append(b, ob...)
-
-	return b, nil
+	return AppendOptions(b, ra.Options)
 }
 
-func (ra *RouterAdvertisement) unmarshal(b []byte) error {
+func (ra *RouterAdvertisement) unmarshal(p *Parser, b []byte) error {
 	if len(b) < raLen {
 		return io.ErrUnexpectedEOF
 	}
 
 	// Skip message body for options.
-	options, err := parseOptions(b[raLen:])
+	options, err := parseOptions(p, ra.Options[:0], b[raLen:])
 	if err != nil {
 		return err
 	}
@@ -346,6 +866,10 @@ func (ra *RouterAdvertisement) unmarshal(b []byte) error {
 	// "If the Reserved (10) value is received, the receiver MUST treat the
 	// value as if it were (00)."
 	if prf == prfReserved {
+		if p.strictPreference() {
+			return errPreferenceReserved
+		}
+
 		prf = Medium
 	}
 
@@ -376,27 +900,42 @@ type RouterSolicitation struct {
 // Type implements Message.
 func (rs *RouterSolicitation) Type() ipv6.ICMPType { return ipv6.ICMPTypeRouterSolicitation }
 
-func (rs *RouterSolicitation) marshal() ([]byte, error) {
-	// b contains reserved area.
-	b := make([]byte, rsLen)
+// Clone implements Message.
+func (rs *RouterSolicitation) Clone() Message {
+	c := *rs
+	c.Options = cloneOptions(rs.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (rs *RouterSolicitation) MarshalBinary() ([]byte, error) { return rs.appendBinary(nil) }
 
-	ob, err := marshalOptions(rs.Options)
-	if err != nil {
-		return nil, err
+// UnmarshalBinary implements Message.
+func (rs *RouterSolicitation) UnmarshalBinary(b []byte) error { return rs.unmarshal(nil, b) }
+
+// Equal reports whether rs and x are the same RouterSolicitation.
+func (rs *RouterSolicitation) Equal(x *RouterSolicitation) bool {
+	if rs == nil || x == nil {
+		return rs == x
 	}
 
-	b = append(b, ob...)
+	return optionsEqual(rs.Options, x.Options)
+}
 
-	return b, nil
+func (rs *RouterSolicitation) appendBinary(b []byte) ([]byte, error) {
+	// Reserved area.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
+
+	return AppendOptions(b, rs.Options)
 }
 
-func (rs *RouterSolicitation) unmarshal(b []byte) error {
+func (rs *RouterSolicitation) unmarshal(p *Parser, b []byte) error {
 	if len(b) < rsLen {
 		return io.ErrUnexpectedEOF
 	}
 
 	// Skip reserved area.
-	options, err := parseOptions(b[rsLen:])
+	options, err := parseOptions(p, rs.Options[:0], b[rsLen:])
 	if err != nil {
 		return err
 	}
@@ -408,22 +947,984 @@ func (rs *RouterSolicitation) unmarshal(b []byte) error {
 	return nil
 }
 
-// checkIPv6 verifies that ip is an IPv6 address.
-func checkIPv6(ip netip.Addr) error {
-	if !ip.Is6() || ip.Is4In6() {
-		return fmt.Errorf("ndp: invalid IPv6 address: %q", ip)
+var _ Message = &InverseNeighborSolicitation{}
+
+// An InverseNeighborSolicitation is an Inverse Neighbor Discovery
+// Solicitation message as described in RFC 3122, Section 2.
+type InverseNeighborSolicitation struct {
+	Options []Option
+}
+
+// Type implements Message.
+func (ins *InverseNeighborSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeInverseNeighborDiscoverySolicitation
+}
+
+// Clone implements Message.
+func (ins *InverseNeighborSolicitation) Clone() Message {
+	c := *ins
+	c.Options = cloneOptions(ins.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (ins *InverseNeighborSolicitation) MarshalBinary() ([]byte, error) { return ins.appendBinary(nil) }
+
+// UnmarshalBinary implements Message.
+func (ins *InverseNeighborSolicitation) UnmarshalBinary(b []byte) error { return ins.unmarshal(nil, b) }
+
+// Equal reports whether ins and x are the same InverseNeighborSolicitation.
+func (ins *InverseNeighborSolicitation) Equal(x *InverseNeighborSolicitation) bool {
+	if ins == nil || x == nil {
+		return ins == x
+	}
+
+	return optionsEqual(ins.Options, x.Options)
+}
+
+func (ins *InverseNeighborSolicitation) appendBinary(b []byte) ([]byte, error) {
+	// Reserved area.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
+
+	return AppendOptions(b, ins.Options)
+}
+
+func (ins *InverseNeighborSolicitation) unmarshal(p *Parser, b []byte) error {
+	if len(b) < indLen {
+		return io.ErrUnexpectedEOF
+	}
+
+	// Skip reserved area.
+	options, err := parseOptions(p, ins.Options[:0], b[indLen:])
+	if err != nil {
+		return err
+	}
+
+	*ins = InverseNeighborSolicitation{
+		Options: options,
 	}
 
 	return nil
 }
 
+var _ Message = &InverseNeighborAdvertisement{}
+
+// An InverseNeighborAdvertisement is an Inverse Neighbor Discovery
+// Advertisement message as described in RFC 3122, Section 2.
+type InverseNeighborAdvertisement struct {
+	Options []Option
+}
+
+// Type implements Message.
+func (ina *InverseNeighborAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement
+}
+
+// Clone implements Message.
+func (ina *InverseNeighborAdvertisement) Clone() Message {
+	c := *ina
+	c.Options = cloneOptions(ina.Options)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (ina *InverseNeighborAdvertisement) MarshalBinary() ([]byte, error) {
+	return ina.appendBinary(nil)
+}
+
+// UnmarshalBinary implements Message.
+func (ina *InverseNeighborAdvertisement) UnmarshalBinary(b []byte) error {
+	return ina.unmarshal(nil, b)
+}
+
+// Equal reports whether ina and x are the same InverseNeighborAdvertisement.
+func (ina *InverseNeighborAdvertisement) Equal(x *InverseNeighborAdvertisement) bool {
+	if ina == nil || x == nil {
+		return ina == x
+	}
+
+	return optionsEqual(ina.Options, x.Options)
+}
+
+func (ina *InverseNeighborAdvertisement) appendBinary(b []byte) ([]byte, error) {
+	// Reserved area.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
+
+	return AppendOptions(b, ina.Options)
+}
+
+func (ina *InverseNeighborAdvertisement) unmarshal(p *Parser, b []byte) error {
+	if len(b) < indLen {
+		return io.ErrUnexpectedEOF
+	}
+
+	// Skip reserved area.
+	options, err := parseOptions(p, ina.Options[:0], b[indLen:])
+	if err != nil {
+		return err
+	}
+
+	*ina = InverseNeighborAdvertisement{
+		Options: options,
+	}
+
+	return nil
+}
+
+// An NIQType is the type of information requested by a NodeInformationQuery,
+// as described in RFC 4620, Section 4.
+type NIQType uint16
+
+// Possible NIQType values.
+const (
+	NIQTypeNOOP          NIQType = 0
+	NIQTypeNodeName      NIQType = 2
+	NIQTypeNodeAddresses NIQType = 3
+	NIQTypeIPv4Addresses NIQType = 4
+)
+
+// Possible NodeInformationQuery Code values, which specify the type of
+// subject carried in the Data field.
+const (
+	NISubjectIPv6 uint8 = 0
+	NISubjectName uint8 = 1
+	NISubjectIPv4 uint8 = 2
+)
+
+// Possible NodeInformationReply Code values.
+const (
+	NIReplySuccess      uint8 = 0
+	NIReplyRefused      uint8 = 1
+	NIReplyUnknownQType uint8 = 2
+)
+
+var _ Message = &NodeInformationQuery{}
+
+// A NodeInformationQuery is a Node Information Query message as described in
+// RFC 4620, Section 4.
+type NodeInformationQuery struct {
+	// Code is the ICMPv6 code of the message, which specifies the type of
+	// subject in Data, such as NISubjectIPv6.
+	Code uint8
+
+	// QType is the type of information requested, and Flags contains
+	// QType-specific flags.
+	QType NIQType
+	Flags uint16
+
+	// Nonce is an opaque value which is echoed in the NodeInformationReply.
+	Nonce [8]byte
+
+	// Data contains the subject of the query in its raw wire format, as
+	// described in RFC 4620, Section 5.
+	Data []byte
+}
+
+// Type implements Message.
+func (q *NodeInformationQuery) Type() ipv6.ICMPType { return ipv6.ICMPTypeNodeInformationQuery }
+
+// Clone implements Message.
+func (q *NodeInformationQuery) Clone() Message {
+	c := *q
+	c.Data = bytes.Clone(q.Data)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (q *NodeInformationQuery) MarshalBinary() ([]byte, error) { return q.appendBinary(nil) }
+
+// UnmarshalBinary implements Message. The ICMPv6 code is not part of b, so
+// Code is left unchanged.
+func (q *NodeInformationQuery) UnmarshalBinary(b []byte) error { return q.unmarshal(nil, b) }
+
+// Equal reports whether q and x are the same NodeInformationQuery.
+func (q *NodeInformationQuery) Equal(x *NodeInformationQuery) bool {
+	if q == nil || x == nil {
+		return q == x
+	}
+
+	return q.Code == x.Code &&
+		q.QType == x.QType &&
+		q.Flags == x.Flags &&
+		q.Nonce == x.Nonce &&
+		bytes.Equal(q.Data, x.Data)
+}
+
+func (q *NodeInformationQuery) code() uint8        { return q.Code }
+func (q *NodeInformationQuery) setCode(code uint8) { q.Code = code }
+
+func (q *NodeInformationQuery) appendBinary(b []byte) ([]byte, error) {
+	return appendNodeInformation(b, q.QType, q.Flags, q.Nonce, q.Data), nil
+}
+
+func (q *NodeInformationQuery) unmarshal(p *Parser, b []byte) error {
+	qtype, flags, nonce, data, err := parseNodeInformation(b)
+	if err != nil {
+		return err
+	}
+
+	q.QType = qtype
+	q.Flags = flags
+	q.Nonce = nonce
+	q.Data = append(q.Data[:0], data...)
+
+	return nil
+}
+
+var _ Message = &NodeInformationReply{}
+
+// A NodeInformationReply is a Node Information Reply message as described in
+// RFC 4620, Section 4.
+type NodeInformationReply struct {
+	// Code is the ICMPv6 code of the message, which indicates the result of
+	// the query, such as NIReplySuccess.
+	Code uint8
+
+	// QType is the type of information being returned, and Flags contains
+	// QType-specific flags.
+	QType NIQType
+	Flags uint16
+
+	// Nonce is an opaque value copied from the NodeInformationQuery.
+	Nonce [8]byte
+
+	// Data contains the information requested by the query in its raw wire
+	// format, as described in RFC 4620, Section 6.
+	Data []byte
+}
+
+// Type implements Message.
+func (r *NodeInformationReply) Type() ipv6.ICMPType { return ipv6.ICMPTypeNodeInformationResponse }
+
+// Clone implements Message.
+func (r *NodeInformationReply) Clone() Message {
+	c := *r
+	c.Data = bytes.Clone(r.Data)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (r *NodeInformationReply) MarshalBinary() ([]byte, error) { return r.appendBinary(nil) }
+
+// UnmarshalBinary implements Message. The ICMPv6 code is not part of b, so
+// Code is left unchanged.
+func (r *NodeInformationReply) UnmarshalBinary(b []byte) error { return r.unmarshal(nil, b) }
+
+// Equal reports whether r and x are the same NodeInformationReply.
+func (r *NodeInformationReply) Equal(x *NodeInformationReply) bool {
+	if r == nil || x == nil {
+		return r == x
+	}
+
+	return r.Code == x.Code &&
+		r.QType == x.QType &&
+		r.Flags == x.Flags &&
+		r.Nonce == x.Nonce &&
+		bytes.Equal(r.Data, x.Data)
+}
+
+func (r *NodeInformationReply) code() uint8        { return r.Code }
+func (r *NodeInformationReply) setCode(code uint8) { r.Code = code }
+
+func (r *NodeInformationReply) appendBinary(b []byte) ([]byte, error) {
+	return appendNodeInformation(b, r.QType, r.Flags, r.Nonce, r.Data), nil
+}
+
+func (r *NodeInformationReply) unmarshal(p *Parser, b []byte) error {
+	qtype, flags, nonce, data, err := parseNodeInformation(b)
+	if err != nil {
+		return err
+	}
+
+	r.QType = qtype
+	r.Flags = flags
+	r.Nonce = nonce
+	r.Data = append(r.Data[:0], data...)
+
+	return nil
+}
+
+// appendNodeInformation appends the body of a Node Information message to b.
+func appendNodeInformation(b []byte, qtype NIQType, flags uint16, nonce [8]byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(qtype))
+	b = binary.BigEndian.AppendUint16(b, flags)
+	b = append(b, nonce[:]...)
+	return append(b, data...)
+}
+
+// parseNodeInformation parses the body of a Node Information message. The
+// returned data aliases b.
+func parseNodeInformation(b []byte) (NIQType, uint16, [8]byte, []byte, error) {
+	var nonce [8]byte
+	if len(b) < niLen {
+		return 0, 0, nonce, nil, io.ErrUnexpectedEOF
+	}
+
+	copy(nonce[:], b[4:12])
+	return NIQType(binary.BigEndian.Uint16(b[0:2])),
+		binary.BigEndian.Uint16(b[2:4]),
+		nonce,
+		b[niLen:],
+		nil
+}
+
+var _ Message = &ICMPError{}
+
+// An ICMPError is an ICMPv6 error message, as described in RFC 4443, Section
+// 3. ICMPv6 errors are not NDP messages, but may be sent in response to NDP
+// messages and can help diagnose why those messages went unanswered.
+type ICMPError struct {
+	// ErrorType is the ICMPv6 type of the error: one of
+	// ipv6.ICMPTypeDestinationUnreachable, ipv6.ICMPTypePacketTooBig,
+	// ipv6.ICMPTypeTimeExceeded, or ipv6.ICMPTypeParameterProblem.
+	ErrorType ipv6.ICMPType
+
+	// Code is the ICMPv6 code of the error, which further describes it.
+	Code uint8
+
+	// Param is the MTU of a packet too big error, or the pointer of a
+	// parameter problem error. It is unused by other errors.
+	Param uint32
+
+	// Invoking contains as much of the packet which invoked the error as
+	// fit in the error, beginning with its IPv6 header.
+	Invoking []byte
+}
+
+// Type implements Message.
+func (e *ICMPError) Type() ipv6.ICMPType { return e.ErrorType }
+
+// Clone implements Message.
+func (e *ICMPError) Clone() Message {
+	c := *e
+	c.Invoking = bytes.Clone(e.Invoking)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (e *ICMPError) MarshalBinary() ([]byte, error) { return e.appendBinary(nil) }
+
+// UnmarshalBinary implements Message. The ICMPv6 type and code are not part of
+// b, so ErrorType and Code are left unchanged.
+func (e *ICMPError) UnmarshalBinary(b []byte) error { return e.unmarshal(nil, b) }
+
+// Equal reports whether e and x are the same ICMPError.
+func (e *ICMPError) Equal(x *ICMPError) bool {
+	if e == nil || x == nil {
+		return e == x
+	}
+
+	return e.ErrorType == x.ErrorType &&
+		e.Code == x.Code &&
+		e.Param == x.Param &&
+		bytes.Equal(e.Invoking, x.Invoking)
+}
+
+// InvokingMessage parses the packet which invoked the error, and returns the
+// Message it carried and the destination address it was sent to. An error is
+// returned if the invoking packet was not an ICMPv6 message recognized by
+// ParseMessage, or if it was truncated.
+func (e *ICMPError) InvokingMessage() (Message, netip.Addr, error) {
+	b := e.Invoking
+	if len(b) < ipv6Len || b[0]>>4 != 6 {
+		return nil, netip.Addr{}, errors.New("ndp: invoking packet is not an IPv6 packet")
+	}
+
+	// Extension headers are not supported.
+	const icmpv6 = 58
+	if b[6] != icmpv6 {
+		return nil, netip.Addr{}, fmt.Errorf("ndp: invoking packet next header is %d, not ICMPv6", b[6])
+	}
+
+	m, err := ParseMessage(b[ipv6Len:])
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+
+	return m, netip.AddrFrom16([16]byte(b[24:ipv6Len])), nil
+}
+
+func (e *ICMPError) code() uint8        { return e.Code }
+func (e *ICMPError) setCode(code uint8) { e.Code = code }
+
+func (e *ICMPError) appendBinary(b []byte) ([]byte, error) {
+	// Per RFC 4443, Section 2.1, error messages have types 0 through 127,
+	// and type 0 is reserved.
+	if e.ErrorType < 1 || e.ErrorType > 127 {
+		return nil, fmt.Errorf("ndp: invalid ICMPv6 error type: %d", e.ErrorType)
+	}
+
+	b = binary.BigEndian.AppendUint32(b, e.Param)
+	return append(b, e.Invoking...), nil
+}
+
+func (e *ICMPError) unmarshal(p *Parser, b []byte) error {
+	if len(b) < errLen {
+		return io.ErrUnexpectedEOF
+	}
+
+	e.Param = binary.BigEndian.Uint32(b[:errLen])
+	e.Invoking = append(e.Invoking[:0], b[errLen:]...)
+
+	return nil
+}
+
+var _ Message = &RawMessage{}
+
+// A RawMessage is an ICMPv6 message of a type which is not implemented by
+// this package. RawMessages are only produced by a Parser with RawMessages
+// set, and for malformed messages by a Conn with SetMalformedMessages enabled.
+type RawMessage struct {
+	// MessageType and Code are the ICMPv6 type and code of the message.
+	MessageType ipv6.ICMPType
+	Code        uint8
+
+	// Body is the message body which follows the ICMPv6 header.
+	Body []byte
+
+	// Err is the error which occurred while parsing a malformed message
+	// returned by a Conn with SetMalformedMessages enabled, and nil otherwise.
+	// Err is not part of the wire format and is ignored by Equal.
+	Err error
+}
+
+// Type implements Message.
+func (rm *RawMessage) Type() ipv6.ICMPType { return rm.MessageType }
+
+// Clone implements Message.
+func (rm *RawMessage) Clone() Message {
+	c := *rm
+	c.Body = bytes.Clone(rm.Body)
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (rm *RawMessage) MarshalBinary() ([]byte, error) { return rm.appendBinary(nil) }
+
+// UnmarshalBinary implements Message. The ICMPv6 type and code are not part of
+// b, so MessageType and Code are left unchanged.
+func (rm *RawMessage) UnmarshalBinary(b []byte) error { return rm.unmarshal(nil, b) }
+
+// Equal reports whether rm and x are the same RawMessage.
+func (rm *RawMessage) Equal(x *RawMessage) bool {
+	if rm == nil || x == nil {
+		return rm == x
+	}
+
+	return rm.MessageType == x.MessageType &&
+		rm.Code == x.Code &&
+		bytes.Equal(rm.Body, x.Body)
+}
+
+func (rm *RawMessage) code() uint8        { return rm.Code }
+func (rm *RawMessage) setCode(code uint8) { rm.Code = code }
+
+func (rm *RawMessage) appendBinary(b []byte) ([]byte, error) {
+	return append(b, rm.Body...), nil
+}
+
+func (rm *RawMessage) unmarshal(_ *Parser, b []byte) error {
+	rm.Body = append(rm.Body[:0], b...)
+	rm.Err = nil
+	return nil
+}
+
+// Possible RouterRenumbering Code values, as described in RFC 2894, Section
+// 3.1.
+const (
+	RRCommand             uint8 = 0
+	RRResult              uint8 = 1
+	RRSequenceNumberReset uint8 = 255
+)
+
+var _ Message = &RouterRenumbering{}
+
+// A RouterRenumbering is a Router Renumbering message as described in RFC
+// 2894, Section 3.1.
+type RouterRenumbering struct {
+	// Code specifies whether the message is a command, a result, or a
+	// sequence number reset, such as RRCommand.
+	Code uint8
+
+	SequenceNumber uint32
+	SegmentNumber  uint8
+
+	// Flags.
+	Test                bool
+	ResultRequested     bool
+	AllInterfaces       bool
+	SiteSpecific        bool
+	ProcessedPreviously bool
+
+	// MaxDelay is encoded in milliseconds.
+	MaxDelay time.Duration
+
+	// Operations are carried by commands, and Results are carried by
+	// results. Both are empty for sequence number resets.
+	Operations []PrefixControlOperation
+	Results    []MatchResult
+}
+
+// An RROpCode is the operation performed by a PrefixControlOperation.
+type RROpCode uint8
+
+// Possible RROpCode values.
+const (
+	RRAdd       RROpCode = 1
+	RRChange    RROpCode = 2
+	RRSetGlobal RROpCode = 3
+)
+
+// A PrefixControlOperation is a Prefix Control Operation of a router
+// renumbering command, consisting of a Match-Prefix Part and zero or more
+// Use-Prefix Parts, as described in RFC 2894, Section 3.2.
+type PrefixControlOperation struct {
+	OpCode  RROpCode
+	Ordinal uint8
+
+	// MatchPrefix contains the match prefix and match length, and
+	// MinLength and MaxLength bound the lengths of prefixes which match.
+	MatchPrefix netip.Prefix
+	MinLength   uint8
+	MaxLength   uint8
+
+	UsePrefixes []UsePrefix
+}
+
+// A UsePrefix is a Use-Prefix Part of a PrefixControlOperation, as described
+// in RFC 2894, Section 3.2.2.
+type UsePrefix struct {
+	// Prefix contains the use prefix and use length.
+	Prefix     netip.Prefix
+	KeepLength uint8
+
+	// FlagMask selects the router advertisement prefix flags which are set
+	// from RAFlags.
+	FlagMask uint8
+	RAFlags  uint8
+
+	ValidLifetime      time.Duration
+	PreferredLifetime  time.Duration
+	DecrementValid     bool
+	DecrementPreferred bool
+}
+
+// A MatchResult is a Match-Result entry of a router renumbering result, as
+// described in RFC 2894, Section 3.3.
+type MatchResult struct {
+	Bounded   bool
+	Forbidden bool
+	Ordinal   uint8
+
+	// MatchedPrefix contains the matched prefix and matched length.
+	MatchedPrefix  netip.Prefix
+	InterfaceIndex uint32
+}
+
+// Lengths of each router renumbering structure.
+const (
+	rrMatchLen  = 24
+	rrUseLen    = 32
+	rrResultLen = 24
+)
+
+// Type implements Message.
+func (rr *RouterRenumbering) Type() ipv6.ICMPType { return ipv6.ICMPTypeRouterRenumbering }
+
+// Clone implements Message.
+func (rr *RouterRenumbering) Clone() Message {
+	c := *rr
+	if rr.Operations != nil {
+		c.Operations = make([]PrefixControlOperation, 0, len(rr.Operations))
+		for _, o := range rr.Operations {
+			if o.UsePrefixes != nil {
+				o.UsePrefixes = append([]UsePrefix(nil), o.UsePrefixes...)
+			}
+			c.Operations = append(c.Operations, o)
+		}
+	}
+	if rr.Results != nil {
+		c.Results = append([]MatchResult(nil), rr.Results...)
+	}
+
+	return &c
+}
+
+// MarshalBinary implements Message.
+func (rr *RouterRenumbering) MarshalBinary() ([]byte, error) { return rr.appendBinary(nil) }
+
+// UnmarshalBinary implements Message. The ICMPv6 code is not part of b, so
+// Code is left unchanged and is used to determine the contents of b.
+func (rr *RouterRenumbering) UnmarshalBinary(b []byte) error { return rr.unmarshal(nil, b) }
+
+// Equal reports whether rr and x are the same RouterRenumbering.
+func (rr *RouterRenumbering) Equal(x *RouterRenumbering) bool {
+	if rr == nil || x == nil {
+		return rr == x
+	}
+
+	if rr.Code != x.Code ||
+		rr.SequenceNumber != x.SequenceNumber ||
+		rr.SegmentNumber != x.SegmentNumber ||
+		rr.Test != x.Test ||
+		rr.ResultRequested != x.ResultRequested ||
+		rr.AllInterfaces != x.AllInterfaces ||
+		rr.SiteSpecific != x.SiteSpecific ||
+		rr.ProcessedPreviously != x.ProcessedPreviously ||
+		rr.MaxDelay != x.MaxDelay ||
+		len(rr.Operations) != len(x.Operations) ||
+		len(rr.Results) != len(x.Results) {
+		return false
+	}
+
+	for i := range rr.Operations {
+		xo, yo := rr.Operations[i], x.Operations[i]
+		if xo.OpCode != yo.OpCode ||
+			xo.Ordinal != yo.Ordinal ||
+			xo.MatchPrefix != yo.MatchPrefix ||
+			xo.MinLength != yo.MinLength ||
+			xo.MaxLength != yo.MaxLength ||
+			len(xo.UsePrefixes) != len(yo.UsePrefixes) {
+			return false
+		}
+
+		for j := range xo.UsePrefixes {
+			if xo.UsePrefixes[j] != yo.UsePrefixes[j] {
+				return false
+			}
+		}
+	}
+
+	for i := range rr.Results {
+		if rr.Results[i] != x.Results[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (rr *RouterRenumbering) code() uint8        { return rr.Code }
+func (rr *RouterRenumbering) setCode(code uint8) { rr.Code = code }
+
+func (rr *RouterRenumbering) appendBinary(b []byte) ([]byte, error) {
+	switch rr.Code {
+	case RRCommand:
+		if len(rr.Results) > 0 {
+			return nil, errors.New("ndp: router renumbering command cannot contain match results")
+		}
+	case RRResult:
+		if len(rr.Operations) > 0 {
+			return nil, errors.New("ndp: router renumbering result cannot contain prefix control operations")
+		}
+	case RRSequenceNumberReset:
+		if len(rr.Operations) > 0 || len(rr.Results) > 0 {
+			return nil, errors.New("ndp: router renumbering sequence number reset must be empty")
+		}
+	default:
+		return nil, fmt.Errorf("ndp: unknown router renumbering code: %d", rr.Code)
+	}
+
+	var flags uint8
+	if rr.Test {
+		flags |= 1 << 7
+	}
+	if rr.ResultRequested {
+		flags |= 1 << 6
+	}
+	if rr.AllInterfaces {
+		flags |= 1 << 5
+	}
+	if rr.SiteSpecific {
+		flags |= 1 << 4
+	}
+	if rr.ProcessedPreviously {
+		flags |= 1 << 3
+	}
+
+	delay := rr.MaxDelay.Milliseconds()
+	if delay < 0 || delay > math.MaxUint16 {
+		return nil, fmt.Errorf("ndp: router renumbering maximum delay out of range: %s", rr.MaxDelay)
+	}
+
+	b = binary.BigEndian.AppendUint32(b, rr.SequenceNumber)
+	b = append(b, rr.SegmentNumber, flags)
+	b = binary.BigEndian.AppendUint16(b, uint16(delay))
+
+	// Reserved.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
+
+	for _, o := range rr.Operations {
+		var err error
+		b, err = o.appendBinary(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range rr.Results {
+		var err error
+		b, err = r.appendBinary(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (rr *RouterRenumbering) unmarshal(p *Parser, b []byte) error {
+	if len(b) < rrLen {
+		return io.ErrUnexpectedEOF
+	}
+
+	var (
+		ops     = rr.Operations[:0]
+		results = rr.Results[:0]
+	)
+
+	body := b[rrLen:]
+	switch rr.Code {
+	case RRCommand:
+		for len(body) > 0 {
+			var (
+				o   PrefixControlOperation
+				n   int
+				err error
+			)
+
+			// Reuse any Use-Prefix Parts from a previous operation.
+			if len(ops) < cap(ops) {
+				o.UsePrefixes = ops[:len(ops)+1][len(ops)].UsePrefixes
+			}
+
+			if n, err = o.unmarshal(body); err != nil {
+				return err
+			}
+
+			ops = append(ops, o)
+			body = body[n:]
+		}
+	case RRResult:
+		if len(body)%rrResultLen != 0 {
+			return io.ErrUnexpectedEOF
+		}
+
+		for ; len(body) > 0; body = body[rrResultLen:] {
+			var r MatchResult
+			if err := r.unmarshal(body[:rrResultLen]); err != nil {
+				return err
+			}
+
+			results = append(results, r)
+		}
+	case RRSequenceNumberReset:
+		// No body.
+	default:
+		return fmt.Errorf("ndp: unknown router renumbering code: %d", rr.Code)
+	}
+
+	if len(ops) == 0 {
+		ops = nil
+	}
+	if len(results) == 0 {
+		results = nil
+	}
+
+	flags := b[5]
+	*rr = RouterRenumbering{
+		Code:                rr.Code,
+		SequenceNumber:      binary.BigEndian.Uint32(b[0:4]),
+		SegmentNumber:       b[4],
+		Test:                (flags & 0x80) != 0,
+		ResultRequested:     (flags & 0x40) != 0,
+		AllInterfaces:       (flags & 0x20) != 0,
+		SiteSpecific:        (flags & 0x10) != 0,
+		ProcessedPreviously: (flags & 0x08) != 0,
+		MaxDelay:            time.Duration(binary.BigEndian.Uint16(b[6:8])) * time.Millisecond,
+		Operations:          ops,
+		Results:             results,
+	}
+
+	return nil
+}
+
+func (o *PrefixControlOperation) appendBinary(b []byte) ([]byte, error) {
+	if err := checkIPv6Prefix(o.MatchPrefix); err != nil {
+		return nil, err
+	}
+
+	// OpLength is in units of 8 bytes: 3 for the Match-Prefix Part and 4
+	// for each Use-Prefix Part.
+	l := 3 + 4*len(o.UsePrefixes)
+	if l > math.MaxUint8 {
+		return nil, errors.New("ndp: too many use prefixes in prefix control operation")
+	}
+
+	b = append(b,
+		byte(o.OpCode), byte(l), o.Ordinal, byte(o.MatchPrefix.Bits()),
+		o.MinLength, o.MaxLength, 0x00, 0x00,
+	)
+	ip := o.MatchPrefix.Addr().As16()
+	b = append(b, ip[:]...)
+
+	for _, u := range o.UsePrefixes {
+		if err := checkIPv6Prefix(u.Prefix); err != nil {
+			return nil, err
+		}
+
+		var vp uint8
+		if u.DecrementValid {
+			vp |= 1 << 7
+		}
+		if u.DecrementPreferred {
+			vp |= 1 << 6
+		}
+
+		b = append(b, byte(u.Prefix.Bits()), u.KeepLength, u.FlagMask, u.RAFlags)
+		b = binary.BigEndian.AppendUint32(b, uint32(u.ValidLifetime.Seconds()))
+		b = binary.BigEndian.AppendUint32(b, uint32(u.PreferredLifetime.Seconds()))
+		b = append(b, vp, 0x00, 0x00, 0x00)
+
+		ip := u.Prefix.Addr().As16()
+		b = append(b, ip[:]...)
+	}
+
+	return b, nil
+}
+
+// unmarshal unmarshals a PrefixControlOperation from the beginning of b and
+// returns the number of bytes consumed.
+func (o *PrefixControlOperation) unmarshal(b []byte) (int, error) {
+	if len(b) < rrMatchLen {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	// OpLength must cover the Match-Prefix Part and whole Use-Prefix Parts.
+	l := int(b[1]) * 8
+	if l < rrMatchLen || (l-rrMatchLen)%rrUseLen != 0 {
+		return 0, fmt.Errorf("ndp: invalid prefix control operation length: %d", b[1])
+	}
+	if l > len(b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	match, err := rrPrefix(b[3], b[8:24])
+	if err != nil {
+		return 0, err
+	}
+
+	uses := o.UsePrefixes[:0]
+	for ub := b[rrMatchLen:l]; len(ub) > 0; ub = ub[rrUseLen:] {
+		p, err := rrPrefix(ub[0], ub[16:32])
+		if err != nil {
+			return 0, err
+		}
+
+		uses = append(uses, UsePrefix{
+			Prefix:             p,
+			KeepLength:         ub[1],
+			FlagMask:           ub[2],
+			RAFlags:            ub[3],
+			ValidLifetime:      time.Duration(binary.BigEndian.Uint32(ub[4:8])) * time.Second,
+			PreferredLifetime:  time.Duration(binary.BigEndian.Uint32(ub[8:12])) * time.Second,
+			DecrementValid:     (ub[12] & 0x80) != 0,
+			DecrementPreferred: (ub[12] & 0x40) != 0,
+		})
+	}
+	if len(uses) == 0 {
+		uses = nil
+	}
+
+	*o = PrefixControlOperation{
+		OpCode:      RROpCode(b[0]),
+		Ordinal:     b[2],
+		MatchPrefix: match,
+		MinLength:   b[4],
+		MaxLength:   b[5],
+		UsePrefixes: uses,
+	}
+
+	return l, nil
+}
+
+func (r *MatchResult) appendBinary(b []byte) ([]byte, error) {
+	if err := checkIPv6Prefix(r.MatchedPrefix); err != nil {
+		return nil, err
+	}
+
+	var flags uint8
+	if r.Bounded {
+		flags |= 1 << 1
+	}
+	if r.Forbidden {
+		flags |= 1 << 0
+	}
+
+	b = append(b, 0x00, flags, r.Ordinal, byte(r.MatchedPrefix.Bits()))
+	b = binary.BigEndian.AppendUint32(b, r.InterfaceIndex)
+
+	ip := r.MatchedPrefix.Addr().As16()
+	return append(b, ip[:]...), nil
+}
+
+func (r *MatchResult) unmarshal(b []byte) error {
+	p, err := rrPrefix(b[3], b[8:24])
+	if err != nil {
+		return err
+	}
+
+	*r = MatchResult{
+		Bounded:        (b[1] & 0x02) != 0,
+		Forbidden:      (b[1] & 0x01) != 0,
+		Ordinal:        b[2],
+		MatchedPrefix:  p,
+		InterfaceIndex: binary.BigEndian.Uint32(b[4:8]),
+	}
+
+	return nil
+}
+
+// rrPrefix creates a router renumbering prefix from a length and 16 byte
+// IPv6 address.
+func rrPrefix(length uint8, b []byte) (netip.Prefix, error) {
+	p := netip.PrefixFrom(netip.AddrFrom16([16]byte(b)), int(length))
+	if !p.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("ndp: invalid router renumbering prefix length: %d", length)
+	}
+
+	return p, nil
+}
+
+// checkIPv6Prefix verifies that p is a valid IPv6 prefix.
+func checkIPv6Prefix(p netip.Prefix) error {
+	if !p.IsValid() {
+		return fmt.Errorf("ndp: invalid IPv6 prefix: %q", p)
+	}
+
+	return checkIPv6(p.Addr())
+}
+
+// checkIPv6 verifies that ip is an IPv6 address.
+func checkIPv6(ip netip.Addr) error {
+	if !ip.Is6() || ip.Is4In6() {
+		return fmt.Errorf("ndp: invalid IPv6 address: %q", ip)
+	}
+
+	return nil
+}
+
+// errPreferenceReserved is returned when the reserved Preference value is
+// sent, or received by a Parser with StrictPreference set.
+var errPreferenceReserved = errors.New("ndp: cannot use reserved router selection preference value")
+
 // checkPreference checks the validity of a Preference value.
 func checkPreference(prf Preference) error {
 	switch prf {
 	case Low, Medium, High:
 		return nil
 	case prfReserved:
-		return errors.New("ndp: cannot use reserved router selection preference value")
+		return errPreferenceReserved
 	default:
 		return fmt.Errorf("ndp: unknown router selection preference value: %d", prf)
 	}
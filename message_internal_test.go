@@ -13,7 +13,7 @@ func TestRouterAdvertisementUnmarshalReservedPrf(t *testing.T) {
 	b := []byte{0x0, byte(prfReserved) << 3, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}
 
 	ra := new(RouterAdvertisement)
-	if err := ra.unmarshal(b); err != nil {
+	if err := ra.unmarshal(nil, b); err != nil {
 		t.Fatalf("failed to unmarshal: %v", err)
 	}
 
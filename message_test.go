@@ -2,13 +2,17 @@ package ndp_test
 
 import (
 	"errors"
+	"net"
 	"net/netip"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mdlayher/ndp"
 	"github.com/mdlayher/ndp/internal/ndptest"
+	"golang.org/x/net/ipv6"
 )
 
 // A messageSub is a sub-test structure for Message marshal/unmarshal tests.
@@ -45,6 +49,41 @@ func TestMarshalParseMessage(t *testing.T) {
 			header: []byte{133, 0x00, 0x00, 0x00},
 			subs:   rsTests(),
 		},
+		{
+			name:   "IND solicitation",
+			header: []byte{141, 0x00, 0x00, 0x00},
+			subs:   insTests(),
+		},
+		{
+			name:   "IND advertisement",
+			header: []byte{142, 0x00, 0x00, 0x00},
+			subs:   inaTests(),
+		},
+		{
+			name:   "NI query",
+			header: []byte{139, ndp.NISubjectName, 0x00, 0x00},
+			subs:   niqTests(),
+		},
+		{
+			name:   "NI reply",
+			header: []byte{140, ndp.NIReplyRefused, 0x00, 0x00},
+			subs:   nirTests(),
+		},
+		{
+			name:   "ICMP error",
+			header: []byte{4, 0x01, 0x00, 0x00},
+			subs:   icmpErrorTests(),
+		},
+		{
+			name:   "RR command",
+			header: []byte{138, ndp.RRCommand, 0x00, 0x00},
+			subs:   rrCommandTests(),
+		},
+		{
+			name:   "RR result",
+			header: []byte{138, ndp.RRResult, 0x00, 0x00},
+			subs:   rrResultTests(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -167,64 +206,1377 @@ func TestParseMessageError(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:   "RR command",
+			header: []byte{138, 0x00, 0x00, 0x00},
+			subs: []sub{
+				{
+					name: "short",
+					bs:   [][]byte{ndptest.Zero(11)},
+				},
+				{
+					name: "bad operation length",
+					bs: [][]byte{
+						ndptest.Zero(12),
+						{0x01, 0x04},
+						ndptest.Zero(30),
+					},
+				},
+				{
+					name: "bad match length",
+					bs: [][]byte{
+						ndptest.Zero(12),
+						{0x01, 0x03, 0x00, 0xff},
+						ndptest.Zero(20),
+					},
+				},
+			},
+		},
+		{
+			name:   "RR unknown code",
+			header: []byte{138, 0x02, 0x00, 0x00},
+			subs: []sub{{
+				name: "unknown",
+				bs:   [][]byte{ndptest.Zero(12)},
+			}},
+		},
+		{
+			name:   "NI query",
+			header: []byte{139, 0x00, 0x00, 0x00},
+			subs: []sub{
+				{
+					name: "short",
+					bs:   [][]byte{ndptest.Zero(11)},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, st := range tt.subs {
+				t.Run(st.name, func(t *testing.T) {
+					ttb := append(tt.header, ndptest.Merge(st.bs)...)
+					_, err := ndp.ParseMessage(ttb)
+					if err == nil {
+						t.Fatal("expected an error, but none occurred")
+					}
+
+					// Rather then exporting errParseMessage, we will just check
+					// for a wrapped substring here for now.
+					perr := errors.Unwrap(err)
+					if perr.Error() != "failed to parse message" {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestMarshalMessageChecksum(t *testing.T) {
+	var (
+		source      = netip.MustParseAddr("2001:db8::10")
+		destination = netip.MustParseAddr("2001:db8::1")
+	)
+
+	message := &ndp.NeighborAdvertisement{
+		Solicited:     true,
+		Override:      true,
+		TargetAddress: source,
+		Options: []ndp.Option{&ndp.LinkLayerAddress{
+			Direction: ndp.Target,
+			Addr:      ndptest.MAC,
+		}},
+	}
+
+	buf, err := ndp.MarshalMessageChecksum(message, source, destination)
+	if err != nil {
+		t.Fatalf("failed to marshal message with checksum: %v", err)
+	}
+
+	// Checksum is in bytes 3 and 4.
+	if diff := cmp.Diff(buf[2:4], []uint8{0x10, 0x0c}); diff != "" {
+		t.Fatalf("unexpected set checksum (-want +got):\n%s", diff)
+	}
+
+	// Check that MarshalMessage has a 0 checksum.
+	buf, err = ndp.MarshalMessage(message)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if diff := cmp.Diff(buf[2:4], []uint8{0, 0}); diff != "" {
+		t.Fatalf("unexpected unset checksum (-want +got):\n%s", diff)
+	}
+}
+
+func TestAppendMessage(t *testing.T) {
+	ra := testRouterAdvertisement()
+
+	want, err := ndp.MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	// Existing contents of the buffer must be preserved.
+	prefix := []byte{0xff, 0xff}
+	b, err := ndp.AppendMessage(prefix, ra)
+	if err != nil {
+		t.Fatalf("failed to append message: %v", err)
+	}
+
+	if diff := cmp.Diff(append(prefix, want...), b); diff != "" {
+		t.Fatalf("unexpected message bytes (-want +got):\n%s", diff)
+	}
+
+	// Reusing a buffer with sufficient capacity must not allocate.
+	buf := make([]byte, 0, 1500)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := ndp.AppendMessage(buf[:0], ra); err != nil {
+			t.Fatalf("failed to append message: %v", err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatalf("unexpected number of allocations: %v", allocs)
+	}
+}
+
+func TestAppendOptions(t *testing.T) {
+	// All Options implemented by this package can be appended.
+	for _, ot := range ndp.OptionTypes() {
+		if ot.Code == privateCode {
+			// Registered by TestRegisterOption.
+			continue
+		}
+
+		if _, ok := ot.New().(ndp.OptionAppender); !ok {
+			t.Fatalf("%s option does not implement OptionAppender", ot.Name)
+		}
+	}
+
+	options := make([]ndp.Option, 0, 256)
+	for i := 0; i < cap(options); i++ {
+		options = append(options, &ndp.PrefixInformation{
+			PrefixLength: 64,
+			Prefix:       netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 0x00, byte(i)}),
+		})
+	}
+
+	var want []byte
+	for _, o := range options {
+		b, err := o.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal option: %v", err)
+		}
+
+		want = append(want, b...)
+	}
+
+	b, err := ndp.AppendOptions([]byte{0xff}, options)
+	if err != nil {
+		t.Fatalf("failed to append options: %v", err)
+	}
+	if diff := cmp.Diff(append([]byte{0xff}, want...), b); diff != "" {
+		t.Fatalf("unexpected option bytes (-want +got):\n%s", diff)
+	}
+
+	// Reusing a buffer with sufficient capacity must not allocate.
+	buf := make([]byte, 0, len(want))
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := ndp.AppendOptions(buf[:0], options); err != nil {
+			t.Fatalf("failed to append options: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("unexpected number of allocations: %v", allocs)
+	}
+
+	// Options implemented outside of this package are verified.
+	if _, err := ndp.AppendOptions(nil, []ndp.Option{&appenderOption{code: privateCode}}); err != nil {
+		t.Fatalf("failed to append external option: %v", err)
+	}
+	if _, err := ndp.AppendOptions(nil, []ndp.Option{&appenderOption{code: privateCode + 1}}); err == nil {
+		t.Fatal("expected an error appending invalid external option, but none occurred")
+	}
+}
+
+// An appenderOption is a privateOption which appends its binary form with an
+// arbitrary code.
+type appenderOption struct {
+	privateOption
+	code uint8
+}
+
+func (ao *appenderOption) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, ao.code, 1)
+	return append(b, ao.Data[:]...), nil
+}
+
+func TestMarshaledLength(t *testing.T) {
+	ra := testRouterAdvertisement()
+
+	b, err := ndp.MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	n, err := ndp.MarshaledLength(ra)
+	if err != nil {
+		t.Fatalf("failed to compute length: %v", err)
+	}
+	if n != len(b) {
+		t.Fatalf("unexpected length: want %d, got %d", len(b), n)
+	}
+
+	// Messages which cannot be marshaled have no length.
+	ra.Options = append(ra.Options, &ndp.LinkLayerAddress{Direction: ndp.Source})
+	if _, err := ndp.MarshaledLength(ra); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestUnmarshalMessage(t *testing.T) {
+	want := testRouterAdvertisement()
+
+	b, err := ndp.MarshalMessage(want)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	// Unmarshal into the same message twice, verifying that the Options from
+	// the first pass are reused by the second.
+	ra := new(ndp.RouterAdvertisement)
+	if err := ndp.UnmarshalMessage(b, ra); err != nil {
+		t.Fatalf("failed to unmarshal message: %v", err)
+	}
+
+	options := ra.Options
+	if err := ndp.UnmarshalMessage(b, ra); err != nil {
+		t.Fatalf("failed to unmarshal message again: %v", err)
+	}
+
+	if diff := cmp.Diff(want, ra, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	for i := range options {
+		if options[i] != ra.Options[i] {
+			t.Fatalf("option %d was not reused", i)
+		}
+	}
+
+	// Messages of a different type cannot be unmarshaled into ra.
+	b, err = ndp.MarshalMessage(&ndp.RouterSolicitation{})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if err := ndp.UnmarshalMessage(b, ra); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestMessageEqual(t *testing.T) {
+	ra := testRouterAdvertisement()
+	if !ra.Equal(testRouterAdvertisement()) {
+		t.Fatal("identical router advertisements are not equal")
+	}
+
+	// Changes to either the message fields or its options are detected.
+	hl := testRouterAdvertisement()
+	hl.CurrentHopLimit++
+	if ra.Equal(hl) {
+		t.Fatal("router advertisements with different hop limits are equal")
+	}
+
+	opts := testRouterAdvertisement()
+	opts.Options[1] = ndp.NewMTU(9000)
+	if ra.Equal(opts) {
+		t.Fatal("router advertisements with different options are equal")
+	}
+
+	na := &ndp.NeighborAdvertisement{
+		Solicited:     true,
+		TargetAddress: netip.MustParseAddr("fe80::1"),
+	}
+	if na.Equal(&ndp.NeighborAdvertisement{TargetAddress: na.TargetAddress}) {
+		t.Fatal("neighbor advertisements with different flags are equal")
+	}
+
+	// Nil messages are only equal to each other.
+	if ra.Equal(nil) {
+		t.Fatal("router advertisement is equal to nil")
+	}
+	if (*ndp.RouterAdvertisement)(nil).Equal(ra) {
+		t.Fatal("nil router advertisement is equal to non-nil")
+	}
+	if !(*ndp.RouterAdvertisement)(nil).Equal(nil) {
+		t.Fatal("nil router advertisements are not equal")
+	}
+}
+
+func TestMessageClone(t *testing.T) {
+	ra := testRouterAdvertisement()
+	ra.Options[0] = &ndp.LinkLayerAddress{
+		Direction: ndp.Source,
+		Addr:      net.HardwareAddr{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad},
+	}
+
+	m := ra.Clone()
+	if diff := cmp.Diff(ra, m, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected clone (-want +got):\n%s", diff)
+	}
+
+	// Modify the original in place and verify the clone is unaffected.
+	want := m.Clone()
+
+	ra.CurrentHopLimit = 1
+	ra.Options[0].(*ndp.LinkLayerAddress).Addr[0] = 0xff
+	ra.Options[3].(*ndp.RecursiveDNSServer).Servers[0] = netip.MustParseAddr("2001:db8::ffff")
+	ra.Options = ra.Options[:1]
+
+	if diff := cmp.Diff(want, m, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("clone was modified (-want +got):\n%s", diff)
+	}
+}
+
+// A privateMessage is a Message implemented outside of package ndp, using an
+// ICMPv6 type reserved for private experimentation.
+type privateMessage struct {
+	Data []byte
+}
+
+const privateType ipv6.ICMPType = 200
+
+var registerPrivate sync.Once
+
+func (*privateMessage) Type() ipv6.ICMPType { return privateType }
+
+func (pm *privateMessage) Clone() ndp.Message {
+	return &privateMessage{Data: append([]byte(nil), pm.Data...)}
+}
+
+func (pm *privateMessage) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), pm.Data...), nil
+}
+
+func (pm *privateMessage) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty private message")
+	}
+
+	pm.Data = append(pm.Data[:0], b...)
+	return nil
+}
+
+func TestRegisterMessage(t *testing.T) {
+	want := &privateMessage{Data: []byte{0xde, 0xad, 0xbe, 0xef}}
+
+	b, err := ndp.MarshalMessage(want)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	// Registration is global, so only register once when tests are run
+	// multiple times.
+	registerPrivate.Do(func() {
+		if _, err := ndp.ParseMessage(b); err == nil {
+			t.Fatal("expected an error before registration, but none occurred")
+		}
+
+		ndp.RegisterMessage(privateType, func() ndp.Message { return new(privateMessage) })
+	})
+
+	got, err := ndp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	// Errors from a registered Message are reported by ParseMessage.
+	if _, err := ndp.ParseMessage(b[:4]); err == nil {
+		t.Fatal("expected an error for empty message, but none occurred")
+	}
+
+	for _, typ := range []ipv6.ICMPType{privateType, ipv6.ICMPTypeRouterAdvertisement} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("expected a panic registering %s, but none occurred", typ)
+				}
+			}()
+
+			ndp.RegisterMessage(typ, func() ndp.Message { return new(privateMessage) })
+		}()
+	}
+}
+
+// A privateOption is an Option implemented outside of package ndp, using an
+// option code reserved for experimentation.
+type privateOption struct {
+	Data [6]byte
+}
+
+const privateCode = ndp.OptionExperiment2
+
+var registerPrivateOption sync.Once
+
+func (*privateOption) Code() uint8 { return privateCode }
+
+func (po *privateOption) MarshalBinary() ([]byte, error) {
+	return append([]byte{privateCode, 1}, po.Data[:]...), nil
+}
+
+func (po *privateOption) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return errors.New("invalid private option length")
+	}
+
+	copy(po.Data[:], b[2:])
+	return nil
+}
+
+func TestRegisterOption(t *testing.T) {
+	want := &ndp.RouterSolicitation{
+		Options: []ndp.Option{
+			&privateOption{Data: [6]byte{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad}},
+			ndp.NewMTU(1500),
+		},
+	}
+
+	b, err := ndp.MarshalMessage(want)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	// Registration is global, so only register once when tests are run
+	// multiple times.
+	registerPrivateOption.Do(func() {
+		m, err := ndp.ParseMessage(b)
+		if err != nil {
+			t.Fatalf("failed to parse message: %v", err)
+		}
+		if _, ok := m.(*ndp.RouterSolicitation).Options[0].(*ndp.RawOption); !ok {
+			t.Fatal("expected a raw option before registration")
+		}
+
+		ndp.RegisterOption(privateCode, func() ndp.Option { return new(privateOption) })
+	})
+
+	got, err := ndp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	// Registered options are deep copied by Clone.
+	c := got.Clone().(*ndp.RouterSolicitation)
+	c.Options[0].(*privateOption).Data[0] = 0xff
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("clone modified original message (-want +got):\n%s", diff)
+	}
+
+	var found bool
+	for _, ot := range ndp.OptionTypes() {
+		if ot.Code == privateCode {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("registered option was not returned by OptionTypes")
+	}
+
+	for _, code := range []uint8{privateCode, 5} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("expected a panic registering %d, but none occurred", code)
+				}
+			}()
+
+			ndp.RegisterOption(code, func() ndp.Option { return new(privateOption) })
+		}()
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var (
+		pi1 = &ndp.PrefixInformation{PrefixLength: 64, Prefix: netip.MustParseAddr("2001:db8::")}
+		pi2 = &ndp.PrefixInformation{PrefixLength: 64, Prefix: netip.MustParseAddr("2001:db8:1::")}
+		mtu = ndp.NewMTU(1500)
+	)
+
+	ra := &ndp.RouterAdvertisement{
+		Options: []ndp.Option{pi1, mtu, pi2},
+	}
+
+	if diff := cmp.Diff([]*ndp.PrefixInformation{pi1, pi2}, ndp.Options[*ndp.PrefixInformation](ra), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected prefix information options (-want +got):\n%s", diff)
+	}
+	if got := ndp.Options[*ndp.RecursiveDNSServer](ra); got != nil {
+		t.Fatalf("unexpected RDNSS options: %v", got)
+	}
+
+	pi, ok := ndp.FirstOption[*ndp.PrefixInformation](ra)
+	if !ok || pi != pi1 {
+		t.Fatalf("unexpected first prefix information option: %v, %v", pi, ok)
+	}
+	if _, ok := ndp.FirstOption[*ndp.RecursiveDNSServer](ra); ok {
+		t.Fatal("unexpectedly found an RDNSS option")
+	}
+
+	// Messages which do not carry options have none of any type.
+	if _, ok := ndp.FirstOption[*ndp.MTU](&ndp.ICMPError{}); ok {
+		t.Fatal("unexpectedly found an MTU option in an ICMP error")
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	var (
+		mac1 = net.HardwareAddr{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad}
+		mac2 = net.HardwareAddr{0xde, 0xad, 0xbe, 0xef, 0xde, 0xaf}
+
+		pi = func(s string) *ndp.PrefixInformation {
+			pi, err := ndp.NewPrefixInformation(netip.MustParsePrefix(s), true, true, time.Hour, time.Hour)
+			if err != nil {
+				t.Fatalf("failed to create prefix information: %v", err)
+			}
+
+			return pi
+		}
+
+		dns1 = netip.MustParseAddr("2001:db8::1")
+		dns2 = netip.MustParseAddr("2001:db8::2")
+	)
+
+	in := []ndp.Option{
+		&ndp.DNSSearchList{Lifetime: time.Hour, DomainNames: []string{"foo.example"}},
+		&ndp.RecursiveDNSServer{Lifetime: time.Hour, Servers: []netip.Addr{dns1}},
+		pi("2001:db8:1::/64"),
+		ndp.NewMTU(1500),
+		&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: mac1},
+		&ndp.RecursiveDNSServer{Lifetime: time.Minute, Servers: []netip.Addr{dns2}},
+		&ndp.DNSSearchList{Lifetime: time.Hour, DomainNames: []string{"bar.example", "foo.example"}},
+		pi("2001:db8::/64"),
+		ndp.NewMTU(9000),
+		&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: mac2},
+		&ndp.RecursiveDNSServer{Lifetime: time.Hour, Servers: []netip.Addr{dns2, dns1}},
+		pi("2001:db8:1::/64"),
+	}
+
+	want := []ndp.Option{
+		&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: mac1},
+		pi("2001:db8:1::/64"),
+		pi("2001:db8::/64"),
+		ndp.NewMTU(1500),
+		&ndp.RecursiveDNSServer{Lifetime: time.Hour, Servers: []netip.Addr{dns1, dns2}},
+		&ndp.RecursiveDNSServer{Lifetime: time.Minute, Servers: []netip.Addr{dns2}},
+		&ndp.DNSSearchList{Lifetime: time.Hour, DomainNames: []string{"foo.example", "bar.example"}},
+	}
+
+	got := ndp.NormalizeOptions(in)
+	if diff := cmp.Diff(want, got, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected options (-want +got):\n%s", diff)
+	}
+
+	// The input options must not be modified.
+	if diff := cmp.Diff([]netip.Addr{dns1}, in[1].(*ndp.RecursiveDNSServer).Servers, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("input RDNSS option was modified (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"foo.example"}, in[0].(*ndp.DNSSearchList).DomainNames); diff != "" {
+		t.Fatalf("input DNSSL option was modified (-want +got):\n%s", diff)
+	}
+
+	if got := ndp.NormalizeOptions(nil); got != nil {
+		t.Fatalf("unexpected options for nil input: %v", got)
+	}
+}
+
+func TestPreferenceText(t *testing.T) {
+	for _, prf := range []ndp.Preference{ndp.Low, ndp.Medium, ndp.High} {
+		b, err := prf.MarshalText()
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", prf, err)
+		}
+
+		var got ndp.Preference
+		if err := got.UnmarshalText(b); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", b, err)
+		}
+		if diff := cmp.Diff(prf, got); diff != "" {
+			t.Fatalf("unexpected preference (-want +got):\n%s", diff)
+		}
+	}
+
+	prf, err := ndp.ParsePreference("HIGH")
+	if err != nil {
+		t.Fatalf("failed to parse preference: %v", err)
+	}
+	if diff := cmp.Diff(ndp.High, prf); diff != "" {
+		t.Fatalf("unexpected preference (-want +got):\n%s", diff)
+	}
+
+	for _, s := range []string{"", "1", "reserved", "prfReserved", "highest"} {
+		if _, err := ndp.ParsePreference(s); err == nil {
+			t.Fatalf("expected an error parsing %q, but none occurred", s)
+		}
+	}
+
+	for _, prf := range []ndp.Preference{2, 4, -1} {
+		if _, err := prf.MarshalText(); err == nil {
+			t.Fatalf("expected an error marshaling %s, but none occurred", prf)
+		}
+	}
+}
+
+func TestMessageTypes(t *testing.T) {
+	mts := ndp.MessageTypes()
+	if len(mts) == 0 {
+		t.Fatal("no message types were returned")
+	}
+
+	for i, mt := range mts {
+		if i > 0 && mts[i-1].Type >= mt.Type {
+			t.Fatalf("message types are not sorted: %v before %v", mts[i-1].Type, mt.Type)
+		}
+		if mt.Name == "" {
+			t.Fatalf("message type %v has no name", mt.Type)
+		}
+
+		m := mt.New()
+		if m == nil || m.Type() != mt.Type {
+			t.Fatalf("message type %v created an unexpected message: %#v", mt.Type, m)
+		}
+
+		// Each call must return a distinct Message.
+		if mt.New() == m {
+			t.Fatalf("message type %v did not create a new message", mt.Type)
+		}
+	}
+}
+
+func TestICMPErrorInvokingMessage(t *testing.T) {
+	ns := &ndp.NeighborSolicitation{TargetAddress: ndptest.IP}
+	nsb, err := ndp.MarshalMessage(ns)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	dst := netip.MustParseAddr("ff02::1:ff00:1")
+
+	// IPv6 header with ICMPv6 next header, followed by the invoking message.
+	ip := make([]byte, 40)
+	ip[0] = 0x60
+	ip[6] = 58
+	ip[7] = ndp.HopLimit
+	copy(ip[24:], dst.AsSlice())
+
+	e := &ndp.ICMPError{
+		ErrorType: ipv6.ICMPTypeDestinationUnreachable,
+		Code:      3,
+		Invoking:  append(ip, nsb...),
+	}
+
+	m, to, err := e.InvokingMessage()
+	if err != nil {
+		t.Fatalf("failed to parse invoking message: %v", err)
+	}
+
+	if diff := cmp.Diff(ns, m, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected invoking message (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(dst, to, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected invoking destination (-want +got):\n%s", diff)
+	}
+
+	// A truncated invoking packet cannot be parsed.
+	e.Invoking = e.Invoking[:30]
+	if _, _, err := e.InvokingMessage(); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestDuplicateAddressDetection(t *testing.T) {
+	if _, err := ndp.NewDuplicateAddressDetectionNS(netip.MustParseAddr("192.0.2.1"), nil); err == nil {
+		t.Fatal("expected an error for IPv4 target, but none occurred")
+	}
+
+	nonce := ndp.NewNonce()
+	ns, err := ndp.NewDuplicateAddressDetectionNS(ndptest.IP, nonce)
+	if err != nil {
+		t.Fatalf("failed to create DAD probe: %v", err)
+	}
+
+	want := &ndp.NeighborSolicitation{
+		TargetAddress: ndptest.IP,
+		Options:       []ndp.Option{nonce},
+	}
+	if diff := cmp.Diff(want, ns, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected DAD probe (-want +got):\n%s", diff)
+	}
+
+	sllao := &ndp.NeighborSolicitation{
+		TargetAddress: ndptest.IP,
+		Options: []ndp.Option{&ndp.LinkLayerAddress{
+			Direction: ndp.Source,
+			Addr:      ndptest.MAC,
+		}},
+	}
+
+	tests := []struct {
+		name string
+		ns   *ndp.NeighborSolicitation
+		src  netip.Addr
+		ok   bool
+	}{
+		{
+			name: "DAD",
+			ns:   ns,
+			src:  netip.IPv6Unspecified(),
+			ok:   true,
+		},
+		{
+			name: "unicast source",
+			ns:   ns,
+			src:  netip.MustParseAddr("fe80::1"),
+		},
+		{
+			name: "source link-layer address",
+			ns:   sllao,
+			src:  netip.IPv6Unspecified(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.ok, ndp.IsDAD(tt.ns, tt.src)); diff != "" {
+				t.Fatalf("unexpected IsDAD result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParserRawMessages(t *testing.T) {
+	want := &ndp.RawMessage{
+		MessageType: ipv6.ICMPTypeEchoRequest,
+		Code:        1,
+		Body:        []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+
+	b, err := ndp.MarshalMessage(want)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if _, err := ndp.ParseMessage(b); err == nil {
+		t.Fatal("expected an error for unrecognized type, but none occurred")
+	}
+
+	p := &ndp.Parser{RawMessages: true}
+	got, err := p.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected raw message (-want +got):\n%s", diff)
+	}
+
+	// Recognized types are still parsed as their own Messages.
+	b, err = ndp.MarshalMessage(&ndp.RouterSolicitation{})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	m, err := p.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if _, ok := m.(*ndp.RouterSolicitation); !ok {
+		t.Fatalf("unexpected message type: %T", m)
+	}
+
+	// Truncated ICMPv6 headers are never valid.
+	if _, err := p.ParseMessage(b[:2]); err == nil {
+		t.Fatal("expected an error for short message, but none occurred")
+	}
+}
+
+func TestParserTolerantOptions(t *testing.T) {
+	// A prefix information option must be 32 bytes long.
+	bad := &ndp.RawOption{
+		Type:   3,
+		Length: 1,
+		Value:  make([]byte, 6),
+	}
+
+	want := &ndp.RouterSolicitation{
+		Options: []ndp.Option{
+			ndp.NewMTU(1500),
+			bad,
+			&ndp.LinkLayerAddress{
+				Direction: ndp.Source,
+				Addr:      ndptest.MAC,
+			},
+		},
+	}
+
+	b, err := ndp.MarshalMessage(want)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	if _, err := ndp.ParseMessage(b); err == nil {
+		t.Fatal("expected an error for malformed option, but none occurred")
+	}
+
+	var errs []*ndp.OptionError
+	p := &ndp.Parser{
+		TolerantOptions: true,
+		OptionError:     func(err *ndp.OptionError) { errs = append(errs, err) },
+	}
+
+	got, err := p.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected message (-want +got):\n%s", diff)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 option error, but got %d", len(errs))
+	}
+	if e := errs[0]; e.Index != 1 || e.Code != bad.Type || e.Err == nil {
+		t.Fatalf("unexpected option error: %v", e)
+	}
+
+	// An option which claims to be longer than the message cannot be
+	// skipped.
+	b = append(b, 1, 4)
+	if _, err := p.ParseMessage(b); err == nil {
+		t.Fatal("expected an error for truncated option, but none occurred")
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	ra := &ndp.RouterAdvertisement{
+		Options: []ndp.Option{
+			ndp.NewMTU(1500),
+			&ndp.RecursiveDNSServer{
+				Lifetime: 1 * time.Hour,
+				Servers: []netip.Addr{
+					netip.MustParseAddr("2001:db8::1"),
+					netip.MustParseAddr("2001:db8::2"),
+				},
+			},
+			&ndp.DNSSearchList{
+				Lifetime:    1 * time.Hour,
+				DomainNames: []string{"example.com", "foo.example.com"},
+			},
+		},
+	}
+
+	b, err := ndp.MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		limits ndp.ParseLimits
+		ok     bool
+	}{
+		{
+			name: "unlimited",
+			ok:   true,
+		},
+		{
+			name: "at limits",
+			limits: ndp.ParseLimits{
+				MaxSize:         len(b),
+				MaxOptions:      3,
+				MaxOptionSize:   40,
+				MaxRDNSSServers: 2,
+				MaxDNSSLDomains: 2,
+				MaxDNSSLLabels:  5,
+			},
+			ok: true,
+		},
+		{
+			name:   "bytes",
+			limits: ndp.ParseLimits{MaxSize: len(b) - 1},
+		},
+		{
+			name:   "options",
+			limits: ndp.ParseLimits{MaxOptions: 2},
+		},
+		{
+			name:   "option bytes",
+			limits: ndp.ParseLimits{MaxOptionSize: 39},
+		},
+		{
+			name:   "default",
+			limits: *ndp.DefaultParseLimits(),
+			ok:     true,
+		},
+		{
+			name:   "RDNSS servers",
+			limits: ndp.ParseLimits{MaxRDNSSServers: 1},
+		},
+		{
+			name:   "DNSSL domain names",
+			limits: ndp.ParseLimits{MaxDNSSLDomains: 1},
+		},
+		{
+			name:   "DNSSL labels",
+			limits: ndp.ParseLimits{MaxDNSSLLabels: 4},
+		},
+		{
+			name:   "cost units",
+			limits: ndp.ParseLimits{MaxCost: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Limits apply even when tolerating malformed options.
+			p := &ndp.Parser{
+				TolerantOptions: true,
+				Limits:          &tt.limits,
+			}
+
+			got, err := p.ParseMessage(b)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("failed to parse message: %v", err)
+				}
+
+				if diff := cmp.Diff(ra, got, cmp.Comparer(addrEqual)); diff != "" {
+					t.Fatalf("unexpected message (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			var lerr *ndp.LimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected a limit error, but got: %v", err)
+			}
+			if lerr.Limit != tt.name {
+				t.Fatalf("unexpected limit: %q", lerr.Limit)
+			}
+		})
+	}
+}
+
+func TestParserLimitsPvD(t *testing.T) {
+	// The options encapsulated in a PvD option count towards the limits of
+	// the Message, along with the options which surround the PvD.
+	ra := &ndp.RouterAdvertisement{
+		Options: []ndp.Option{
+			ndp.NewMTU(1500),
+			&ndp.PvD{
+				FQDN: "pvd.example.com",
+				Options: []ndp.Option{
+					&ndp.RecursiveDNSServer{
+						Lifetime: 1 * time.Hour,
+						Servers: []netip.Addr{
+							netip.MustParseAddr("2001:db8::1"),
+							netip.MustParseAddr("2001:db8::2"),
+						},
+					},
+					&ndp.DNSSearchList{
+						Lifetime:    1 * time.Hour,
+						DomainNames: []string{"example.com", "foo.example.com"},
+					},
+				},
+			},
+		},
+	}
+
+	b, err := ndp.MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		limits ndp.ParseLimits
+		ok     bool
+	}{
+		{
+			name: "at limits",
+			limits: ndp.ParseLimits{
+				MaxOptions:      4,
+				MaxRDNSSServers: 2,
+				MaxDNSSLDomains: 2,
+				MaxDNSSLLabels:  5,
+			},
+			ok: true,
+		},
+		{
+			name:   "options",
+			limits: ndp.ParseLimits{MaxOptions: 3},
+		},
+		{
+			name:   "RDNSS servers",
+			limits: ndp.ParseLimits{MaxRDNSSServers: 1},
+		},
+		{
+			name:   "DNSSL domain names",
+			limits: ndp.ParseLimits{MaxDNSSLDomains: 1},
+		},
+		{
+			name:   "DNSSL labels",
+			limits: ndp.ParseLimits{MaxDNSSLLabels: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ndp.Parser{
+				TolerantOptions: true,
+				Limits:          &tt.limits,
+			}
+
+			got, err := p.ParseMessage(b)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("failed to parse message: %v", err)
+				}
+
+				if diff := cmp.Diff(ra, got, cmp.Comparer(addrEqual)); diff != "" {
+					t.Fatalf("unexpected message (-want +got):\n%s", diff)
+				}
+				return
+			}
+
+			var lerr *ndp.LimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected a limit error, but got: %v", err)
+			}
+			if lerr.Limit != tt.name {
+				t.Fatalf("unexpected limit: %q", lerr.Limit)
+			}
+		})
+	}
+}
+
+func TestDefaultParseLimits(t *testing.T) {
+	// A hostile RA with thousands of tiny options.
+	ra := &ndp.RouterAdvertisement{}
+	for i := 0; i < 4096; i++ {
+		ra.Options = append(ra.Options, ndp.NewMTU(1500))
+	}
+
+	b, err := ndp.MarshalMessage(ra)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	_, err = (&ndp.Parser{Limits: ndp.DefaultParseLimits()}).ParseMessage(b)
+
+	var lerr *ndp.LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != "options" {
+		t.Fatalf("expected an options limit error, but got: %v", err)
+	}
+}
+
+func TestParserLimitsMaxCostWorstCase(t *testing.T) {
+	// The budget documented for ParseLimits.MaxCost must admit worst case
+	// messages without domain names, and reject those with them.
+	p := &ndp.Parser{Limits: &ndp.ParseLimits{MaxCost: 5000}}
+
+	for name, ra := range worstCaseRouterAdvertisements() {
+		t.Run(name, func(t *testing.T) {
+			b, err := ndp.MarshalMessage(ra)
+			if err != nil {
+				t.Fatalf("failed to marshal message: %v", err)
+			}
+
+			var lerr *ndp.LimitError
+			_, err = p.ParseMessage(b)
+			if got, want := errors.As(err, &lerr), name == "DNS search list"; got != want {
+				t.Fatalf("unexpected limit error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewUnsolicitedNA(t *testing.T) {
+	if _, err := ndp.NewUnsolicitedNA(netip.MustParseAddr("192.0.2.1"), ndptest.MAC, false); err == nil {
+		t.Fatal("expected an error for IPv4 target, but none occurred")
+	}
+	if _, err := ndp.NewUnsolicitedNA(ndptest.IP, net.HardwareAddr{0xde, 0xad}, false); err == nil {
+		t.Fatal("expected an error for short link-layer address, but none occurred")
+	}
+
+	na, err := ndp.NewUnsolicitedNA(ndptest.IP, ndptest.MAC, true)
+	if err != nil {
+		t.Fatalf("failed to create unsolicited NA: %v", err)
+	}
+
+	want := &ndp.NeighborAdvertisement{
+		Router:        true,
+		Override:      true,
+		TargetAddress: ndptest.IP,
+		Options: []ndp.Option{&ndp.LinkLayerAddress{
+			Direction: ndp.Target,
+			Addr:      ndptest.MAC,
+		}},
+	}
+	if diff := cmp.Diff(want, na, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected unsolicited NA (-want +got):\n%s", diff)
+	}
+
+	// Non-Ethernet link-layer addresses, such as EUI-64, are also supported.
+	eui64 := net.HardwareAddr{0x02, 0x00, 0x00, 0xff, 0xfe, 0x00, 0x00, 0x01}
+	na, err = ndp.NewUnsolicitedNA(ndptest.IP, eui64, false)
+	if err != nil {
+		t.Fatalf("failed to create EUI-64 unsolicited NA: %v", err)
+	}
+
+	lla, ok := ndp.FirstOption[*ndp.LinkLayerAddress](na)
+	if !ok {
+		t.Fatal("missing target link-layer address option")
+	}
+	if diff := cmp.Diff(eui64, lla.Addr); diff != "" {
+		t.Fatalf("unexpected EUI-64 address (-want +got):\n%s", diff)
+	}
+	if _, err := ndp.MarshalMessage(na); err != nil {
+		t.Fatalf("failed to marshal EUI-64 unsolicited NA: %v", err)
+	}
+}
+
+func TestParserStrictPreference(t *testing.T) {
+	var (
+		// RA with reserved router selection preference.
+		raPrf = []byte{
+			134, 0x00, 0x00, 0x00,
+			0x40, 0x10, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		}
+
+		// RA with a route information option with reserved preference.
+		rioPrf = ndptest.Merge([][]byte{
+			{134, 0x00, 0x00, 0x00},
+			ndptest.Zero(12),
+			{24, 0x01, 0x00, 0x10},
+			ndptest.Zero(4),
+		})
+	)
+
+	tests := []struct {
+		name   string
+		b      []byte
+		strict bool
+		want   ndp.Message
+	}{
+		{
+			name: "RA reserved",
+			b:    raPrf,
+			want: &ndp.RouterAdvertisement{
+				CurrentHopLimit:           64,
+				RouterSelectionPreference: ndp.Medium,
+			},
+		},
+		{
+			name:   "RA reserved strict",
+			b:      raPrf,
+			strict: true,
+		},
+		{
+			name: "RIO reserved",
+			b:    rioPrf,
+			want: &ndp.RouterAdvertisement{},
+		},
+		{
+			name:   "RIO reserved strict",
+			b:      rioPrf,
+			strict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ndp.Parser{StrictPreference: tt.strict}
+
+			m, err := p.ParseMessage(tt.b)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, m); diff != "" {
+				t.Fatalf("unexpected message (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func BenchmarkParseMessage(b *testing.B) {
+	buf, err := ndp.MarshalMessage(testRouterAdvertisement())
+	if err != nil {
+		b.Fatalf("failed to marshal message: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ndp.ParseMessage(buf); err != nil {
+			b.Fatalf("failed to parse message: %v", err)
+		}
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for _, st := range tt.subs {
-				t.Run(st.name, func(t *testing.T) {
-					ttb := append(tt.header, ndptest.Merge(st.bs)...)
-					_, err := ndp.ParseMessage(ttb)
-					if err == nil {
-						t.Fatal("expected an error, but none occurred")
-					}
+func BenchmarkUnmarshalMessage(b *testing.B) {
+	buf, err := ndp.MarshalMessage(testRouterAdvertisement())
+	if err != nil {
+		b.Fatalf("failed to marshal message: %v", err)
+	}
 
-					// Rather then exporting errParseMessage, we will just check
-					// for a wrapped substring here for now.
-					perr := errors.Unwrap(err)
-					if perr.Error() != "failed to parse message" {
-						t.Fatalf("unexpected error: %v", err)
-					}
-				})
+	ra := new(ndp.RouterAdvertisement)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := ndp.UnmarshalMessage(buf, ra); err != nil {
+			b.Fatalf("failed to unmarshal message: %v", err)
+		}
+	}
+}
+
+func BenchmarkMarshalMessage(b *testing.B) {
+	ra := testRouterAdvertisement()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ndp.MarshalMessage(ra); err != nil {
+			b.Fatalf("failed to marshal message: %v", err)
+		}
+	}
+}
+
+func BenchmarkAppendMessage(b *testing.B) {
+	ra := testRouterAdvertisement()
+	buf := make([]byte, 0, 1500)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ndp.AppendMessage(buf[:0], ra); err != nil {
+			b.Fatalf("failed to append message: %v", err)
+		}
+	}
+}
+
+// testRouterAdvertisement returns a RouterAdvertisement with a typical set of
+// options for marshaling tests and benchmarks.
+func BenchmarkParseMessageWorstCase(b *testing.B) {
+	for name, ra := range worstCaseRouterAdvertisements() {
+		buf, err := ndp.MarshalMessage(ra)
+		if err != nil {
+			b.Fatalf("failed to marshal %s message: %v", name, err)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(buf)))
+
+			m := new(ndp.RouterAdvertisement)
+			for i := 0; i < b.N; i++ {
+				if err := ndp.UnmarshalMessage(buf, m); err != nil {
+					b.Fatalf("failed to unmarshal message: %v", err)
+				}
 			}
 		})
 	}
 }
 
-func TestMarshalMessageChecksum(t *testing.T) {
-	var (
-		source      = netip.MustParseAddr("2001:db8::10")
-		destination = netip.MustParseAddr("2001:db8::1")
-	)
+// worstCaseRouterAdvertisements returns RouterAdvertisements which fill a 1500
+// byte Ethernet frame with options that are expensive to parse.
+func worstCaseRouterAdvertisements() map[string]*ndp.RouterAdvertisement {
+	// 1500 bytes, less the IPv6, ICMPv6, and RA headers.
+	const optionsLen = 1500 - 40 - 4 - 12
 
-	message := &ndp.NeighborAdvertisement{
-		Solicited:     true,
-		Override:      true,
-		TargetAddress: source,
-		Options: []ndp.Option{&ndp.LinkLayerAddress{
-			Direction: ndp.Target,
-			Addr:      ndptest.MAC,
-		}},
+	var mtus []ndp.Option
+	for i := 0; i < optionsLen/ndp.MTULen; i++ {
+		mtus = append(mtus, ndp.NewMTU(1500))
 	}
 
-	buf, err := ndp.MarshalMessageChecksum(message, source, destination)
-	if err != nil {
-		t.Fatalf("failed to marshal message with checksum: %v", err)
+	var pis []ndp.Option
+	for i := 0; i < optionsLen/ndp.PrefixInformationLen; i++ {
+		pi := &ndp.PrefixInformation{
+			OnLink:            true,
+			ValidLifetime:     ndp.Infinity,
+			PreferredLifetime: ndp.Infinity,
+		}
+		pi.SetIPPrefix(netip.PrefixFrom(netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(i)}), 64))
+		pis = append(pis, pi)
 	}
 
-	// Checksum is in bytes 3 and 4.
-	if diff := cmp.Diff(buf[2:4], []uint8{0x10, 0x0c}); diff != "" {
-		t.Fatalf("unexpected set checksum (-want +got):\n%s", diff)
+	// Domain names of many single character labels, each of which must be
+	// validated and decoded.
+	var (
+		dnssl = &ndp.DNSSearchList{Lifetime: ndp.Infinity}
+		name  = strings.TrimSuffix(strings.Repeat("a.", 60), ".")
+	)
+	for i := 0; i < optionsLen/(len(name)+2); i++ {
+		dnssl.DomainNames = append(dnssl.DomainNames, name)
 	}
 
-	// Check that MarshalMessage has a 0 checksum.
-	buf, err = ndp.MarshalMessage(message)
-	if err != nil {
-		t.Fatalf("failed to marshal message: %v", err)
+	return map[string]*ndp.RouterAdvertisement{
+		"MTU":                {Options: mtus},
+		"prefix information": {Options: pis},
+		"DNS search list":    {Options: []ndp.Option{dnssl}},
 	}
+}
 
-	if diff := cmp.Diff(buf[2:4], []uint8{0, 0}); diff != "" {
-		t.Fatalf("unexpected unset checksum (-want +got):\n%s", diff)
+func testRouterAdvertisement() *ndp.RouterAdvertisement {
+	return &ndp.RouterAdvertisement{
+		CurrentHopLimit:      64,
+		ManagedConfiguration: true,
+		RouterLifetime:       30 * time.Minute,
+		Options: []ndp.Option{
+			&ndp.LinkLayerAddress{
+				Direction: ndp.Source,
+				Addr:      ndptest.MAC,
+			},
+			ndp.NewMTU(1500),
+			&ndp.PrefixInformation{
+				PrefixLength:                   64,
+				OnLink:                         true,
+				AutonomousAddressConfiguration: true,
+				ValidLifetime:                  24 * time.Hour,
+				PreferredLifetime:              4 * time.Hour,
+				Prefix:                         ndptest.Prefix,
+			},
+			&ndp.RecursiveDNSServer{
+				Lifetime: 1 * time.Hour,
+				Servers:  []netip.Addr{ndptest.IP},
+			},
+		},
 	}
 }
 
@@ -483,4 +1835,418 @@ func rsTests() []messageSub {
 	}
 }
 
+func insTests() []messageSub {
+	return []messageSub{
+		{
+			name: "ok",
+			m: &ndp.InverseNeighborSolicitation{
+				Options: []ndp.Option{
+					&ndp.LinkLayerAddress{
+						Direction: ndp.Source,
+						Addr:      ndptest.MAC,
+					},
+					&ndp.LinkLayerAddress{
+						Direction: ndp.Target,
+						Addr:      ndptest.MAC,
+					},
+				},
+			},
+			bs: [][]byte{
+				// IND solicitation message.
+				{0x00, 0x00, 0x00, 0x00},
+				// Source LLA option.
+				{0x01, 0x01},
+				ndptest.MAC,
+				// Target LLA option.
+				{0x02, 0x01},
+				ndptest.MAC,
+			},
+			ok: true,
+		},
+	}
+}
+
+func inaTests() []messageSub {
+	return []messageSub{
+		{
+			name: "ok",
+			m: &ndp.InverseNeighborAdvertisement{
+				Options: []ndp.Option{
+					&ndp.LinkLayerAddress{
+						Direction: ndp.Target,
+						Addr:      ndptest.MAC,
+					},
+					&ndp.AddressList{
+						Direction: ndp.Target,
+						Addresses: []netip.Addr{ndptest.IP},
+					},
+				},
+			},
+			bs: [][]byte{
+				// IND advertisement message.
+				{0x00, 0x00, 0x00, 0x00},
+				// Target LLA option.
+				{0x02, 0x01},
+				ndptest.MAC,
+				// Target Address List option.
+				{0x0a, 0x03},
+				ndptest.Zero(6),
+				ndptest.IP.AsSlice(),
+			},
+			ok: true,
+		},
+	}
+}
+
+func niqTests() []messageSub {
+	return []messageSub{
+		{
+			name: "ok, NOOP",
+			m: &ndp.NodeInformationQuery{
+				Code:  ndp.NISubjectName,
+				QType: ndp.NIQTypeNOOP,
+				Nonce: [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+			},
+			bs: [][]byte{
+				{0x00, 0x00, 0x00, 0x00},
+				{1, 2, 3, 4, 5, 6, 7, 8},
+			},
+			ok: true,
+		},
+		{
+			name: "ok, node addresses",
+			m: &ndp.NodeInformationQuery{
+				Code:  ndp.NISubjectName,
+				QType: ndp.NIQTypeNodeAddresses,
+				Flags: 0x003e,
+				Nonce: [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+				Data:  []byte{4, 'h', 'o', 's', 't', 0x00, 0x00},
+			},
+			bs: [][]byte{
+				{0x00, 0x03, 0x00, 0x3e},
+				{1, 2, 3, 4, 5, 6, 7, 8},
+				{4, 'h', 'o', 's', 't', 0x00, 0x00},
+			},
+			ok: true,
+		},
+	}
+}
+
+func nirTests() []messageSub {
+	return []messageSub{
+		{
+			name: "ok, refused",
+			m: &ndp.NodeInformationReply{
+				Code:  ndp.NIReplyRefused,
+				QType: ndp.NIQTypeNodeName,
+				Nonce: [8]byte{8, 7, 6, 5, 4, 3, 2, 1},
+			},
+			bs: [][]byte{
+				{0x00, 0x02, 0x00, 0x00},
+				{8, 7, 6, 5, 4, 3, 2, 1},
+			},
+			ok: true,
+		},
+	}
+}
+
+func icmpErrorTests() []messageSub {
+	return []messageSub{
+		{
+			name: "bad, zero type",
+			m:    &ndp.ICMPError{},
+		},
+		{
+			name: "bad, informational type",
+			m:    &ndp.ICMPError{ErrorType: ipv6.ICMPTypeEchoRequest},
+		},
+		{
+			name: "ok, parameter problem",
+			m: &ndp.ICMPError{
+				ErrorType: ipv6.ICMPTypeParameterProblem,
+				Code:      1,
+				Param:     40,
+				Invoking:  []byte{0x60, 0x00, 0x00, 0x00},
+			},
+			bs: [][]byte{
+				{0x00, 0x00, 0x00, 0x28},
+				{0x60, 0x00, 0x00, 0x00},
+			},
+			ok: true,
+		},
+	}
+}
+
+func rrCommandTests() []messageSub {
+	var (
+		match = netip.MustParsePrefix("2001:db8::/32")
+		use   = netip.MustParsePrefix("2001:db8:1::/48")
+	)
+
+	return []messageSub{
+		{
+			name: "bad, results in command",
+			m: &ndp.RouterRenumbering{
+				Code:    ndp.RRCommand,
+				Results: []ndp.MatchResult{{MatchedPrefix: match}},
+			},
+		},
+		{
+			name: "bad, max delay",
+			m: &ndp.RouterRenumbering{
+				Code:     ndp.RRCommand,
+				MaxDelay: 1 * time.Hour,
+			},
+		},
+		{
+			name: "bad, IPv4 match prefix",
+			m: &ndp.RouterRenumbering{
+				Code: ndp.RRCommand,
+				Operations: []ndp.PrefixControlOperation{{
+					MatchPrefix: netip.MustParsePrefix("192.0.2.0/24"),
+				}},
+			},
+		},
+		{
+			name: "ok, no operations",
+			m: &ndp.RouterRenumbering{
+				Code:            ndp.RRCommand,
+				SequenceNumber:  1,
+				Test:            true,
+				ResultRequested: true,
+				MaxDelay:        500 * time.Millisecond,
+			},
+			bs: [][]byte{
+				{0x00, 0x00, 0x00, 0x01},
+				{0x00, 0xc0, 0x01, 0xf4},
+				ndptest.Zero(4),
+			},
+			ok: true,
+		},
+		{
+			name: "ok, operation",
+			m: &ndp.RouterRenumbering{
+				Code:           ndp.RRCommand,
+				SequenceNumber: 2,
+				SegmentNumber:  1,
+				AllInterfaces:  true,
+				Operations: []ndp.PrefixControlOperation{{
+					OpCode:      ndp.RRAdd,
+					Ordinal:     10,
+					MatchPrefix: match,
+					MinLength:   32,
+					MaxLength:   64,
+					UsePrefixes: []ndp.UsePrefix{{
+						Prefix:            use,
+						KeepLength:        16,
+						FlagMask:          0xc0,
+						RAFlags:           0x80,
+						ValidLifetime:     ndp.Infinity,
+						PreferredLifetime: 1 * time.Hour,
+						DecrementValid:    true,
+					}},
+				}},
+			},
+			bs: [][]byte{
+				// Header.
+				{0x00, 0x00, 0x00, 0x02},
+				{0x01, 0x20, 0x00, 0x00},
+				ndptest.Zero(4),
+				// Match-Prefix Part.
+				{0x01, 0x07, 0x0a, 0x20},
+				{0x20, 0x40, 0x00, 0x00},
+				match.Addr().AsSlice(),
+				// Use-Prefix Part.
+				{0x30, 0x10, 0xc0, 0x80},
+				{0xff, 0xff, 0xff, 0xff},
+				{0x00, 0x00, 0x0e, 0x10},
+				{0x80, 0x00, 0x00, 0x00},
+				use.Addr().AsSlice(),
+			},
+			ok: true,
+		},
+	}
+}
+
+func rrResultTests() []messageSub {
+	matched := netip.MustParsePrefix("2001:db8::/64")
+
+	return []messageSub{
+		{
+			name: "ok",
+			m: &ndp.RouterRenumbering{
+				Code:           ndp.RRResult,
+				SequenceNumber: 3,
+				Results: []ndp.MatchResult{{
+					Forbidden:      true,
+					Ordinal:        10,
+					MatchedPrefix:  matched,
+					InterfaceIndex: 2,
+				}},
+			},
+			bs: [][]byte{
+				// Header.
+				{0x00, 0x00, 0x00, 0x03},
+				{0x00, 0x00, 0x00, 0x00},
+				ndptest.Zero(4),
+				// Match-Result.
+				{0x00, 0x01, 0x0a, 0x40},
+				{0x00, 0x00, 0x00, 0x02},
+				matched.Addr().AsSlice(),
+			},
+			ok: true,
+		},
+	}
+}
+
 func addrEqual(x, y netip.Addr) bool { return x == y }
+
+func TestCheckRouterAdvertisements(t *testing.T) {
+	prefix := netip.MustParsePrefix("2001:db8::/64")
+	pio := func(valid, preferred time.Duration) *ndp.PrefixInformation {
+		return &ndp.PrefixInformation{
+			PrefixLength:      uint8(prefix.Bits()),
+			ValidLifetime:     valid,
+			PreferredLifetime: preferred,
+			Prefix:            prefix.Addr(),
+		}
+	}
+
+	tests := []struct {
+		name string
+		x, y *ndp.RouterAdvertisement
+		cs   []ndp.RAConflict
+	}{
+		{
+			name: "consistent",
+			x:    testRouterAdvertisement(),
+			y:    testRouterAdvertisement(),
+		},
+		{
+			name: "unspecified",
+			x: &ndp.RouterAdvertisement{
+				CurrentHopLimit: 64,
+				ReachableTime:   30 * time.Second,
+				Options:         []ndp.Option{ndp.NewMTU(1500)},
+			},
+			y: &ndp.RouterAdvertisement{
+				RetransmitTimer: 1 * time.Second,
+			},
+		},
+		{
+			name: "conflicts",
+			x: &ndp.RouterAdvertisement{
+				CurrentHopLimit:      64,
+				ManagedConfiguration: true,
+				ReachableTime:        30 * time.Second,
+				Options: []ndp.Option{
+					ndp.NewMTU(1500),
+					pio(24*time.Hour, 4*time.Hour),
+				},
+			},
+			y: &ndp.RouterAdvertisement{
+				CurrentHopLimit: 255,
+				ReachableTime:   30 * time.Second,
+				Options: []ndp.Option{
+					pio(24*time.Hour, 1*time.Hour),
+					ndp.NewMTU(9000),
+				},
+			},
+			cs: []ndp.RAConflict{
+				{Field: "CurrentHopLimit", X: uint8(64), Y: uint8(255)},
+				{Field: "ManagedConfiguration", X: true, Y: false},
+				{Field: "MTU", X: uint32(1500), Y: uint32(9000)},
+				{
+					Field:  "PreferredLifetime",
+					Prefix: prefix,
+					X:      4 * time.Hour,
+					Y:      1 * time.Hour,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := ndp.CheckRouterAdvertisements(tt.x, tt.y)
+			if diff := cmp.Diff(tt.cs, cs, cmp.Comparer(prefixEqual)); diff != "" {
+				t.Fatalf("unexpected conflicts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithdrawRouterAdvertisement(t *testing.T) {
+	var (
+		mac  = net.HardwareAddr{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad}
+		pfx  = netip.MustParseAddr("2001:db8::")
+		pfx1 = netip.MustParseAddr("2001:db8:1::")
+		dns  = netip.MustParseAddr("2001:db8::53")
+	)
+
+	ra := &ndp.RouterAdvertisement{
+		CurrentHopLimit: 64,
+		RouterLifetime:  30 * time.Minute,
+		Options: []ndp.Option{
+			&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: mac},
+			ndp.NewMTU(1500),
+			&ndp.PrefixInformation{
+				PrefixLength:                   64,
+				OnLink:                         true,
+				AutonomousAddressConfiguration: true,
+				ValidLifetime:                  ndp.Infinity,
+				PreferredLifetime:              ndp.Infinity,
+				Prefix:                         pfx,
+			},
+			&ndp.RouteInformation{
+				PrefixLength:  48,
+				Preference:    ndp.High,
+				RouteLifetime: time.Hour,
+				Prefix:        pfx1,
+			},
+			&ndp.RecursiveDNSServer{Lifetime: time.Hour, Servers: []netip.Addr{dns}},
+			&ndp.DNSSearchList{Lifetime: time.Hour, DomainNames: []string{"example.com"}},
+		},
+	}
+	orig := ra.Clone()
+
+	want := &ndp.RouterAdvertisement{
+		CurrentHopLimit: 64,
+		Options: []ndp.Option{
+			&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: mac},
+			ndp.NewMTU(1500),
+			&ndp.PrefixInformation{
+				PrefixLength:                   64,
+				OnLink:                         true,
+				AutonomousAddressConfiguration: true,
+				Prefix:                         pfx,
+			},
+			&ndp.RouteInformation{
+				PrefixLength: 48,
+				Preference:   ndp.High,
+				Prefix:       pfx1,
+			},
+			&ndp.RecursiveDNSServer{Servers: []netip.Addr{dns}},
+			&ndp.DNSSearchList{DomainNames: []string{"example.com"}},
+		},
+	}
+
+	if diff := cmp.Diff(want, ndp.WithdrawRouterAdvertisement(ra), cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected withdrawn RA (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(orig, ra, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("input RA was modified (-want +got):\n%s", diff)
+	}
+
+	if _, ok := ndp.Withdraw(ndp.NewMTU(1500)); ok {
+		t.Fatal("unexpectedly withdrew an MTU option")
+	}
+}
+
+func prefixEqual(x, y netip.Prefix) bool { return x == y }
+
+func TestCheckWireLengths(t *testing.T) {
+	if err := ndp.CheckWireLengths(); err != nil {
+		t.Fatalf("failed to check wire lengths: %v", err)
+	}
+}
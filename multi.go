@@ -0,0 +1,301 @@
+package ndp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/ipv6"
+)
+
+// An InterfaceMessage is a Message received by a MultiConn, annotated with
+// the network interface on which it was received.
+type InterfaceMessage struct {
+	// Interface is the network interface of the Conn which received the
+	// Message.
+	Interface *net.Interface
+
+	// Message, ControlMessage, and From are the values returned by
+	// Conn.ReadFrom.
+	Message        Message
+	ControlMessage *ipv6.ControlMessage
+	From           netip.Addr
+
+	// Err is non-nil if reading from Interface failed, in which case no more
+	// messages are received from Interface and the other fields are unset.
+	Err error
+}
+
+// A MultiConn is a set of Conns, one per network interface, whose received
+// messages are multiplexed onto a single channel.
+type MultiConn struct {
+	// conns is sorted by interface name.
+	conns []*Conn
+	msgs  chan InterfaceMessage
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// ListenInterfaces creates a MultiConn with a Conn for each network interface
+// whose name matches pattern, using the specified address type as described
+// by Listen. pattern uses the syntax of path.Match; an empty pattern or "*"
+// matches all interfaces.
+//
+// Interfaces which are down, are loopback interfaces, or do not support
+// multicast are ignored, as are interfaces which have no address of type addr.
+// An error is returned if no Conns could be created.
+func ListenInterfaces(pattern string, addr Addr) (*MultiConn, error) {
+	return (&ListenConfig{}).ListenInterfaces(pattern, addr)
+}
+
+// ListenInterfaces creates a MultiConn in the same way as the package-level
+// ListenInterfaces, creating each Conn with the options of lc.
+func (lc *ListenConfig) ListenInterfaces(pattern string, addr Addr) (*MultiConn, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("ndp: invalid interface pattern %q: %v", pattern, err)
+	}
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conns []*Conn
+		// addrErr is the most recent error from choosing an address, reported
+		// if no interface has a usable address.
+		addrErr error
+	)
+
+	closeAll := func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}
+
+	for i := range ifis {
+		ifi := &ifis[i]
+		if ok, _ := path.Match(pattern, ifi.Name); !ok {
+			continue
+		}
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 ||
+			ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		ip, err := lc.chooseAddr(ifi, addr)
+		if err != nil {
+			addrErr = err
+			continue
+		}
+
+		c, err := lc.listen(ifi, ip)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("ndp: failed to listen on interface %q: %w", ifi.Name, err)
+		}
+
+		conns = append(conns, c)
+	}
+
+	if len(conns) == 0 {
+		if addrErr != nil {
+			return nil, fmt.Errorf("ndp: no interfaces matching %q have a usable address: %w", pattern, addrErr)
+		}
+
+		return nil, fmt.Errorf("ndp: no interfaces match %q", pattern)
+	}
+
+	mc, err := NewMultiConn(conns...)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	return mc, nil
+}
+
+// NewMultiConn creates a MultiConn from existing Conns, such as those created
+// by NewConn. Each Conn must use a different network interface. The MultiConn
+// takes ownership of the Conns, which are closed by MultiConn.Close.
+func NewMultiConn(conns ...*Conn) (*MultiConn, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("ndp: MultiConn requires at least one Conn")
+	}
+
+	for _, c := range conns {
+		if c == nil {
+			return nil, errors.New("ndp: nil Conn")
+		}
+	}
+
+	cs := make([]*Conn, len(conns))
+	copy(cs, conns)
+	sort.Slice(cs, func(i, j int) bool { return cs[i].ifi.Name < cs[j].ifi.Name })
+
+	for i := 1; i < len(cs); i++ {
+		if cs[i].ifi.Name == cs[i-1].ifi.Name {
+			return nil, fmt.Errorf("ndp: duplicate Conn for interface %q", cs[i].ifi.Name)
+		}
+	}
+
+	mc := &MultiConn{
+		conns: cs,
+		msgs:  make(chan InterfaceMessage, len(cs)),
+		done:  make(chan struct{}),
+	}
+
+	mc.wg.Add(len(cs))
+	for _, c := range cs {
+		go mc.read(c)
+	}
+
+	go func() {
+		// Signal consumers once all of the readers have stopped.
+		mc.wg.Wait()
+		close(mc.msgs)
+	}()
+
+	return mc, nil
+}
+
+// read reads messages from c until an error occurs or mc is closed.
+func (mc *MultiConn) read(c *Conn) {
+	defer mc.wg.Done()
+
+	for {
+		m, cm, from, err := c.ReadFrom()
+		im := InterfaceMessage{Interface: c.ifi, Err: err}
+		if err == nil {
+			im.Message, im.ControlMessage, im.From = m, cm, from
+		}
+
+		select {
+		case <-mc.done:
+			// Errors caused by Close are not reported.
+			return
+		default:
+		}
+
+		select {
+		case mc.msgs <- im:
+		case <-mc.done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Messages returns a channel of messages received by all of the Conns in the
+// MultiConn. Messages are filtered and parsed as configured for each Conn. The
+// channel is closed when the MultiConn is closed, or when reading from every
+// Conn has failed.
+func (mc *MultiConn) Messages() <-chan InterfaceMessage { return mc.msgs }
+
+// Close stops receiving messages and closes all of the Conns in the MultiConn.
+func (mc *MultiConn) Close() error {
+	var err error
+	mc.closeOnce.Do(func() {
+		close(mc.done)
+		for _, c := range mc.conns {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+
+		mc.wg.Wait()
+	})
+
+	return err
+}
+
+// Interfaces returns the network interfaces of the Conns in the MultiConn,
+// sorted by name.
+func (mc *MultiConn) Interfaces() []*net.Interface {
+	ifis := make([]*net.Interface, 0, len(mc.conns))
+	for _, c := range mc.conns {
+		ifis = append(ifis, c.ifi)
+	}
+
+	return ifis
+}
+
+// Conn returns the Conn for the network interface with the specified name,
+// which can be used to configure the Conn or to send messages on that
+// interface. Messages must not be read from the Conn directly.
+func (mc *MultiConn) Conn(name string) (*Conn, bool) {
+	for _, c := range mc.conns {
+		if c.ifi.Name == name {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// JoinGroup joins the specified multicast group on every Conn in the
+// MultiConn, as described by Conn.JoinGroup. If joining fails on any
+// interface, the group is left on the interfaces which were already joined.
+func (mc *MultiConn) JoinGroup(group netip.Addr) error {
+	for i, c := range mc.conns {
+		if err := c.JoinGroup(group); err != nil {
+			for _, jc := range mc.conns[:i] {
+				_ = jc.LeaveGroup(group)
+			}
+
+			return fmt.Errorf("ndp: failed to join %s on interface %q: %w", group, c.ifi.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// LeaveGroup leaves the specified multicast group on every Conn in the
+// MultiConn, as described by Conn.LeaveGroup. The group is left on as many
+// interfaces as possible, and the first error is returned.
+func (mc *MultiConn) LeaveGroup(group netip.Addr) error {
+	var err error
+	for _, c := range mc.conns {
+		if lerr := c.LeaveGroup(group); lerr != nil && err == nil {
+			err = fmt.Errorf("ndp: failed to leave %s on interface %q: %w", group, c.ifi.Name, lerr)
+		}
+	}
+
+	return err
+}
+
+// JoinSolicitedNodeMulticast joins the solicited-node multicast group of the
+// address of each Conn in the MultiConn, so that neighbor solicitations for
+// each interface's address are received on that interface. Conns bound to
+// the unspecified address are skipped.
+func (mc *MultiConn) JoinSolicitedNodeMulticast() error {
+	for i, c := range mc.conns {
+		if c.addr.IsUnspecified() {
+			continue
+		}
+
+		if err := c.JoinSolicitedNodeMulticast(c.addr); err != nil {
+			for _, jc := range mc.conns[:i] {
+				if !jc.addr.IsUnspecified() {
+					_ = jc.LeaveSolicitedNodeMulticast(jc.addr)
+				}
+			}
+
+			return fmt.Errorf("ndp: failed to join solicited-node multicast group on interface %q: %w", c.ifi.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// Default values for ProbeConfig, as described in RFC 4861, Section 10.
+const (
+	defaultProbeCount    = 3
+	defaultProbeInterval = 1 * time.Second
+)
+
+// A ProbeConfig configures ProbeNeighbor. The zero value is valid and uses
+// default values.
+type ProbeConfig struct {
+	// Count specifies the number of neighbor solicitations to send. If zero,
+	// 3 solicitations are sent.
+	Count int
+
+	// Interval specifies how long to wait for a neighbor advertisement after
+	// each solicitation. If zero, 1 second is used.
+	Interval time.Duration
+}
+
+// A ProbeResult is the result of a ProbeNeighbor operation.
+type ProbeResult struct {
+	// Sent contains the time at which each neighbor solicitation was sent.
+	Sent []time.Time
+
+	// Advertisement and From contain the neighbor advertisement received in
+	// response to a solicitation and the address which sent it, and Received
+	// is the time at which it arrived. If the neighbor did not answer,
+	// Advertisement is nil.
+	Advertisement *NeighborAdvertisement
+	From          netip.Addr
+	Received      time.Time
+}
+
+// Answered reports whether the neighbor answered any of the solicitations.
+func (r *ProbeResult) Answered() bool { return r.Advertisement != nil }
+
+// Elapsed returns the amount of time between the first solicitation and the
+// neighbor's answer, or zero if the neighbor did not answer.
+func (r *ProbeResult) Elapsed() time.Duration {
+	if !r.Answered() || len(r.Sent) == 0 {
+		return 0
+	}
+
+	return r.Received.Sub(r.Sent[0])
+}
+
+// ProbeNeighbor sends a burst of neighbor solicitations for target to its
+// solicited-node multicast group, and waits for a neighbor advertisement for
+// target after each. This is useful to check whether a sleeping host wakes up
+// and answers in a timely manner.
+//
+// If cfg is nil, default values are used. If the neighbor does not answer any
+// of the solicitations, ProbeNeighbor returns a ProbeResult which reports
+// false for Answered, and a nil error.
+func ProbeNeighbor(ctx context.Context, c *Conn, target netip.Addr, cfg *ProbeConfig) (*ProbeResult, error) {
+	if cfg == nil {
+		cfg = &ProbeConfig{}
+	}
+
+	count := cfg.Count
+	if count == 0 {
+		count = defaultProbeCount
+	}
+	if count < 0 {
+		return nil, errors.New("ndp: probe count must not be negative")
+	}
+
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultProbeInterval
+	}
+
+	snm, err := SolicitedNodeMulticast(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := &NeighborSolicitation{TargetAddress: target}
+	if !c.addr.IsUnspecified() && c.ifi.HardwareAddr != nil {
+		ns.Options = append(ns.Options, &LinkLayerAddress{
+			Direction: Source,
+			Addr:      c.ifi.HardwareAddr,
+		})
+	}
+
+	defer c.watchContext(ctx)()
+
+	res := &ProbeResult{Sent: make([]time.Time, 0, count)}
+	for i := 0; i < count; i++ {
+		if err := c.WriteTo(ns, nil, snm); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		res.Sent = append(res.Sent, now)
+
+		if err := c.SetReadDeadline(now.Add(interval)); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		m, from, err := c.readMatch(func(m Message, _ *ipv6.ControlMessage, _ netip.Addr) bool {
+			na, ok := m.(*NeighborAdvertisement)
+			return ok && na.TargetAddress == target.WithZone("")
+		})
+		if err == nil {
+			res.Advertisement = m.(*NeighborAdvertisement)
+			res.From = from
+			res.Received = time.Now()
+			return res, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !isTimeout(err) {
+			return nil, err
+		}
+	}
+
+	// No answer.
+	return res, nil
+}
+
+// NewDuplicateAddressDetectionNS creates a NeighborSolicitation which probes
+// for other nodes using target, as described in RFC 4862, Section 5.4.2. If
+// nonce is not nil, it is included so that looped back probes can be detected,
+// as described in RFC 7527.
+//
+// The resulting NeighborSolicitation must be sent from the unspecified
+// address, such as by a Conn created with the Unspecified Addr, to the
+// solicited-node multicast address of target. Per RFC 4861, Section 4.3, it
+// does not include a source link-layer address option.
+func NewDuplicateAddressDetectionNS(target netip.Addr, nonce *Nonce) (*NeighborSolicitation, error) {
+	if err := checkIPv6(target); err != nil {
+		return nil, err
+	}
+
+	ns := &NeighborSolicitation{TargetAddress: target}
+	if nonce != nil {
+		ns.Options = append(ns.Options, nonce)
+	}
+
+	return ns, nil
+}
+
+// NewUnsolicitedNA creates an unsolicited NeighborAdvertisement which
+// announces that target is now reachable at the link-layer address mac, as
+// described in RFC 4861, Section 7.2.6. This is commonly used to move a
+// floating address from one node to another.
+//
+// The NeighborAdvertisement sets the Override flag so that neighbors update
+// their existing cache entries, clears the Solicited flag, and includes a
+// target link-layer address option. Router indicates whether the sender is a
+// router.
+//
+// The resulting NeighborAdvertisement must be sent to the all-nodes multicast
+// address, ff02::1.
+func NewUnsolicitedNA(target netip.Addr, mac net.HardwareAddr, router bool) (*NeighborAdvertisement, error) {
+	if err := checkIPv6(target); err != nil {
+		return nil, err
+	}
+
+	lla := &LinkLayerAddress{
+		Direction: Target,
+		Addr:      bytes.Clone(mac),
+	}
+
+	// Any link-layer address format supported by the option is valid.
+	if _, err := lla.MarshalBinary(); err != nil {
+		return nil, err
+	}
+
+	return &NeighborAdvertisement{
+		Router:        router,
+		Override:      true,
+		TargetAddress: target,
+		Options:       []Option{lla},
+	}, nil
+}
+
+// IsDAD reports whether ns, received from src, is a duplicate address
+// detection probe: it must be sent from the unspecified address, and must not
+// include a source link-layer address option.
+//
+// The source address is required because it is not part of the
+// NeighborSolicitation itself, and is the defining property of a duplicate
+// address detection probe.
+func IsDAD(ns *NeighborSolicitation, src netip.Addr) bool {
+	if !src.IsUnspecified() {
+		return false
+	}
+
+	for _, o := range ns.Options {
+		if lla, ok := o.(*LinkLayerAddress); ok && lla.Direction == Source {
+			return false
+		}
+	}
+
+	return true
+}
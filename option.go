@@ -13,7 +13,10 @@ import (
 	"net"
 	"net/netip"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -29,22 +32,61 @@ const (
 	ethAddrLen = 6
 
 	// The assumed NDP option length (in units of 8 bytes) for fixed length options.
-	llaOptLen = 1
-	piOptLen  = 4
-	mtuOptLen = 1
+	piOptLen     = 4
+	mtuOptLen    = 1
+	pref64OptLen = 2
+	tsOptLen     = 2
 
 	// Type values for each type of valid Option.
-	optSourceLLA         = 1
-	optTargetLLA         = 2
-	optPrefixInformation = 3
-	optMTU               = 5
-	optNonce             = 14
-	optRouteInformation  = 24
-	optRDNSS             = 25
-	optRAFlagsExtension  = 26
-	optDNSSL             = 31
-	optCaptivePortal     = 37
-	optPREF64            = 38
+	optSourceLLA           = OptionSourceLinkLayerAddress
+	optTargetLLA           = OptionTargetLinkLayerAddress
+	optPrefixInformation   = OptionPrefixInformation
+	optMTU                 = OptionMTU
+	optSourceAddressList   = OptionSourceAddressList
+	optTargetAddressList   = OptionTargetAddressList
+	optCGA                 = OptionCGA
+	optRSASignature        = OptionRSASignature
+	optTimestamp           = OptionTimestamp
+	optNonce               = OptionNonce
+	optPvD                 = OptionPvD
+	optRouteInformation    = OptionRouteInformation
+	optRDNSS               = OptionRecursiveDNSServer
+	optRAFlagsExtension    = OptionRAFlagsExtension
+	optDNSSL               = OptionDNSSearchList
+	optAddressRegistration = OptionAddressRegistration
+	optCaptivePortal       = OptionCaptivePortal
+	optPREF64              = OptionPREF64
+	optEncryptedDNS        = OptionEncryptedDNS
+)
+
+// NDP option type codes, as assigned by IANA in the IPv6 Neighbor Discovery
+// Option Formats registry. These are the values returned by Option.Code and
+// stored in RawOption.Type.
+const (
+	OptionSourceLinkLayerAddress = 1
+	OptionTargetLinkLayerAddress = 2
+	OptionPrefixInformation      = 3
+	OptionRedirectedHeader       = 4
+	OptionMTU                    = 5
+	OptionSourceAddressList      = 9
+	OptionTargetAddressList      = 10
+	OptionCGA                    = 11
+	OptionRSASignature           = 12
+	OptionTimestamp              = 13
+	OptionNonce                  = 14
+	OptionPvD                    = 21
+	OptionRouteInformation       = 24
+	OptionRecursiveDNSServer     = 25
+	OptionRAFlagsExtension       = 26
+	OptionDNSSearchList          = 31
+	OptionAddressRegistration    = 33
+	OptionCaptivePortal          = 37
+	OptionPREF64                 = 38
+	OptionEncryptedDNS           = 144
+
+	// Codes reserved for experimentation by RFC 4727, Section 2.
+	OptionExperiment1 = 253
+	OptionExperiment2 = 254
 )
 
 // A Direction specifies the direction of a LinkLayerAddress Option as a source
@@ -57,7 +99,9 @@ const (
 	Target Direction = optTargetLLA
 )
 
-// An Option is a Neighbor Discovery Protocol option.
+// An Option is a Neighbor Discovery Protocol option. Options implemented
+// outside of this package can be decoded by registering them with
+// RegisterOption.
 type Option interface {
 	// Code specifies the NDP option code for an Option.
 	Code() uint8
@@ -66,46 +110,152 @@ type Option interface {
 	// also refer to that field as "Type", but we want to avoid confusion
 	// with Message implementations which already use Type.
 
-	// Called when dealing with a Message's Options.
-	marshal() ([]byte, error)
-	unmarshal(b []byte) error
+	// MarshalBinary and UnmarshalBinary operate on the complete binary form
+	// of an Option, including its type and length fields.
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(b []byte) error
+}
+
+// An OptionAppender is an Option which can append its binary form, including
+// its type and length fields, to an existing buffer and return the extended
+// buffer. This avoids an allocation per Option when many Options are
+// marshaled, such as with AppendOptions. All Options implemented by this
+// package are OptionAppenders.
+type OptionAppender interface {
+	Option
+	AppendBinary(b []byte) ([]byte, error)
+}
+
+// optionRegistry holds the Option constructors added by RegisterOption.
+var optionRegistry struct {
+	mu sync.RWMutex
+	m  map[uint8]func() Option
+}
+
+// RegisterOption registers a constructor for Options with the NDP option code
+// code, so that ParseMessage and Conn.ReadFrom decode them using the Option's
+// UnmarshalBinary method rather than as RawOptions. This enables decoding of
+// experimental or vendor NDP options outside of this package.
+//
+// RegisterOption is typically called from an init function. It panics if fn
+// is nil, if code is already registered, or if code is an option code which
+// this package already implements.
+func RegisterOption(code uint8, fn func() Option) {
+	if fn == nil {
+		panic("ndp: RegisterOption constructor is nil")
+	}
+	if newOption(code, nil) != nil {
+		panic(fmt.Sprintf("ndp: RegisterOption called for built-in option code %d", code))
+	}
+
+	optionRegistry.mu.Lock()
+	defer optionRegistry.mu.Unlock()
+
+	if _, ok := optionRegistry.m[code]; ok {
+		panic(fmt.Sprintf("ndp: RegisterOption called twice for option code %d", code))
+	}
+	if optionRegistry.m == nil {
+		optionRegistry.m = make(map[uint8]func() Option)
+	}
+
+	optionRegistry.m[code] = fn
+}
+
+// registeredOption creates an empty Option for code using a constructor added
+// by RegisterOption, or returns nil if code is not registered.
+func registeredOption(code uint8) Option {
+	optionRegistry.mu.RLock()
+	defer optionRegistry.mu.RUnlock()
+
+	if fn, ok := optionRegistry.m[code]; ok {
+		return fn()
+	}
+
+	return nil
 }
 
 var _ Option = &LinkLayerAddress{}
 
 // A LinkLayerAddress is a Source or Target Link-Layer Address option, as
 // described in RFC 4861, Section 4.6.1.
+//
+// The format of the option depends on the type of link. Addr may be a 6 byte
+// Ethernet address (RFC 2464), an 8 byte EUI-64 address as used by IEEE
+// 802.15.4 links (RFC 4944), or a 20 byte InfiniBand address (RFC 4391).
 type LinkLayerAddress struct {
 	Direction Direction
 	Addr      net.HardwareAddr
 }
 
-// TODO(mdlayher): deal with non-ethernet links and variable option length?
+// llaFormats are the supported formats of link-layer addresses, which are
+// identified by the length of the option in units of 8 bytes. Each address is
+// stored at an offset within the option's value and followed by padding.
+var llaFormats = []struct {
+	addrLen, off int
+	optLen       uint8
+}{
+	// Ethernet, RFC 2464.
+	{addrLen: ethAddrLen, optLen: 1},
+	// EUI-64, RFC 4944.
+	{addrLen: 8, optLen: 2},
+	// InfiniBand, RFC 4391. The address is preceded by 2 reserved bytes.
+	{addrLen: 20, off: 2, optLen: 3},
+}
 
 // Code implements Option.
 func (lla *LinkLayerAddress) Code() byte { return byte(lla.Direction) }
 
-func (lla *LinkLayerAddress) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (lla *LinkLayerAddress) MarshalBinary() ([]byte, error) { return lla.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (lla *LinkLayerAddress) AppendBinary(b []byte) ([]byte, error) { return lla.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (lla *LinkLayerAddress) UnmarshalBinary(b []byte) error { return lla.unmarshal(b) }
+
+// Equal reports whether lla and x are the same LinkLayerAddress.
+func (lla *LinkLayerAddress) Equal(x *LinkLayerAddress) bool {
+	if lla == nil || x == nil {
+		return lla == x
+	}
+
+	return lla.Direction == x.Direction && bytes.Equal(lla.Addr, x.Addr)
+}
+
+// String returns the string representation of a LinkLayerAddress.
+func (lla *LinkLayerAddress) String() string {
+	dir := "source"
+	if lla.Direction == Target {
+		dir = "target"
+	}
+
+	return fmt.Sprintf("%s link-layer address: %s", dir, lla.Addr)
+}
+
+func (lla *LinkLayerAddress) appendBinary(b []byte) ([]byte, error) {
 	if d := lla.Direction; d != Source && d != Target {
 		return nil, fmt.Errorf("ndp: invalid link-layer address direction: %d", d)
 	}
 
-	if len(lla.Addr) != ethAddrLen {
-		return nil, fmt.Errorf("ndp: invalid link-layer address: %q", lla.Addr)
-	}
+	for _, f := range llaFormats {
+		if len(lla.Addr) != f.addrLen {
+			continue
+		}
 
-	raw := &RawOption{
-		Type:   lla.Code(),
-		Length: llaOptLen,
-		Value:  lla.Addr,
+		start := len(b)
+		b = append(b, lla.Code(), f.optLen)
+		b = append(b, make([]byte, f.off)...)
+		b = append(b, lla.Addr...)
+		return appendPadding(b, len(b)-start), nil
 	}
 
-	return raw.marshal()
+	return nil, fmt.Errorf("ndp: invalid link-layer address: %q", lla.Addr)
 }
 
 func (lla *LinkLayerAddress) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -114,16 +264,20 @@ func (lla *LinkLayerAddress) unmarshal(b []byte) error {
 		return fmt.Errorf("ndp: invalid link-layer address direction: %d", d)
 	}
 
-	if l := raw.Length; l != llaOptLen {
-		return fmt.Errorf("ndp: unexpected link-layer address option length: %d", l)
-	}
+	for _, f := range llaFormats {
+		if raw.Length != f.optLen {
+			continue
+		}
 
-	*lla = LinkLayerAddress{
-		Direction: d,
-		Addr:      net.HardwareAddr(raw.Value),
+		*lla = LinkLayerAddress{
+			Direction: d,
+			Addr:      append(lla.Addr[:0], raw.Value[f.off:f.off+f.addrLen]...),
+		}
+
+		return nil
 	}
 
-	return nil
+	return fmt.Errorf("ndp: unexpected link-layer address option length: %d", raw.Length)
 }
 
 var _ Option = new(MTU)
@@ -138,25 +292,74 @@ func NewMTU(mtu uint32) *MTU {
 	return &MTU{MTU: mtu}
 }
 
+// minMTU is the minimum link MTU for IPv6, as described in RFC 8200, Section 5.
+const minMTU = 1280
+
+// NewMTUChecked creates an MTU Option from an MTU value, returning an error if
+// mtu is less than the IPv6 minimum link MTU of 1280 bytes. Hosts ignore such
+// options, per RFC 4861, Section 6.3.4.
+//
+// NewMTUChecked cannot check mtu against the MTU of the advertising interface;
+// use CheckInterface for that purpose.
+func NewMTUChecked(mtu uint32) (*MTU, error) {
+	if mtu < minMTU {
+		return nil, fmt.Errorf("ndp: MTU %d is less than IPv6 minimum MTU %d", mtu, minMTU)
+	}
+
+	return NewMTU(mtu), nil
+}
+
+// CheckInterface reports whether m.MTU is valid for advertisement on the
+// interface ifi: it must be at least 1280 bytes and, per RFC 4861, Section
+// 6.2.1, must not exceed the MTU of the link, since hosts which use a larger
+// MTU cannot communicate on the link. If the MTU of ifi is unknown, only the
+// minimum is checked.
+func (m *MTU) CheckInterface(ifi *net.Interface) error {
+	if m.MTU < minMTU {
+		return fmt.Errorf("ndp: MTU %d is less than IPv6 minimum MTU %d", m.MTU, minMTU)
+	}
+	if ifi.MTU > 0 && uint64(m.MTU) > uint64(ifi.MTU) {
+		return fmt.Errorf("ndp: MTU %d exceeds MTU %d of interface %q", m.MTU, ifi.MTU, ifi.Name)
+	}
+
+	return nil
+}
+
 // Code implements Option.
 func (*MTU) Code() byte { return optMTU }
 
-func (m *MTU) marshal() ([]byte, error) {
-	raw := &RawOption{
-		Type:   m.Code(),
-		Length: mtuOptLen,
-		// 2 reserved bytes, 4 for MTU.
-		Value: make([]byte, 6),
+// MarshalBinary implements Option.
+func (m *MTU) MarshalBinary() ([]byte, error) { return m.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (m *MTU) AppendBinary(b []byte) ([]byte, error) { return m.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (m *MTU) UnmarshalBinary(b []byte) error { return m.unmarshal(b) }
+
+// Equal reports whether m and x are the same MTU.
+func (m *MTU) Equal(x *MTU) bool {
+	if m == nil || x == nil {
+		return m == x
 	}
 
-	binary.BigEndian.PutUint32(raw.Value[2:6], uint32(m.MTU))
+	return *m == *x
+}
+
+// String returns the string representation of an MTU.
+func (m *MTU) String() string {
+	return fmt.Sprintf("MTU: %d", m.MTU)
+}
 
-	return raw.marshal()
+func (m *MTU) appendBinary(b []byte) ([]byte, error) {
+	// 2 reserved bytes, 4 for MTU.
+	b = append(b, m.Code(), mtuOptLen, 0x00, 0x00)
+	return binary.BigEndian.AppendUint32(b, m.MTU), nil
 }
 
 func (m *MTU) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -172,59 +375,156 @@ type PrefixInformation struct {
 	PrefixLength                   uint8
 	OnLink                         bool
 	AutonomousAddressConfiguration bool
-	ValidLifetime                  time.Duration
-	PreferredLifetime              time.Duration
-	Prefix                         netip.Addr
+
+	// RouterAddress is the "R" flag from RFC 6275, Section 7.2. When set,
+	// Prefix contains the complete global address of the sending router
+	// rather than only a prefix, so its bits after PrefixLength are retained
+	// when marshaling and unmarshaling.
+	RouterAddress bool
+
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+	Prefix            netip.Addr
 }
 
 // Code implements Option.
 func (*PrefixInformation) Code() byte { return optPrefixInformation }
 
-func (pi *PrefixInformation) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (pi *PrefixInformation) MarshalBinary() ([]byte, error) { return pi.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (pi *PrefixInformation) AppendBinary(b []byte) ([]byte, error) { return pi.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (pi *PrefixInformation) UnmarshalBinary(b []byte) error { return pi.unmarshal(b) }
+
+// Equal reports whether pi and x are the same PrefixInformation.
+func (pi *PrefixInformation) Equal(x *PrefixInformation) bool {
+	if pi == nil || x == nil {
+		return pi == x
+	}
+
+	return *pi == *x
+}
+
+// IPPrefix returns the Prefix and PrefixLength of pi as a netip.Prefix. Any
+// host bits of Prefix are retained, so IPPrefix does not make an invalid
+// PrefixInformation valid.
+func (pi *PrefixInformation) IPPrefix() netip.Prefix {
+	return netip.PrefixFrom(pi.Prefix, int(pi.PrefixLength))
+}
+
+// SetIPPrefix sets the Prefix and PrefixLength of pi from p. Any host bits of
+// p are cleared, as required by RFC 4861, Section 4.6.2. If p is not a valid
+// prefix, both fields are cleared.
+func (pi *PrefixInformation) SetIPPrefix(p netip.Prefix) {
+	p = p.Masked()
+	if !p.IsValid() {
+		pi.Prefix, pi.PrefixLength = netip.Addr{}, 0
+		return
+	}
+
+	pi.Prefix, pi.PrefixLength = p.Addr(), uint8(p.Bits())
+}
+
+// NewPrefixInformation creates a PrefixInformation Option for prefix. Any host
+// bits of prefix are cleared.
+//
+// An error is returned if prefix is not a valid IPv6 prefix, if prefix is
+// link-local or multicast, if either lifetime is negative, or if preferred is
+// greater than valid. Per RFC 4862, Section 5.5.3, hosts ignore such options.
+// As with NewCaptivePortal, the caller can bypass NewPrefixInformation and
+// construct a PrefixInformation Option directly if needed.
+func NewPrefixInformation(prefix netip.Prefix, onLink, autonomous bool, valid, preferred time.Duration) (*PrefixInformation, error) {
+	if err := checkIPv6Prefix(prefix); err != nil {
+		return nil, err
+	}
+
+	prefix = prefix.Masked()
+	if ip := prefix.Addr(); ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return nil, fmt.Errorf("ndp: invalid prefix information prefix: %s", prefix)
+	}
+
+	if valid < 0 || preferred < 0 {
+		return nil, errors.New("ndp: prefix information lifetimes must not be negative")
+	}
+	if preferred > valid {
+		return nil, fmt.Errorf("ndp: prefix information preferred lifetime %s exceeds valid lifetime %s",
+			preferred, valid)
+	}
+
+	pi := &PrefixInformation{
+		OnLink:                         onLink,
+		AutonomousAddressConfiguration: autonomous,
+		ValidLifetime:                  valid,
+		PreferredLifetime:              preferred,
+	}
+	pi.SetIPPrefix(prefix)
+
+	return pi, nil
+}
+
+// String returns the string representation of a PrefixInformation.
+func (pi *PrefixInformation) String() string {
+	var flags []string
+	if pi.OnLink {
+		flags = append(flags, "on-link")
+	}
+	if pi.AutonomousAddressConfiguration {
+		flags = append(flags, "autonomous")
+	}
+	if pi.RouterAddress {
+		flags = append(flags, "router address")
+	}
+
+	return fmt.Sprintf("prefix information: %s/%d, flags: [%s], valid: %s, preferred: %s",
+		pi.Prefix, pi.PrefixLength, strings.Join(flags, ", "), pi.ValidLifetime, pi.PreferredLifetime)
+}
+
+func (pi *PrefixInformation) appendBinary(b []byte) ([]byte, error) {
 	// Per the RFC:
 	// "The bits in the prefix after the prefix length are reserved and MUST
 	// be initialized to zero by the sender and ignored by the receiver."
 	//
 	// Therefore, any prefix, when masked with its specified length, should be
-	// identical to the prefix itself for it to be valid.
-	p := netip.PrefixFrom(pi.Prefix, int(pi.PrefixLength))
-	if masked := p.Masked(); pi.Prefix != masked.Addr() {
+	// identical to the prefix itself for it to be valid. The exception is a
+	// router address, whose bits after the prefix length are significant.
+	p := pi.IPPrefix()
+	if masked := p.Masked(); pi.Prefix != masked.Addr() && !(pi.RouterAddress && masked.IsValid()) {
 		return nil, fmt.Errorf("ndp: invalid prefix information: %s/%d",
 			pi.Prefix, pi.PrefixLength)
 	}
 
-	raw := &RawOption{
-		Type:   pi.Code(),
-		Length: piOptLen,
-		// 30 bytes for PrefixInformation body.
-		Value: make([]byte, 30),
-	}
-
-	raw.Value[0] = pi.PrefixLength
-
+	var flags uint8
 	if pi.OnLink {
-		raw.Value[1] |= (1 << 7)
+		flags |= (1 << 7)
 	}
 	if pi.AutonomousAddressConfiguration {
-		raw.Value[1] |= (1 << 6)
+		flags |= (1 << 6)
 	}
+	if pi.RouterAddress {
+		flags |= (1 << 5)
+	}
+
+	b = append(b, pi.Code(), piOptLen, pi.PrefixLength, flags)
 
 	valid := pi.ValidLifetime.Seconds()
-	binary.BigEndian.PutUint32(raw.Value[2:6], uint32(valid))
+	b = binary.BigEndian.AppendUint32(b, uint32(valid))
 
 	pref := pi.PreferredLifetime.Seconds()
-	binary.BigEndian.PutUint32(raw.Value[6:10], uint32(pref))
+	b = binary.BigEndian.AppendUint32(b, uint32(pref))
 
 	// 4 bytes reserved.
+	b = append(b, 0x00, 0x00, 0x00, 0x00)
 
-	copy(raw.Value[14:30], pi.Prefix.AsSlice())
-
-	return raw.marshal()
+	prefix := as16(pi.Prefix)
+	return append(b, prefix[:]...), nil
 }
 
 func (pi *PrefixInformation) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -236,6 +536,7 @@ func (pi *PrefixInformation) unmarshal(b []byte) error {
 	var (
 		oFlag = (raw.Value[1] & 0x80) != 0
 		aFlag = (raw.Value[1] & 0x40) != 0
+		rFlag = (raw.Value[1] & 0x20) != 0
 
 		valid     = time.Duration(binary.BigEndian.Uint32(raw.Value[2:6])) * time.Second
 		preferred = time.Duration(binary.BigEndian.Uint32(raw.Value[6:10])) * time.Second
@@ -252,14 +553,18 @@ func (pi *PrefixInformation) unmarshal(b []byte) error {
 	}
 
 	// Per the RFC, bits in prefix past prefix length are ignored by the
-	// receiver.
+	// receiver, unless the prefix is a router address.
 	pl := raw.Value[0]
 	p := netip.PrefixFrom(ip, int(pl)).Masked()
+	if rFlag && p.IsValid() {
+		p = netip.PrefixFrom(ip, int(pl))
+	}
 
 	*pi = PrefixInformation{
 		PrefixLength:                   pl,
 		OnLink:                         oFlag,
 		AutonomousAddressConfiguration: aFlag,
+		RouterAddress:                  rFlag,
 		ValidLifetime:                  valid,
 		PreferredLifetime:              preferred,
 		Prefix:                         p.Addr(),
@@ -282,7 +587,101 @@ type RouteInformation struct {
 // Code implements Option.
 func (*RouteInformation) Code() byte { return optRouteInformation }
 
-func (ri *RouteInformation) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (ri *RouteInformation) MarshalBinary() ([]byte, error) { return ri.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (ri *RouteInformation) AppendBinary(b []byte) ([]byte, error) { return ri.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (ri *RouteInformation) UnmarshalBinary(b []byte) error { return ri.unmarshal(b) }
+
+// Equal reports whether ri and x are the same RouteInformation.
+func (ri *RouteInformation) Equal(x *RouteInformation) bool {
+	if ri == nil || x == nil {
+		return ri == x
+	}
+
+	return *ri == *x
+}
+
+// IPPrefix returns the Prefix and PrefixLength of ri as a netip.Prefix. Any
+// host bits of Prefix are retained, so IPPrefix does not make an invalid
+// RouteInformation valid.
+func (ri *RouteInformation) IPPrefix() netip.Prefix {
+	return netip.PrefixFrom(ri.Prefix, int(ri.PrefixLength))
+}
+
+// SetIPPrefix sets the Prefix and PrefixLength of ri from p. Any host bits of
+// p are cleared, as required by RFC 4191, Section 2.3. If p is not a valid
+// prefix, both fields are cleared.
+func (ri *RouteInformation) SetIPPrefix(p netip.Prefix) {
+	p = p.Masked()
+	if !p.IsValid() {
+		ri.Prefix, ri.PrefixLength = netip.Addr{}, 0
+		return
+	}
+
+	ri.Prefix, ri.PrefixLength = p.Addr(), uint8(p.Bits())
+}
+
+// NewRouteInformation creates a RouteInformation Option for prefix with the
+// input preference and lifetime. Any host bits of prefix are cleared. The
+// shortest valid encoding of prefix is chosen when the Option is marshaled.
+//
+// An error is returned if prefix is not a valid IPv6 prefix, if prf is not a
+// valid Preference, or if lifetime cannot be represented on the wire.
+func NewRouteInformation(prefix netip.Prefix, prf Preference, lifetime time.Duration) (*RouteInformation, error) {
+	if err := checkIPv6Prefix(prefix); err != nil {
+		return nil, err
+	}
+	if err := checkPreference(prf); err != nil {
+		return nil, err
+	}
+	if lifetime < 0 || lifetime > Infinity {
+		return nil, fmt.Errorf("ndp: route information lifetime out of range: %s", lifetime)
+	}
+
+	ri := &RouteInformation{
+		Preference:    prf,
+		RouteLifetime: lifetime,
+	}
+	ri.SetIPPrefix(prefix)
+
+	return ri, nil
+}
+
+// A Route is a route to be advertised in a RouteInformation option.
+type Route struct {
+	Prefix     netip.Prefix
+	Preference Preference
+	Lifetime   time.Duration
+}
+
+// RouteInformationOptions creates a RouteInformation Option for each of
+// routes using NewRouteInformation, in order, for use in the Options of a
+// RouterAdvertisement. An error is returned if any Route is invalid.
+func RouteInformationOptions(routes []Route) ([]Option, error) {
+	options := make([]Option, 0, len(routes))
+	for _, r := range routes {
+		ri, err := NewRouteInformation(r.Prefix, r.Preference, r.Lifetime)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, ri)
+	}
+
+	return options, nil
+}
+
+// String returns the string representation of a RouteInformation.
+func (ri *RouteInformation) String() string {
+	return fmt.Sprintf("route information: %s/%d, preference: %s, lifetime: %s",
+		ri.Prefix, ri.PrefixLength, ri.Preference, ri.RouteLifetime)
+}
+
+func (ri *RouteInformation) appendBinary(b []byte) ([]byte, error) {
 	// Per the RFC:
 	// "The bits in the prefix after the prefix length are reserved and MUST
 	// be initialized to zero by the sender and ignored by the receiver."
@@ -290,7 +689,7 @@ func (ri *RouteInformation) marshal() ([]byte, error) {
 	// Therefore, any prefix, when masked with its specified length, should be
 	// identical to the prefix itself for it to be valid.
 	err := fmt.Errorf("ndp: invalid route information: %s/%d", ri.Prefix, ri.PrefixLength)
-	p := netip.PrefixFrom(ri.Prefix, int(ri.PrefixLength))
+	p := ri.IPPrefix()
 	if masked := p.Masked(); ri.Prefix != masked.Addr() {
 		return nil, err
 	}
@@ -310,32 +709,26 @@ func (ri *RouteInformation) marshal() ([]byte, error) {
 		return nil, err
 	}
 
-	raw := &RawOption{
-		Type:   ri.Code(),
-		Length: uint8(iplen) + 1,
-		// Prefix length, preference, lifetime, and prefix body as computed by
-		// using iplen.
-		Value: make([]byte, 1+1+4+(iplen*8)),
+	if err := checkPreference(ri.Preference); err != nil {
+		return nil, err
 	}
 
-	raw.Value[0] = ri.PrefixLength
-
 	// Adjacent bits are reserved.
-	if prf := uint8(ri.Preference); prf != 0 {
-		raw.Value[1] |= (prf << 3)
-	}
+	prf := uint8(ri.Preference) << 3
 
-	lt := ri.RouteLifetime.Seconds()
-	binary.BigEndian.PutUint32(raw.Value[2:6], uint32(lt))
+	b = append(b, ri.Code(), uint8(iplen)+1, ri.PrefixLength, prf)
 
-	copy(raw.Value[6:], ri.Prefix.AsSlice())
+	lt := ri.RouteLifetime.Seconds()
+	b = binary.BigEndian.AppendUint32(b, uint32(lt))
 
-	return raw.marshal()
+	// Prefix body as computed by using iplen.
+	prefix := as16(ri.Prefix)
+	return append(b, prefix[:iplen*8]...), nil
 }
 
 func (ri *RouteInformation) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -373,20 +766,20 @@ func (ri *RouteInformation) unmarshal(b []byte) error {
 		return err
 	}
 
-	// Take up to the specified number of IP bytes into the prefix.
+	// Take up to the specified number of IP bytes into the prefix, including
+	// a final partial byte, and ignore any bits after the prefix length.
 	var (
 		addr [16]byte
-		buf  = raw.Value[6 : 6+(l/8)]
+		buf  = raw.Value[6 : 6+(int(l)+7)/8]
 	)
 
 	copy(addr[:], buf)
 
 	*ri = RouteInformation{
-		PrefixLength:  l,
 		Preference:    pref,
 		RouteLifetime: lt,
-		Prefix:        netip.AddrFrom16(addr),
 	}
+	ri.SetIPPrefix(netip.PrefixFrom(netip.AddrFrom16(addr), int(l)))
 
 	return nil
 }
@@ -401,6 +794,83 @@ type RecursiveDNSServer struct {
 // Code implements Option.
 func (*RecursiveDNSServer) Code() byte { return optRDNSS }
 
+// MarshalBinary implements Option.
+func (r *RecursiveDNSServer) MarshalBinary() ([]byte, error) { return r.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (r *RecursiveDNSServer) AppendBinary(b []byte) ([]byte, error) { return r.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (r *RecursiveDNSServer) UnmarshalBinary(b []byte) error { return r.unmarshal(b) }
+
+// Equal reports whether r and x are the same RecursiveDNSServer.
+func (r *RecursiveDNSServer) Equal(x *RecursiveDNSServer) bool {
+	if r == nil || x == nil {
+		return r == x
+	}
+
+	if r.Lifetime != x.Lifetime || len(r.Servers) != len(x.Servers) {
+		return false
+	}
+
+	for i := range r.Servers {
+		if r.Servers[i] != x.Servers[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewRecursiveDNSServer creates a RecursiveDNSServer Option with the input
+// lifetime and servers. An error is returned if no servers are specified, if
+// any server is not an IPv6 address, or if lifetime cannot be represented on
+// the wire.
+//
+// NewRecursiveDNSServer cannot check lifetime against the router's
+// advertisement interval; use CheckLifetime for that purpose.
+func NewRecursiveDNSServer(lifetime time.Duration, servers ...netip.Addr) (*RecursiveDNSServer, error) {
+	if len(servers) == 0 {
+		return nil, errRDNSSNoServers
+	}
+	for _, s := range servers {
+		if err := checkIPv6(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if lifetime < 0 || lifetime > Infinity {
+		return nil, fmt.Errorf("ndp: recursive DNS server option lifetime out of range: %s", lifetime)
+	}
+
+	return &RecursiveDNSServer{
+		Lifetime: lifetime,
+		Servers:  append([]netip.Addr(nil), servers...),
+	}, nil
+}
+
+// CheckLifetime reports whether r.Lifetime is within the bounds recommended by
+// RFC 8106, Section 5.1 for a router which sends unsolicited router
+// advertisements at most every maxRtrAdvInterval. A non-zero Lifetime should
+// be at least 3 * maxRtrAdvInterval, so that servers do not expire when a
+// small number of advertisements are lost. A Lifetime of zero is always
+// permitted, as it indicates the servers should no longer be used.
+//
+// The returned error is advisory: r can still be marshaled and sent.
+func (r *RecursiveDNSServer) CheckLifetime(maxRtrAdvInterval time.Duration) error {
+	// Bounds from RFC 4861, Section 6.2.1.
+	if maxRtrAdvInterval < 4*time.Second || maxRtrAdvInterval > 1800*time.Second {
+		return fmt.Errorf("ndp: invalid MaxRtrAdvInterval: %s", maxRtrAdvInterval)
+	}
+
+	if lower := 3 * maxRtrAdvInterval; r.Lifetime != 0 && r.Lifetime < lower {
+		return fmt.Errorf("ndp: recursive DNS server option lifetime %s is less than recommended minimum %s",
+			r.Lifetime, lower)
+	}
+
+	return nil
+}
+
 // Offsets for the RDNSS option.
 const (
 	rdnssLifetimeOff = 2
@@ -412,43 +882,39 @@ var (
 	errRDNSSBadServer = errors.New("ndp: recursive DNS server option has malformed IPv6 address")
 )
 
-func (r *RecursiveDNSServer) marshal() ([]byte, error) {
+// String returns the string representation of a RecursiveDNSServer.
+func (r *RecursiveDNSServer) String() string {
+	return fmt.Sprintf("recursive DNS servers: lifetime: %s, servers: %s", r.Lifetime, joinAddrs(r.Servers))
+}
+
+func (r *RecursiveDNSServer) appendBinary(b []byte) ([]byte, error) {
 	slen := len(r.Servers)
 	if slen == 0 {
 		return nil, errRDNSSNoServers
 	}
 
-	raw := &RawOption{
-		Type: r.Code(),
-		// Always have one length unit to start, and then each IPv6 address
-		// occupies two length units.
-		Length: 1 + uint8((slen * 2)),
-		// Allocate enough space for all data.
-		Value: make([]byte, rdnssServersOff+(slen*net.IPv6len)),
+	// Always have one length unit to start, and then each IPv6 address
+	// occupies two length units.
+	l, err := optionLength(8 + (slen * 2 * 8))
+	if err != nil {
+		return nil, err
 	}
 
-	binary.BigEndian.PutUint32(
-		raw.Value[rdnssLifetimeOff:rdnssServersOff],
-		uint32(r.Lifetime.Seconds()),
-	)
-
-	for i := 0; i < len(r.Servers); i++ {
-		// Determine the start and end byte offsets for each address,
-		// effectively iterating 16 bytes at a time to insert an address.
-		var (
-			start = rdnssServersOff + (i * net.IPv6len)
-			end   = rdnssServersOff + net.IPv6len + (i * net.IPv6len)
-		)
+	// 2 reserved bytes precede the lifetime.
+	b = append(b, r.Code(), l, 0x00, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(r.Lifetime.Seconds()))
 
-		copy(raw.Value[start:end], r.Servers[i].AsSlice())
+	for _, s := range r.Servers {
+		ip := as16(s)
+		b = append(b, ip[:]...)
 	}
 
-	return raw.marshal()
+	return b, nil
 }
 
 func (r *RecursiveDNSServer) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -473,7 +939,10 @@ func (r *RecursiveDNSServer) unmarshal(b []byte) error {
 		return errRDNSSNoServers
 	}
 
-	servers := make([]netip.Addr, 0, count)
+	servers := r.Servers[:0]
+	if cap(servers) < count {
+		servers = make([]netip.Addr, 0, count)
+	}
 	for i := 0; i < count; i++ {
 		// Determine the start and end byte offsets for each address,
 		// effectively iterating 16 bytes at a time to fetch an address.
@@ -503,11 +972,89 @@ func (r *RecursiveDNSServer) unmarshal(b []byte) error {
 type DNSSearchList struct {
 	Lifetime    time.Duration
 	DomainNames []string
+
+	// Profile, if non-nil, specifies the IDNA profile used to convert
+	// DomainNames to and from their ASCII wire format. If nil, idna.Punycode
+	// is used.
+	Profile *idna.Profile
+
+	// NoIDNA disables IDNA processing of DomainNames. Each domain name must
+	// then consist of ASCII characters and is sent and received verbatim,
+	// including any Punycode labels.
+	//
+	// Profile and NoIDNA configure encoding and decoding of d, and are
+	// preserved by UnmarshalBinary and ignored by Equal.
+	NoIDNA bool
+
+	// RawLabels, if set, replaces DomainNames with Labels: UnmarshalBinary
+	// stores the exact wire-format labels of each domain name in Labels
+	// without validating or decoding them, and MarshalBinary emits Labels
+	// verbatim. This permits forwarding a DNS search list byte-for-byte.
+	//
+	// Like Profile and NoIDNA, RawLabels is preserved by UnmarshalBinary.
+	RawLabels bool
+
+	// Labels holds the labels of each domain name when RawLabels is set, such
+	// that Labels[i][j] is label j of domain name i.
+	Labels [][][]byte
 }
 
 // Code implements Option.
 func (*DNSSearchList) Code() byte { return optDNSSL }
 
+// MarshalBinary implements Option.
+func (d *DNSSearchList) MarshalBinary() ([]byte, error) { return d.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (d *DNSSearchList) AppendBinary(b []byte) ([]byte, error) { return d.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (d *DNSSearchList) UnmarshalBinary(b []byte) error { return d.unmarshal(b) }
+
+// Equal reports whether d and x are the same DNSSearchList.
+func (d *DNSSearchList) Equal(x *DNSSearchList) bool {
+	if d == nil || x == nil {
+		return d == x
+	}
+
+	if d.Lifetime != x.Lifetime || len(d.DomainNames) != len(x.DomainNames) || len(d.Labels) != len(x.Labels) {
+		return false
+	}
+
+	for i := range d.DomainNames {
+		if d.DomainNames[i] != x.DomainNames[i] {
+			return false
+		}
+	}
+
+	for i := range d.Labels {
+		if len(d.Labels[i]) != len(x.Labels[i]) {
+			return false
+		}
+
+		for j := range d.Labels[i] {
+			if !bytes.Equal(d.Labels[i][j], x.Labels[i][j]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// profile returns the IDNA profile for d, or nil if IDNA processing is
+// disabled.
+func (d *DNSSearchList) profile() *idna.Profile {
+	switch {
+	case d.NoIDNA:
+		return nil
+	case d.Profile != nil:
+		return d.Profile
+	default:
+		return idna.Punycode
+	}
+}
+
 // Offsets for the RDNSS option.
 const (
 	dnsslLifetimeOff = 2
@@ -519,63 +1066,68 @@ var (
 	errDNSSLNoDomains  = errors.New("ndp: DNS search list option requires at least one domain name")
 )
 
-func (d *DNSSearchList) marshal() ([]byte, error) {
-	if len(d.DomainNames) == 0 {
-		return nil, errDNSSLNoDomains
+// String returns the string representation of a DNSSearchList.
+func (d *DNSSearchList) String() string {
+	names := d.DomainNames
+	if d.RawLabels {
+		names = make([]string, 0, len(d.Labels))
+		for _, ls := range d.Labels {
+			names = append(names, strconv.Quote(string(bytes.Join(ls, []byte(".")))))
+		}
 	}
 
-	// Make enough room for reserved bytes and lifetime.
-	value := make([]byte, dnsslDomainsOff)
+	return fmt.Sprintf("DNS search list: lifetime: %s, domain names: %s", d.Lifetime, strings.Join(names, ", "))
+}
 
-	binary.BigEndian.PutUint32(
-		value[dnsslLifetimeOff:dnsslDomainsOff],
-		uint32(d.Lifetime.Seconds()),
-	)
+func (d *DNSSearchList) appendBinary(b []byte) ([]byte, error) {
+	n := len(d.DomainNames)
+	if d.RawLabels {
+		n = len(d.Labels)
+	}
+	if n == 0 {
+		return nil, errDNSSLNoDomains
+	}
 
-	// Attach each label component of a domain name with a one byte length prefix
-	// and a null terminator between full domain names, using the algorithm from:
-	// https://tools.ietf.org/html/rfc1035#section-3.1.
-	for _, dn := range d.DomainNames {
-		// All unicode names must be converted to punycode.
-		dn, err := idna.ToASCII(dn)
-		if err != nil {
-			return nil, errDNSSLBadDomains
-		}
+	// The length is computed once all domain names have been appended, and 2
+	// reserved bytes precede the lifetime.
+	start := len(b)
+	b = append(b, d.Code(), 0x00, 0x00, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(d.Lifetime.Seconds()))
 
-		for _, label := range strings.Split(dn, ".") {
-			// Label must be convertable to valid Punycode.
-			if !isASCII(label) {
+	if d.RawLabels {
+		for _, labels := range d.Labels {
+			var ok bool
+			b, ok = appendLabels(b, labels)
+			if !ok {
+				return nil, errDNSSLBadDomains
+			}
+		}
+	} else {
+		for _, dn := range d.DomainNames {
+			var ok bool
+			b, ok = appendDomain(b, dn, d.profile())
+			if !ok {
 				return nil, errDNSSLBadDomains
 			}
-
-			value = append(value, byte(len(label)))
-			value = append(value, label...)
 		}
-
-		value = append(value, 0)
 	}
 
-	// Pad null bytes into value, so that when combined with type and length,
-	// the entire buffer length is divisible by 8 bytes for proper NDP option
-	// length.
-	if r := (len(value) + 2) % 8; r != 0 {
-		value = append(value, bytes.Repeat([]byte{0x00}, 8-r)...)
-	}
+	// Pad null bytes so that the entire option length is divisible by 8 bytes
+	// for proper NDP option length.
+	b = appendPadding(b, len(b)-start)
 
-	raw := &RawOption{
-		Type: d.Code(),
-		// Always have one length unit to start, and then calculate the length
-		// needed for value.
-		Length: uint8((len(value) + 2) / 8),
-		Value:  value,
+	l, err := optionLength(len(b) - start)
+	if err != nil {
+		return nil, err
 	}
 
-	return raw.marshal()
+	b[start+1] = l
+	return b, nil
 }
 
 func (d *DNSSearchList) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -583,85 +1135,51 @@ func (d *DNSSearchList) unmarshal(b []byte) error {
 	lt := time.Duration(binary.BigEndian.Uint32(
 		raw.Value[dnsslLifetimeOff:dnsslDomainsOff])) * time.Second
 
-	// This block implements the domain name space parsing algorithm from:
-	// https://tools.ietf.org/html/rfc1035#section-3.1.
-	//
-	// A domain is comprised of a sequence of labels, which are accumulated and
-	// then separated by periods later on.
-	var domains []string
-	var labels []string
-	for i := dnsslDomainsOff; ; {
-		if len(raw.Value[i:]) < 2 {
-			return errDNSSLBadDomains
-		}
-
-		// Parse the length of the upcoming label.
-		length := int(raw.Value[i])
-		if length >= len(raw.Value[i:])-1 {
-			// Length out of range.
-			return errDNSSLBadDomains
-		}
-		if length == 0 {
-			// No more labels.
-			break
-		}
-		i++
-
-		// Parse the label string and ensure it is ASCII, and that it doesn't
-		// contain invalid characters.
-		label := string(raw.Value[i : i+length])
-		if !isASCII(label) {
-			return errDNSSLBadDomains
-		}
-
-		// TODO(mdlayher): much smarter validation.
-		if label == "" || strings.Contains(label, ".") || strings.Contains(label, " ") {
-			return errDNSSLBadDomains
-		}
+	// Raw labels are copied out of b at once and sliced from the copy.
+	value := raw.Value
+	if d.RawLabels {
+		value = append([]byte(nil), value...)
+	}
 
-		// Verify that the Punycode label decodes to something sane.
-		label, err := idna.ToUnicode(label)
-		if err != nil {
-			return errDNSSLBadDomains
+	// Parse domain names until reaching the null padding bytes at the end of
+	// the option.
+	var (
+		domains []string
+		labels  [][][]byte
+	)
+	for i := dnsslDomainsOff; i < len(value) && value[i] != 0; {
+		var (
+			n  int
+			ok bool
+		)
+		if d.RawLabels {
+			var ls [][]byte
+			ls, n, ok = parseLabels(value[i:])
+			labels = append(labels, ls)
+		} else {
+			var domain string
+			domain, n, ok = parseDomain(value[i:], d.profile())
+			domains = append(domains, domain)
 		}
-
-		// TODO(mdlayher): much smarter validation.
-		if label == "" || hasUnicodeReplacement(label) || strings.Contains(label, ".") || strings.Contains(label, " ") {
+		if !ok {
 			return errDNSSLBadDomains
 		}
 
-		labels = append(labels, label)
-		i += length
-
-		// If we've reached a null byte, join labels into a domain name and
-		// empty the label stack for reuse.
-		if raw.Value[i] == 0 {
-			i++
-
-			domain, err := idna.ToUnicode(strings.Join(labels, "."))
-			if err != nil {
-				return errDNSSLBadDomains
-			}
-
-			domains = append(domains, domain)
-			labels = []string{}
-
-			// Have we reached the end of the value slice?
-			if len(raw.Value[i:]) == 0 || (len(raw.Value[i:]) == 1 && raw.Value[i] == 0) {
-				// No more non-padding bytes, no more labels.
-				break
-			}
-		}
+		i += n
 	}
 
 	// Must have found at least one domain.
-	if len(domains) == 0 {
+	if len(domains) == 0 && len(labels) == 0 {
 		return errDNSSLNoDomains
 	}
 
 	*d = DNSSearchList{
 		Lifetime:    lt,
 		DomainNames: domains,
+		Profile:     d.Profile,
+		NoIDNA:      d.NoIDNA,
+		RawLabels:   d.RawLabels,
+		Labels:      labels,
 	}
 
 	return nil
@@ -681,6 +1199,9 @@ type CaptivePortal struct {
 // a special case, if uri is empty, Unrestricted is used as the CaptivePortal
 // OptionURI.
 //
+// An error is returned if uri is longer than the 2038 bytes which fit in an
+// option.
+//
 // If uri is an IP address literal, an error is returned. Per RFC 8910, uri
 // "SHOULD NOT" be an IP address, but there are circumstances where this
 // behavior may be useful. In that case, the caller can bypass NewCaptivePortal
@@ -690,8 +1211,10 @@ func NewCaptivePortal(uri string) (*CaptivePortal, error) {
 		return &CaptivePortal{URI: Unrestricted}, nil
 	}
 
-	// Try to comply with the max limit for DHCPv4.
-	if len(uri) > 255 {
+	// The URI must fit in the largest possible option, less 2 bytes for the
+	// type and length. Note that the equivalent DHCPv4 option is limited to
+	// 255 bytes.
+	if len(uri) > maxOptionLen-2 {
 		return nil, errors.New("ndp: captive portal option URI is too long")
 	}
 
@@ -721,33 +1244,66 @@ func NewCaptivePortal(uri string) (*CaptivePortal, error) {
 // Code implements Option.
 func (*CaptivePortal) Code() byte { return optCaptivePortal }
 
-func (cp *CaptivePortal) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (cp *CaptivePortal) MarshalBinary() ([]byte, error) { return cp.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (cp *CaptivePortal) AppendBinary(b []byte) ([]byte, error) { return cp.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (cp *CaptivePortal) UnmarshalBinary(b []byte) error { return cp.unmarshal(b) }
+
+// Equal reports whether cp and x are the same CaptivePortal. Trailing null
+// padding bytes in URI are ignored.
+func (cp *CaptivePortal) Equal(x *CaptivePortal) bool {
+	if cp == nil || x == nil {
+		return cp == x
+	}
+
+	return cp.uri() == x.uri()
+}
+
+// IsUnrestricted reports whether cp indicates a network with no captive portal
+// restrictions, using the Unrestricted URN from RFC 8910, Section 2. The
+// comparison is case-insensitive.
+func (cp *CaptivePortal) IsUnrestricted() bool {
+	return strings.EqualFold(cp.uri(), Unrestricted)
+}
+
+// URL parses cp.URI as a URL. Trailing null padding bytes in URI are ignored.
+func (cp *CaptivePortal) URL() (*url.URL, error) {
+	return url.Parse(cp.uri())
+}
+
+// uri returns cp.URI with any trailing null padding bytes removed.
+func (cp *CaptivePortal) uri() string { return strings.TrimRight(cp.URI, "\x00") }
+
+// String returns the string representation of a CaptivePortal.
+func (cp *CaptivePortal) String() string {
+	return fmt.Sprintf("captive portal: %s", cp.uri())
+}
+
+func (cp *CaptivePortal) appendBinary(b []byte) ([]byte, error) {
 	if len(cp.URI) == 0 {
 		return nil, errors.New("ndp: captive portal option requires a non-empty URI")
 	}
 
 	// Pad up to next unit of 8 bytes including 2 bytes for code, length, and
 	// bytes for the URI string. Extra bytes will be null.
-	l := len(cp.URI)
-	if r := (l + 2) % 8; r != 0 {
-		l += 8 - r
-	}
-
-	value := make([]byte, l)
-	copy(value, []byte(cp.URI))
-
-	raw := &RawOption{
-		Type:   cp.Code(),
-		Length: (uint8(l) + 2) / 8,
-		Value:  value,
+	n := len(cp.URI) + 2
+	l, err := optionLength(n + padLen(n))
+	if err != nil {
+		return nil, err
 	}
 
-	return raw.marshal()
+	b = append(b, cp.Code(), l)
+	b = append(b, cp.URI...)
+	return appendPadding(b, n), nil
 }
 
 func (cp *CaptivePortal) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -781,7 +1337,67 @@ type PREF64 struct {
 
 func (p *PREF64) Code() byte { return optPREF64 }
 
-func (p *PREF64) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (p *PREF64) MarshalBinary() ([]byte, error) { return p.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (p *PREF64) AppendBinary(b []byte) ([]byte, error) { return p.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (p *PREF64) UnmarshalBinary(b []byte) error { return p.unmarshal(b) }
+
+// Equal reports whether p and x are the same PREF64.
+func (p *PREF64) Equal(x *PREF64) bool {
+	if p == nil || x == nil {
+		return p == x
+	}
+
+	return *p == *x
+}
+
+// Bounds for the PREF64 option lifetime, which is encoded as a 13-bit count of
+// 8 second units.
+const (
+	pref64LifetimeUnit = 8 * time.Second
+	pref64MaxLifetime  = 8191 * pref64LifetimeUnit
+
+	// The default AdvDefaultLifetime of 3 * MaxRtrAdvInterval from RFC 4861,
+	// Section 6.2.1.
+	defaultRouterLifetime = 3 * 600 * time.Second
+)
+
+// PREF64Lifetime returns a lifetime for a PREF64 option carried by ra, derived
+// from ra.RouterLifetime as recommended by RFC 8781, Section 4.1, so that the
+// prefix remains valid for as long as the router. If ra.RouterLifetime is 0,
+// the RFC 4861 default router lifetime of 30 minutes is used instead, since a
+// router which is not a default router may still advertise a PREF64.
+//
+// The lifetime is rounded up to a multiple of 8 seconds, and limited to the
+// maximum of 65528 seconds, so that it can be encoded without loss.
+func PREF64Lifetime(ra *RouterAdvertisement) time.Duration {
+	lt := ra.RouterLifetime
+	if lt <= 0 {
+		lt = defaultRouterLifetime
+	}
+
+	// Round up to the next unit, which also ensures that a lifetime of less
+	// than one unit is not encoded as 0.
+	if r := lt % pref64LifetimeUnit; r != 0 {
+		lt += pref64LifetimeUnit - r
+	}
+	if lt > pref64MaxLifetime {
+		lt = pref64MaxLifetime
+	}
+
+	return lt
+}
+
+// String returns the string representation of a PREF64.
+func (p *PREF64) String() string {
+	return fmt.Sprintf("pref64: %s, lifetime: %s", p.Prefix, p.Lifetime)
+}
+
+func (p *PREF64) appendBinary(b []byte) ([]byte, error) {
 	var plc uint8
 	switch p.Prefix.Bits() {
 	case 96:
@@ -807,32 +1423,25 @@ func (p *PREF64) marshal() ([]byte, error) {
 		return nil, errors.New("ndp: pref64 scaled lifetime is too large")
 	}
 
-	value := []byte{}
+	// The scaled lifetime and PLC values live within the same 16-bit field,
+	// followed by the 96 most significant bits of the prefix.
+	b = append(b, p.Code(), pref64OptLen)
 
-	// The scaled lifetime and PLC values live within the same 16-bit field.
 	// Here we move the scaled lifetime to the left-most 13 bits and place the
 	// PLC at the last 3 bits of the 16-bit field.
-	value = binary.BigEndian.AppendUint16(
-		value,
+	b = binary.BigEndian.AppendUint16(
+		b,
 		(scaledLifetime<<3&(0xffff^0b111))|uint16(plc&0b111),
 	)
 
 	allPrefixBits := p.Prefix.Masked().Addr().As16()
 	optionPrefixBits := allPrefixBits[:96/8]
-	value = append(value, optionPrefixBits...)
-
-	raw := &RawOption{
-		Type:   p.Code(),
-		Length: (uint8(len(value)) + 2) / 8,
-		Value:  value,
-	}
-
-	return raw.marshal()
+	return append(b, optionPrefixBits...), nil
 }
 
 func (p *PREF64) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -893,10 +1502,80 @@ type RAFlagsExtension struct {
 // RAFlagsExtension.
 type RAFlags []byte
 
+// raFlagsFirstBit is the IANA bit number of the first bit of RAFlags, as bits
+// 0 through 7 are carried in the Router Advertisement message itself.
+const raFlagsFirstBit = 8
+
+// Bit reports whether the flag with bit number n is set, where n is numbered
+// as in the IANA IPv6 ND Router Advertisement flags registry, described in
+// RFC 5175, Section 5. Bits which are out of range of f are not set.
+func (f RAFlags) Bit(n int) bool {
+	i := n - raFlagsFirstBit
+	if i < 0 || i/8 >= len(f) {
+		return false
+	}
+
+	return f[i/8]&(0x80>>(i%8)) != 0
+}
+
+// SetBit sets or clears the flag with bit number n, which is numbered in the
+// same way as for Bit. If n is out of range of f, f is extended to the next
+// length which is valid for an RAFlagsExtension. SetBit panics if n is less
+// than 8, as those flags are carried by a RouterAdvertisement.
+func (f *RAFlags) SetBit(n int, on bool) {
+	i := n - raFlagsFirstBit
+	if i < 0 {
+		panic(fmt.Sprintf("ndp: RA flags bit %d is carried by the router advertisement", n))
+	}
+
+	if l := i/8 + 1; l > len(*f) {
+		if !on {
+			return
+		}
+
+		// Account for the option type and length when padding the flags,
+		// which are always at least 6 bytes.
+		if l < 6 {
+			l = 6
+		}
+		l += padLen(l + 2)
+		*f = append(*f, make([]byte, l-len(*f))...)
+	}
+
+	if on {
+		(*f)[i/8] |= 0x80 >> (i % 8)
+	} else {
+		(*f)[i/8] &^= 0x80 >> (i % 8)
+	}
+}
+
 // Code implements Option.
 func (*RAFlagsExtension) Code() byte { return optRAFlagsExtension }
 
-func (ra *RAFlagsExtension) marshal() ([]byte, error) {
+// MarshalBinary implements Option.
+func (ra *RAFlagsExtension) MarshalBinary() ([]byte, error) { return ra.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (ra *RAFlagsExtension) AppendBinary(b []byte) ([]byte, error) { return ra.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (ra *RAFlagsExtension) UnmarshalBinary(b []byte) error { return ra.unmarshal(b) }
+
+// Equal reports whether ra and x are the same RAFlagsExtension.
+func (ra *RAFlagsExtension) Equal(x *RAFlagsExtension) bool {
+	if ra == nil || x == nil {
+		return ra == x
+	}
+
+	return bytes.Equal(ra.Flags, x.Flags)
+}
+
+// String returns the string representation of an RAFlagsExtension.
+func (ra *RAFlagsExtension) String() string {
+	return fmt.Sprintf("RA flags extension: [%# 02x]", []byte(ra.Flags))
+}
+
+func (ra *RAFlagsExtension) appendBinary(b []byte) ([]byte, error) {
 	// "MUST NOT be added to a Router Advertisement message if no flags in the
 	// option are set."
 	//
@@ -915,26 +1594,23 @@ func (ra *RAFlagsExtension) marshal() ([]byte, error) {
 
 	// Enforce the option size matches the next unit of 8 bytes including 2
 	// bytes for code and length.
-	l := len(ra.Flags)
-	if r := (l + 2) % 8; r != 0 {
+	n := len(ra.Flags) + 2
+	if padLen(n) != 0 {
 		return nil, errors.New("ndp: RA flags extension length is invalid")
 	}
 
-	value := make([]byte, l)
-	copy(value, ra.Flags)
-
-	raw := &RawOption{
-		Type:   ra.Code(),
-		Length: (uint8(l) + 2) / 8,
-		Value:  value,
+	l, err := optionLength(n)
+	if err != nil {
+		return nil, err
 	}
 
-	return raw.marshal()
+	b = append(b, ra.Code(), l)
+	return append(b, ra.Flags...), nil
 }
 
 func (ra *RAFlagsExtension) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
@@ -943,8 +1619,91 @@ func (ra *RAFlagsExtension) unmarshal(b []byte) error {
 		return errors.New("ndp: RA Flags Extension too short")
 	}
 
-	// raw already made a copy.
-	ra.Flags = raw.Value
+	ra.Flags = append(ra.Flags[:0], raw.Value...)
+	return nil
+}
+
+// defaultTimestampDelta is the default TIMESTAMP_DELTA value, as described in
+// RFC 3971, Section 5.3.5.
+const defaultTimestampDelta = 300 * time.Second
+
+var _ Option = &Timestamp{}
+
+// A Timestamp is a Timestamp option, as described in RFC 3971, Section 5.3.1.
+// The timestamp is encoded with a precision of 1/65536 seconds, so Time is
+// truncated to that precision when marshaled.
+type Timestamp struct {
+	Time time.Time
+}
+
+// Code implements Option.
+func (*Timestamp) Code() byte { return optTimestamp }
+
+// MarshalBinary implements Option.
+func (ts *Timestamp) MarshalBinary() ([]byte, error) { return ts.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (ts *Timestamp) AppendBinary(b []byte) ([]byte, error) { return ts.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (ts *Timestamp) UnmarshalBinary(b []byte) error { return ts.unmarshal(b) }
+
+// Equal reports whether ts and x are the same Timestamp.
+func (ts *Timestamp) Equal(x *Timestamp) bool {
+	if ts == nil || x == nil {
+		return ts == x
+	}
+
+	return ts.Time.Equal(x.Time)
+}
+
+// WithinSkew reports whether the Timestamp is within delta of now, as required
+// to accept a message from a new peer in RFC 3971, Section 5.3.4.2. If delta
+// is zero, the default TIMESTAMP_DELTA of 5 minutes is used.
+func (ts *Timestamp) WithinSkew(now time.Time, delta time.Duration) bool {
+	if delta == 0 {
+		delta = defaultTimestampDelta
+	}
+
+	d := now.Sub(ts.Time)
+	return -delta < d && d < delta
+}
+
+// String returns the string representation of a Timestamp.
+func (ts *Timestamp) String() string {
+	return fmt.Sprintf("timestamp: %s", ts.Time.UTC().Format(time.RFC3339Nano))
+}
+
+func (ts *Timestamp) appendBinary(b []byte) ([]byte, error) {
+	// The timestamp is a 48-bit count of seconds since the UNIX epoch,
+	// followed by a 16-bit fraction of a second.
+	sec := ts.Time.Unix()
+	if sec < 0 || sec >= 1<<48 {
+		return nil, fmt.Errorf("ndp: timestamp out of range: %s", ts.Time)
+	}
+
+	frac := uint64(ts.Time.Nanosecond()) << 16 / uint64(time.Second)
+
+	b = append(b, ts.Code(), tsOptLen)
+	b = append(b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	return binary.BigEndian.AppendUint64(b, uint64(sec)<<16|frac), nil
+}
+
+func (ts *Timestamp) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	if raw.Length != tsOptLen {
+		return fmt.Errorf("ndp: unexpected timestamp option length: %d", raw.Length)
+	}
+
+	// Skip reserved bytes.
+	v := binary.BigEndian.Uint64(raw.Value[6:])
+	ns := (v & 0xffff) * uint64(time.Second) >> 16
+
+	*ts = Timestamp{Time: time.Unix(int64(v>>16), int64(ns))}
 	return nil
 }
 
@@ -955,185 +1714,1991 @@ type Nonce struct {
 
 // NewNonce creates a Nonce option with an opaque random value.
 func NewNonce() *Nonce {
-	// Minimum is 6 bytes, and this is also the only value that the Linux kernel
-	// recognizes as of kernel 5.17.
-	const n = 6
-	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		panicf("ndp: failed to generate nonce bytes: %v", err)
+	n, err := NewNonceFrom(rand.Reader)
+	if err != nil {
+		panicf("%v", err)
+	}
+
+	return n
+}
+
+// Minimum is 6 bytes, and this is also the only value that the Linux kernel
+// recognizes as of kernel 5.17.
+const minNonceLen = 6
+
+// NewNonceFrom creates a Nonce option with an opaque value read from r. It is
+// intended for simulations and tests which require reproducible nonces, such
+// as by using a *math/rand.Rand with a fixed seed. Use NewNonce to create a
+// Nonce using a cryptographically secure random source.
+func NewNonceFrom(r io.Reader) (*Nonce, error) {
+	return newNonce(r, minNonceLen)
+}
+
+// NewNonceSize creates a Nonce option with an opaque random value of size
+// bytes. The size must be 6 bytes plus a multiple of 8 bytes, so that the
+// option is aligned to 8 bytes. Note that some implementations, including
+// Linux, only recognize 6 byte nonces.
+func NewNonceSize(size int) (*Nonce, error) {
+	if err := checkNonceLen(size); err != nil {
+		return nil, err
+	}
+
+	return newNonce(rand.Reader, size)
+}
+
+// NewNonceBytes creates a Nonce option with a copy of the nonce value b, such
+// as a nonce which was previously persisted using Bytes. The length of b must
+// be 6 bytes plus a multiple of 8 bytes.
+func NewNonceBytes(b []byte) (*Nonce, error) {
+	if err := checkNonceLen(len(b)); err != nil {
+		return nil, err
+	}
+
+	return &Nonce{b: append([]byte(nil), b...)}, nil
+}
+
+func newNonce(r io.Reader, size int) (*Nonce, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("ndp: failed to generate nonce bytes: %v", err)
+	}
+
+	return &Nonce{b: b}, nil
+}
+
+// checkNonceLen verifies that a nonce of n bytes is at least the minimum size
+// and fills a whole number of 8 byte units, including 2 bytes for the option
+// code and length.
+func checkNonceLen(n int) error {
+	if n < minNonceLen || padLen(n+2) != 0 {
+		return fmt.Errorf("ndp: invalid nonce size %d: must be 6 bytes plus a multiple of 8 bytes", n)
 	}
 
-	return &Nonce{b: b}
+	return nil
 }
 
+// Bytes returns a copy of the nonce value of n.
+func (n *Nonce) Bytes() []byte { return append([]byte(nil), n.b...) }
+
 // Equal reports whether n and x are the same nonce.
-func (n *Nonce) Equal(x *Nonce) bool { return subtle.ConstantTimeCompare(n.b, x.b) == 1 }
+func (n *Nonce) Equal(x *Nonce) bool {
+	if n == nil || x == nil {
+		return n == x
+	}
+
+	return subtle.ConstantTimeCompare(n.b, x.b) == 1
+}
 
 // Code implements Option.
 func (*Nonce) Code() byte { return optNonce }
 
+// MarshalBinary implements Option.
+func (n *Nonce) MarshalBinary() ([]byte, error) { return n.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (n *Nonce) AppendBinary(b []byte) ([]byte, error) { return n.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (n *Nonce) UnmarshalBinary(b []byte) error { return n.unmarshal(b) }
+
 // String returns the string representation of a Nonce.
 func (n *Nonce) String() string { return hex.EncodeToString(n.b) }
 
-func (n *Nonce) marshal() ([]byte, error) {
+func (n *Nonce) appendBinary(b []byte) ([]byte, error) {
 	if len(n.b) == 0 {
 		return nil, errors.New("ndp: nonce option requires a non-empty nonce value")
 	}
 
 	// Enforce the nonce size matches the next unit of 8 bytes including 2 bytes
 	// for code and length.
-	l := len(n.b)
-	if r := (l + 2) % 8; r != 0 {
+	nl := len(n.b) + 2
+	if padLen(nl) != 0 {
 		return nil, errors.New("ndp: nonce size is invalid")
 	}
 
-	value := make([]byte, l)
-	copy(value, n.b)
-
-	raw := &RawOption{
-		Type:   n.Code(),
-		Length: (uint8(l) + 2) / 8,
-		Value:  value,
+	l, err := optionLength(nl)
+	if err != nil {
+		return nil, err
 	}
 
-	return raw.marshal()
+	b = append(b, n.Code(), l)
+	return append(b, n.b...), nil
 }
 
 func (n *Nonce) unmarshal(b []byte) error {
-	raw := new(RawOption)
-	if err := raw.unmarshal(b); err != nil {
+	raw, err := parseRawOption(b)
+	if err != nil {
 		return err
 	}
 
-	// raw already made a copy.
-	n.b = raw.Value
+	n.b = append(n.b[:0], raw.Value...)
 	return nil
 }
 
-var _ Option = &RawOption{}
+var _ Option = &AddressList{}
 
-// A RawOption is an Option in its raw and unprocessed format.  Options which
-// are not recognized by this package can be represented using a RawOption.
-type RawOption struct {
-	Type   uint8
-	Length uint8
-	Value  []byte
+// An AddressList is a Source or Target Address List option, as described in
+// RFC 3122, Section 3.1. Source and Target Address List options are carried
+// by Inverse Neighbor Discovery messages.
+type AddressList struct {
+	Direction Direction
+	Addresses []netip.Addr
 }
 
 // Code implements Option.
-func (r *RawOption) Code() byte { return r.Type }
-
-func (r *RawOption) marshal() ([]byte, error) {
-	// Length specified in units of 8 bytes, and the caller must provide
-	// an accurate length.
-	l := int(r.Length * 8)
-	if 1+1+len(r.Value) != l {
-		return nil, io.ErrUnexpectedEOF
+func (al *AddressList) Code() byte {
+	switch al.Direction {
+	case Source:
+		return optSourceAddressList
+	case Target:
+		return optTargetAddressList
+	default:
+		// Invalid, but report something rather than panicking.
+		return 0
 	}
+}
 
-	b := make([]byte, r.Length*8)
-	b[0] = r.Type
-	b[1] = r.Length
+// MarshalBinary implements Option.
+func (al *AddressList) MarshalBinary() ([]byte, error) { return al.appendBinary(nil) }
 
-	copy(b[2:], r.Value)
+// AppendBinary implements OptionAppender.
+func (al *AddressList) AppendBinary(b []byte) ([]byte, error) { return al.appendBinary(b) }
 
-	return b, nil
-}
+// UnmarshalBinary implements Option.
+func (al *AddressList) UnmarshalBinary(b []byte) error { return al.unmarshal(b) }
 
-func (r *RawOption) unmarshal(b []byte) error {
-	if len(b) < 2 {
-		return io.ErrUnexpectedEOF
+// Equal reports whether al and x are the same AddressList.
+func (al *AddressList) Equal(x *AddressList) bool {
+	if al == nil || x == nil {
+		return al == x
 	}
 
-	r.Type = b[0]
-	r.Length = b[1]
-	// Exclude type and length fields from value's length.
-	l := int(r.Length*8) - 2
-
-	// Enforce a valid length value that matches the expected one.
-	if lb := len(b[2:]); l != lb {
-		return fmt.Errorf("ndp: option value byte length should be %d, but length is %d", l, lb)
+	if al.Direction != x.Direction || len(al.Addresses) != len(x.Addresses) {
+		return false
 	}
 
-	r.Value = make([]byte, l)
-	copy(r.Value, b[2:])
+	for i := range al.Addresses {
+		if al.Addresses[i] != x.Addresses[i] {
+			return false
+		}
+	}
 
-	return nil
+	return true
 }
 
-// marshalOptions marshals a slice of Options into a single byte slice.
-func marshalOptions(options []Option) ([]byte, error) {
-	var b []byte
-	for _, o := range options {
-		ob, err := o.marshal()
-		if err != nil {
-			return nil, err
-		}
+// Offset for the addresses of an address list option, following 6 reserved
+// bytes.
+const alAddressesOff = 6
 
-		b = append(b, ob...)
+var errAddressListNoAddresses = errors.New("ndp: address list option requires at least one address")
+
+// String returns the string representation of an AddressList.
+func (al *AddressList) String() string {
+	dir := "source"
+	if al.Direction == Target {
+		dir = "target"
 	}
 
-	return b, nil
+	return fmt.Sprintf("%s address list: %s", dir, joinAddrs(al.Addresses))
 }
 
-// parseOptions parses a slice of Options from a byte slice.
-func parseOptions(b []byte) ([]Option, error) {
-	var options []Option
-	for i := 0; len(b[i:]) != 0; {
-		// Two bytes: option type and option length.
-		if len(b[i:]) < 2 {
-			return nil, io.ErrUnexpectedEOF
-		}
+func (al *AddressList) appendBinary(b []byte) ([]byte, error) {
+	if d := al.Direction; d != Source && d != Target {
+		return nil, fmt.Errorf("ndp: invalid address list direction: %d", d)
+	}
 
-		// Type processed as-is, but length is stored in units of 8 bytes,
-		// so expand it to the actual byte length.
-		t := b[i]
-		l := int(b[i+1]) * 8
+	n := len(al.Addresses)
+	if n == 0 {
+		return nil, errAddressListNoAddresses
+	}
 
-		// Verify that we won't advance beyond the end of the byte slice.
-		if l > len(b[i:]) {
-			return nil, io.ErrUnexpectedEOF
-		}
+	// One length unit for the header and reserved bytes, and then each IPv6
+	// address occupies two length units.
+	l, err := optionLength(8 + (n * 2 * 8))
+	if err != nil {
+		return nil, err
+	}
 
-		// Infer the option from its type value and use it for unmarshaling.
-		var o Option
-		switch t {
-		case optSourceLLA, optTargetLLA:
-			o = new(LinkLayerAddress)
-		case optMTU:
-			o = new(MTU)
-		case optPrefixInformation:
-			o = new(PrefixInformation)
-		case optRouteInformation:
-			o = new(RouteInformation)
-		case optRDNSS:
-			o = new(RecursiveDNSServer)
-		case optRAFlagsExtension:
-			o = new(RAFlagsExtension)
-		case optDNSSL:
-			o = new(DNSSearchList)
-		case optCaptivePortal:
-			o = new(CaptivePortal)
-		case optPREF64:
-			o = new(PREF64)
-		case optNonce:
-			o = new(Nonce)
-		default:
-			o = new(RawOption)
-		}
-
-		// Unmarshal at the current offset, up to the expected length.
-		if err := o.unmarshal(b[i : i+l]); err != nil {
+	b = append(b, al.Code(), l, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	for _, a := range al.Addresses {
+		if err := checkIPv6(a); err != nil {
 			return nil, err
 		}
 
-		// Advance to the next option's type field.
-		i += l
+		ip := a.As16()
+		b = append(b, ip[:]...)
+	}
 
-		options = append(options, o)
+	return b, nil
+}
+
+func (al *AddressList) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
 	}
 
-	return options, nil
+	var d Direction
+	switch raw.Type {
+	case optSourceAddressList:
+		d = Source
+	case optTargetAddressList:
+		d = Target
+	default:
+		return fmt.Errorf("ndp: invalid address list option type: %d", raw.Type)
+	}
+
+	// "The number of addresses is equal to (Length - 1) / 2."
+	dividend := int(raw.Length) - 1
+	if dividend%2 != 0 {
+		return fmt.Errorf("ndp: unexpected address list option length: %d", raw.Length)
+	}
+
+	count := dividend / 2
+	if count == 0 {
+		return errAddressListNoAddresses
+	}
+
+	addrs := al.Addresses[:0]
+	if cap(addrs) < count {
+		addrs = make([]netip.Addr, 0, count)
+	}
+	for i := 0; i < count; i++ {
+		start := alAddressesOff + (i * net.IPv6len)
+		addrs = append(addrs, netip.AddrFrom16(
+			([net.IPv6len]byte)(raw.Value[start:start+net.IPv6len])))
+	}
+
+	*al = AddressList{
+		Direction: d,
+		Addresses: addrs,
+	}
+
+	return nil
+}
+
+// An ARStatus is the status of an AddressRegistration, as described in RFC
+// 6775, Section 4.1 and RFC 8505, Section 4.1.
+type ARStatus uint8
+
+// Possible ARStatus values.
+const (
+	ARSuccess                ARStatus = 0
+	ARDuplicateAddress       ARStatus = 1
+	ARNeighborCacheFull      ARStatus = 2
+	ARMoved                  ARStatus = 3
+	ARRemoved                ARStatus = 4
+	ARValidationRequested    ARStatus = 5
+	ARDuplicateSourceAddress ARStatus = 6
+	ARInvalidSourceAddress   ARStatus = 7
+	ARTopologicallyIncorrect ARStatus = 8
+	ARRegistrySaturated      ARStatus = 9
+	ARValidationFailed       ARStatus = 10
+)
+
+var _ Option = &AddressRegistration{}
+
+// An AddressRegistration is an Address Registration option (ARO) as described
+// in RFC 6775, Section 4.1, or an Extended Address Registration option (EARO)
+// as described in RFC 8505, Section 4.1. Both share the same option code.
+//
+// An ARO carries the EUI-64 of the registering node in ROVR, and sets none of
+// the EARO fields.
+type AddressRegistration struct {
+	Status ARStatus
+
+	// EARO fields: Opaque is passed to the routing protocol, and OpaqueType
+	// is the 2-bit "I" field which indicates its contents. The "R" flag is
+	// RequestReachability, and TID is the transaction ID, present when the
+	// "T" flag HasTID is set.
+	Opaque              uint8
+	OpaqueType          uint8
+	RequestReachability bool
+	HasTID              bool
+	TID                 uint8
+
+	// Lifetime is the registration lifetime, in units of 60 seconds.
+	Lifetime time.Duration
+
+	// ROVR is the EUI-64 of the registering node for an ARO, or the
+	// Registration Ownership Verifier of 8, 16, 24, or 32 bytes for an EARO.
+	ROVR []byte
+}
+
+// Code implements Option.
+func (*AddressRegistration) Code() byte { return optAddressRegistration }
+
+// MarshalBinary implements Option.
+func (ar *AddressRegistration) MarshalBinary() ([]byte, error) { return ar.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (ar *AddressRegistration) AppendBinary(b []byte) ([]byte, error) { return ar.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (ar *AddressRegistration) UnmarshalBinary(b []byte) error { return ar.unmarshal(b) }
+
+// Equal reports whether ar and x are the same AddressRegistration.
+func (ar *AddressRegistration) Equal(x *AddressRegistration) bool {
+	if ar == nil || x == nil {
+		return ar == x
+	}
+
+	return ar.Status == x.Status &&
+		ar.Opaque == x.Opaque &&
+		ar.OpaqueType == x.OpaqueType &&
+		ar.RequestReachability == x.RequestReachability &&
+		ar.HasTID == x.HasTID &&
+		ar.TID == x.TID &&
+		ar.Lifetime == x.Lifetime &&
+		bytes.Equal(ar.ROVR, x.ROVR)
+}
+
+// String returns the string representation of an AddressRegistration.
+func (ar *AddressRegistration) String() string {
+	return fmt.Sprintf("address registration: status: %d, lifetime: %s, ROVR: %x", ar.Status, ar.Lifetime, ar.ROVR)
+}
+
+func (ar *AddressRegistration) appendBinary(b []byte) ([]byte, error) {
+	switch len(ar.ROVR) {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("ndp: invalid address registration ROVR length: %d", len(ar.ROVR))
+	}
+
+	if ar.OpaqueType > 0b11 {
+		return nil, fmt.Errorf("ndp: invalid address registration opaque type: %d", ar.OpaqueType)
+	}
+
+	lifetime := ar.Lifetime / time.Minute
+	if lifetime < 0 || lifetime > math.MaxUint16 {
+		return nil, fmt.Errorf("ndp: address registration lifetime out of range: %s", ar.Lifetime)
+	}
+
+	var flags uint8
+	flags |= ar.OpaqueType << 2
+	if ar.RequestReachability {
+		flags |= 1 << 1
+	}
+	if ar.HasTID {
+		flags |= 1 << 0
+	}
+
+	b = append(b, ar.Code(), uint8(1+len(ar.ROVR)/8), uint8(ar.Status), ar.Opaque, flags, ar.TID)
+	b = binary.BigEndian.AppendUint16(b, uint16(lifetime))
+	return append(b, ar.ROVR...), nil
+}
+
+func (ar *AddressRegistration) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	if raw.Length < 2 || raw.Length > 5 {
+		return fmt.Errorf("ndp: unexpected address registration option length: %d", raw.Length)
+	}
+
+	flags := raw.Value[2]
+
+	*ar = AddressRegistration{
+		Status:              ARStatus(raw.Value[0]),
+		Opaque:              raw.Value[1],
+		OpaqueType:          (flags >> 2) & 0b11,
+		RequestReachability: flags&(1<<1) != 0,
+		HasTID:              flags&(1<<0) != 0,
+		TID:                 raw.Value[3],
+		Lifetime:            time.Duration(binary.BigEndian.Uint16(raw.Value[4:6])) * time.Minute,
+		ROVR:                append(ar.ROVR[:0], raw.Value[6:]...),
+	}
+
+	return nil
+}
+
+var _ Option = &PvD{}
+
+// A PvD is a Provisioning Domain option, as described in RFC 8801, Section 3.1.
+// A PvD option encapsulates the options of a Router Advertisement which
+// belong to the provisioning domain identified by FQDN.
+type PvD struct {
+	// HTTP indicates that additional information is available via HTTPS, and
+	// Legacy indicates that the PvD is also announced without a PvD option.
+	HTTP   bool
+	Legacy bool
+
+	// Delay is the 4-bit exponent of the delay to apply before fetching
+	// additional information, and Sequence is the PvD ID sequence number.
+	Delay    uint8
+	Sequence uint16
+
+	// FQDN is the fully qualified domain name which identifies the PvD.
+	FQDN string
+
+	// RouterAdvertisement, if not nil, is a Router Advertisement message
+	// header which is specific to the PvD. Its Options must be empty; the
+	// options of the PvD are stored in Options, which must not contain
+	// another PvD.
+	RouterAdvertisement *RouterAdvertisement
+	Options             []Option
+}
+
+// Code implements Option.
+func (*PvD) Code() byte { return optPvD }
+
+// MarshalBinary implements Option.
+func (p *PvD) MarshalBinary() ([]byte, error) { return p.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (p *PvD) AppendBinary(b []byte) ([]byte, error) { return p.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (p *PvD) UnmarshalBinary(b []byte) error { return p.unmarshal(nil, &optionUsage{}, b) }
+
+// Equal reports whether p and x are the same PvD.
+func (p *PvD) Equal(x *PvD) bool {
+	if p == nil || x == nil {
+		return p == x
+	}
+
+	if (p.RouterAdvertisement == nil) != (x.RouterAdvertisement == nil) {
+		return false
+	}
+	if p.RouterAdvertisement != nil && !p.RouterAdvertisement.Equal(x.RouterAdvertisement) {
+		return false
+	}
+
+	return p.HTTP == x.HTTP &&
+		p.Legacy == x.Legacy &&
+		p.Delay == x.Delay &&
+		p.Sequence == x.Sequence &&
+		p.FQDN == x.FQDN &&
+		optionsEqual(p.Options, x.Options)
+}
+
+// Offsets for the PvD option.
+const (
+	pvdSequenceOff = 2
+	pvdFQDNOff     = 4
+)
+
+var (
+	errPvDBadFQDN = errors.New("ndp: PvD option has malformed FQDN")
+	errPvDNested  = errors.New("ndp: PvD options must not be nested")
+)
+
+// String returns the string representation of a PvD.
+func (p *PvD) String() string {
+	opts := make([]string, 0, len(p.Options))
+	for _, o := range p.Options {
+		opts = append(opts, optionString(o))
+	}
+
+	return fmt.Sprintf("PvD: %s, sequence: %d, HTTP: %t, legacy: %t, RA header: %t, options: [%s]",
+		p.FQDN, p.Sequence, p.HTTP, p.Legacy, p.RouterAdvertisement != nil, strings.Join(opts, "; "))
+}
+
+func (p *PvD) appendBinary(b []byte) ([]byte, error) {
+	if p.Delay > 0x0f {
+		return nil, fmt.Errorf("ndp: PvD delay must be a 4-bit value: %d", p.Delay)
+	}
+	if p.FQDN == "" {
+		return nil, errPvDBadFQDN
+	}
+
+	var flags uint8
+	if p.HTTP {
+		flags |= 1 << 7
+	}
+	if p.Legacy {
+		flags |= 1 << 6
+	}
+	if p.RouterAdvertisement != nil {
+		flags |= 1 << 5
+	}
+
+	// The length is computed once the FQDN and all encapsulated options have
+	// been appended.
+	start := len(b)
+	b = append(b, p.Code(), 0x00, flags, p.Delay)
+	b = binary.BigEndian.AppendUint16(b, p.Sequence)
+
+	b, ok := appendDomain(b, p.FQDN, idna.Punycode)
+	if !ok {
+		return nil, errPvDBadFQDN
+	}
+	b = appendPadding(b, len(b)-start)
+
+	if ra := p.RouterAdvertisement; ra != nil {
+		if len(ra.Options) != 0 {
+			return nil, errors.New("ndp: PvD router advertisement must not have options")
+		}
+
+		// Per RFC 8801, Section 3.1, the type of the header is that of a
+		// router advertisement, and the code and checksum are zero.
+		var err error
+		b = append(b, byte(ra.Type()), 0x00, 0x00, 0x00)
+		b, err = ra.appendBinary(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range p.Options {
+		if _, ok := o.(*PvD); ok {
+			return nil, errPvDNested
+		}
+	}
+
+	b, err := AppendOptions(b, p.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := optionLength(len(b) - start)
+	if err != nil {
+		return nil, err
+	}
+
+	b[start+1] = l
+	return b, nil
+}
+
+// unmarshal parses the PvD option b. Its encapsulated options are parsed
+// according to the policies of pr, and count towards the limits of pr along
+// with the other options of the enclosing Message as tracked by u.
+func (p *PvD) unmarshal(pr *Parser, u *optionUsage, b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	if len(raw.Value) < pvdFQDNOff {
+		return io.ErrUnexpectedEOF
+	}
+
+	fqdn, n, ok := parseDomain(raw.Value[pvdFQDNOff:], idna.Punycode)
+	if !ok {
+		return errPvDBadFQDN
+	}
+
+	// Skip the padding after the FQDN to reach the router advertisement
+	// header and encapsulated options, accounting for the type and length.
+	i := pvdFQDNOff + n
+	i += padLen(i + 2)
+	if i > len(raw.Value) {
+		return io.ErrUnexpectedEOF
+	}
+
+	var ra *RouterAdvertisement
+	if raw.Value[0]&0x20 != 0 {
+		if len(raw.Value[i:]) < icmpLen+raLen {
+			return io.ErrUnexpectedEOF
+		}
+
+		// Reuse any existing storage for the header, which never carries
+		// options.
+		ra = p.RouterAdvertisement
+		if ra == nil {
+			ra = new(RouterAdvertisement)
+		}
+
+		i += icmpLen
+		if err := ra.unmarshal(nil, raw.Value[i:i+raLen]); err != nil {
+			return err
+		}
+		i += raLen
+	}
+
+	// Mark the encapsulated options so that nested PvD options are rejected.
+	u.inPvD = true
+	options, err := parseOptionsUsage(pr, u, p.Options[:0], raw.Value[i:])
+	u.inPvD = false
+	if err != nil {
+		return err
+	}
+
+	*p = PvD{
+		HTTP:                raw.Value[0]&0x80 != 0,
+		Legacy:              raw.Value[0]&0x40 != 0,
+		Delay:               raw.Value[1] & 0x0f,
+		Sequence:            binary.BigEndian.Uint16(raw.Value[pvdSequenceOff:pvdFQDNOff]),
+		FQDN:                fqdn,
+		RouterAdvertisement: ra,
+		Options:             options,
+	}
+
+	return nil
+}
+
+var _ Option = &EncryptedDNS{}
+
+// An EncryptedDNS is an Encrypted DNS (DNR) option, as described in RFC 9463,
+// Section 6.1. It advertises a resolver which supports encrypted DNS
+// protocols such as DNS over TLS or DNS over HTTPS.
+type EncryptedDNS struct {
+	// ServicePriority orders resolvers, with lower values being preferred.
+	ServicePriority uint16
+	Lifetime        time.Duration
+
+	// ADN is the authentication domain name of the resolver.
+	ADN string
+
+	// Addresses are the IPv6 addresses of the resolver, and SvcParams are
+	// the service parameters which describe how to reach it.
+	Addresses []netip.Addr
+	SvcParams []SvcParam
+}
+
+// A SvcParam is a service parameter key and value, as described in RFC 9460,
+// Section 2.2.
+type SvcParam struct {
+	Key   uint16
+	Value []byte
+}
+
+// Code implements Option.
+func (*EncryptedDNS) Code() byte { return optEncryptedDNS }
+
+// MarshalBinary implements Option.
+func (e *EncryptedDNS) MarshalBinary() ([]byte, error) { return e.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (e *EncryptedDNS) AppendBinary(b []byte) ([]byte, error) { return e.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (e *EncryptedDNS) UnmarshalBinary(b []byte) error { return e.unmarshal(b) }
+
+// Equal reports whether e and x are the same EncryptedDNS.
+func (e *EncryptedDNS) Equal(x *EncryptedDNS) bool {
+	if e == nil || x == nil {
+		return e == x
+	}
+
+	if e.ServicePriority != x.ServicePriority ||
+		e.Lifetime != x.Lifetime ||
+		e.ADN != x.ADN ||
+		len(e.Addresses) != len(x.Addresses) ||
+		len(e.SvcParams) != len(x.SvcParams) {
+		return false
+	}
+
+	for i := range e.Addresses {
+		if e.Addresses[i] != x.Addresses[i] {
+			return false
+		}
+	}
+
+	for i := range e.SvcParams {
+		if e.SvcParams[i].Key != x.SvcParams[i].Key || !bytes.Equal(e.SvcParams[i].Value, x.SvcParams[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Offsets for the Encrypted DNS option.
+const (
+	dnrLifetimeOff = 2
+	dnrADNOff      = 6
+)
+
+var (
+	errDNRBadADN       = errors.New("ndp: encrypted DNS option has malformed authentication domain name")
+	errDNRNoAddresses  = errors.New("ndp: encrypted DNS option requires at least one IPv6 address")
+	errDNRBadSvcParams = errors.New("ndp: encrypted DNS option has malformed service parameters")
+)
+
+// String returns the string representation of an EncryptedDNS.
+func (e *EncryptedDNS) String() string {
+	return fmt.Sprintf("encrypted DNS: %s, priority: %d, lifetime: %s, addresses: %s, service parameters: %d",
+		e.ADN, e.ServicePriority, e.Lifetime, joinAddrs(e.Addresses), len(e.SvcParams))
+}
+
+func (e *EncryptedDNS) appendBinary(b []byte) ([]byte, error) {
+	if e.ADN == "" {
+		return nil, errDNRBadADN
+	}
+	if len(e.Addresses) == 0 {
+		return nil, errDNRNoAddresses
+	}
+
+	// The length is computed once all variable length fields have been
+	// appended.
+	start := len(b)
+	b = append(b, e.Code(), 0x00)
+	b = binary.BigEndian.AppendUint16(b, e.ServicePriority)
+	b = binary.BigEndian.AppendUint32(b, uint32(e.Lifetime.Seconds()))
+
+	// Each variable length field is preceded by its 16-bit length, which is
+	// filled in after the field is appended. Overly long fields are caught by
+	// the check on the length of the entire option.
+	adn := len(b)
+	b, ok := appendDomain(append(b, 0x00, 0x00), e.ADN, idna.Punycode)
+	if !ok {
+		return nil, errDNRBadADN
+	}
+	binary.BigEndian.PutUint16(b[adn:], uint16(len(b)-adn-2))
+
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.Addresses)*net.IPv6len))
+	for _, a := range e.Addresses {
+		if !a.Is6() {
+			return nil, fmt.Errorf("ndp: invalid encrypted DNS IPv6 address: %s", a)
+		}
+
+		ip := a.As16()
+		b = append(b, ip[:]...)
+	}
+
+	svc := len(b)
+	b = append(b, 0x00, 0x00)
+	for _, p := range e.SvcParams {
+		b = binary.BigEndian.AppendUint16(b, p.Key)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p.Value)))
+		b = append(b, p.Value...)
+	}
+	binary.BigEndian.PutUint16(b[svc:], uint16(len(b)-svc-2))
+
+	b = appendPadding(b, len(b)-start)
+
+	l, err := optionLength(len(b) - start)
+	if err != nil {
+		return nil, err
+	}
+
+	b[start+1] = l
+	return b, nil
+}
+
+func (e *EncryptedDNS) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	// Each variable length field is preceded by its 16-bit length.
+	field := func(i int) ([]byte, int, error) {
+		if len(raw.Value[i:]) < 2 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+
+		n := int(binary.BigEndian.Uint16(raw.Value[i : i+2]))
+		i += 2
+		if len(raw.Value[i:]) < n {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+
+		return raw.Value[i : i+n], i + n, nil
+	}
+
+	if len(raw.Value) < dnrADNOff {
+		return io.ErrUnexpectedEOF
+	}
+
+	adnb, i, err := field(dnrADNOff)
+	if err != nil {
+		return err
+	}
+
+	adn, n, ok := parseDomain(adnb, idna.Punycode)
+	if !ok || n != len(adnb) {
+		return errDNRBadADN
+	}
+
+	addrb, i, err := field(i)
+	if err != nil {
+		return err
+	}
+	if len(addrb) == 0 {
+		return errDNRNoAddresses
+	}
+	if len(addrb)%net.IPv6len != 0 {
+		return fmt.Errorf("ndp: encrypted DNS option address length must be a multiple of %d: %d",
+			net.IPv6len, len(addrb))
+	}
+
+	addrs := e.Addresses[:0]
+	for j := 0; j < len(addrb); j += net.IPv6len {
+		addrs = append(addrs, netip.AddrFrom16([16]byte(addrb[j:j+net.IPv6len])))
+	}
+
+	svcb, _, err := field(i)
+	if err != nil {
+		return err
+	}
+
+	// Reuse any existing storage for the service parameters.
+	params := e.SvcParams[:0]
+	for j := 0; j < len(svcb); {
+		if len(svcb[j:]) < 4 {
+			return errDNRBadSvcParams
+		}
+
+		var (
+			key = binary.BigEndian.Uint16(svcb[j : j+2])
+			n   = int(binary.BigEndian.Uint16(svcb[j+2 : j+4]))
+		)
+		j += 4
+
+		if len(svcb[j:]) < n {
+			return errDNRBadSvcParams
+		}
+
+		var v []byte
+		if k := len(params); k < cap(params) {
+			v = params[:k+1][k].Value[:0]
+		}
+		params = append(params, SvcParam{
+			Key:   key,
+			Value: append(v, svcb[j:j+n]...),
+		})
+		j += n
+	}
+
+	*e = EncryptedDNS{
+		ServicePriority: binary.BigEndian.Uint16(raw.Value[0:dnrLifetimeOff]),
+		Lifetime: time.Duration(binary.BigEndian.Uint32(
+			raw.Value[dnrLifetimeOff:dnrADNOff])) * time.Second,
+		ADN:       adn,
+		Addresses: addrs,
+		SvcParams: params,
+	}
+
+	return nil
+}
+
+var _ Option = &RawOption{}
+
+// A RawOption is an Option in its raw and unprocessed format.  Options which
+// are not recognized by this package can be represented using a RawOption.
+type RawOption struct {
+	Type   uint8
+	Length uint8
+	Value  []byte
+}
+
+// Code implements Option.
+func (r *RawOption) Code() byte { return r.Type }
+
+// MarshalBinary implements Option.
+func (r *RawOption) MarshalBinary() ([]byte, error) { return r.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (r *RawOption) AppendBinary(b []byte) ([]byte, error) { return r.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (r *RawOption) UnmarshalBinary(b []byte) error { return r.unmarshal(b) }
+
+// Equal reports whether r and x are the same RawOption.
+func (r *RawOption) Equal(x *RawOption) bool {
+	if r == nil || x == nil {
+		return r == x
+	}
+
+	return r.Type == x.Type && r.Length == x.Length && bytes.Equal(r.Value, x.Value)
+}
+
+// String returns the string representation of a RawOption.
+func (r *RawOption) String() string {
+	return fmt.Sprintf("type: %03d, value: %v", r.Type, r.Value)
+}
+
+func (r *RawOption) appendBinary(b []byte) ([]byte, error) {
+	// Length specified in units of 8 bytes, and the caller must provide
+	// an accurate length.
+	l := int(r.Length) * 8
+	if 1+1+len(r.Value) != l {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b = append(b, r.Type, r.Length)
+	return append(b, r.Value...), nil
+}
+
+func (r *RawOption) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	// Reuse any existing storage for the value.
+	*r = RawOption{
+		Type:   raw.Type,
+		Length: raw.Length,
+		Value:  append(r.Value[:0], raw.Value...),
+	}
+
+	return nil
+}
+
+// parseRawOption parses a RawOption from b, but unlike RawOption.unmarshal,
+// the RawOption's Value refers to b rather than a copy of it. Options which
+// retain the value beyond unmarshaling must copy it.
+func parseRawOption(b []byte) (RawOption, error) {
+	if len(b) < 2 {
+		return RawOption{}, io.ErrUnexpectedEOF
+	}
+
+	var (
+		t = b[0]
+		n = b[1]
+		// Exclude type and length fields from value's length.
+		l = int(n)*8 - 2
+	)
+
+	// Enforce a valid length value that matches the expected one.
+	if lb := len(b[2:]); l != lb {
+		return RawOption{}, fmt.Errorf("ndp: option value byte length should be %d, but length is %d", l, lb)
+	}
+
+	return RawOption{
+		Type:   t,
+		Length: n,
+		Value:  b[2:],
+	}, nil
+}
+
+// Options returns the Options of type T carried by m, in the order in which
+// they appear. It returns nil if m carries no Options of type T.
+func Options[T Option](m Message) []T {
+	var ts []T
+	for _, o := range messageOptions(m) {
+		if t, ok := o.(T); ok {
+			ts = append(ts, t)
+		}
+	}
+
+	return ts
+}
+
+// FirstOption returns the first Option of type T carried by m, and reports
+// whether such an Option was found.
+func FirstOption[T Option](m Message) (T, bool) {
+	for _, o := range messageOptions(m) {
+		if t, ok := o.(T); ok {
+			return t, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// messageOptions returns the Options carried by m, or nil if m is not a type
+// of Message which carries Options.
+func messageOptions(m Message) []Option {
+	switch m := m.(type) {
+	case *NeighborAdvertisement:
+		return m.Options
+	case *NeighborSolicitation:
+		return m.Options
+	case *RouterAdvertisement:
+		return m.Options
+	case *RouterSolicitation:
+		return m.Options
+	case *InverseNeighborSolicitation:
+		return m.Options
+	case *InverseNeighborAdvertisement:
+		return m.Options
+	default:
+		return nil
+	}
+}
+
+// NormalizeOptions returns a normalized copy of options, which is useful when
+// options from several sources are combined into a single Message:
+//   - only the first source and target LinkLayerAddress and the first MTU
+//     option are kept
+//   - RecursiveDNSServer options with the same Lifetime are merged, as are
+//     DNSSearchList options with the same Lifetime, and duplicate servers and
+//     domain names are removed
+//   - options which are equal to an earlier option are removed
+//   - options are ordered by their code, retaining the relative order of
+//     options with the same code
+//
+// DNSSearchList options with RawLabels set are never merged. The input
+// options are not modified.
+func NormalizeOptions(options []Option) []Option {
+	if len(options) == 0 {
+		return nil
+	}
+
+	var (
+		out   = make([]Option, 0, len(options))
+		rdnss = make(map[time.Duration]*RecursiveDNSServer)
+		dnssl = make(map[time.Duration]*DNSSearchList)
+	)
+
+	for _, o := range options {
+		switch o := o.(type) {
+		case *LinkLayerAddress, *MTU:
+			if containsCode(out, o.Code()) {
+				continue
+			}
+		case *RecursiveDNSServer:
+			r, ok := rdnss[o.Lifetime]
+			if !ok {
+				r = &RecursiveDNSServer{Lifetime: o.Lifetime}
+				rdnss[o.Lifetime] = r
+				out = append(out, r)
+			}
+
+			r.Servers = appendUnique(r.Servers, o.Servers...)
+			continue
+		case *DNSSearchList:
+			if o.RawLabels {
+				break
+			}
+
+			d, ok := dnssl[o.Lifetime]
+			if !ok {
+				d = cloneOption(o).(*DNSSearchList)
+				d.DomainNames = nil
+				dnssl[o.Lifetime] = d
+				out = append(out, d)
+			}
+
+			d.DomainNames = appendUnique(d.DomainNames, o.DomainNames...)
+			continue
+		}
+
+		if containsOption(out, o) {
+			continue
+		}
+
+		out = append(out, cloneOption(o))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Code() < out[j].Code()
+	})
+
+	return out
+}
+
+// Withdraw returns a copy of o with its lifetimes set to zero, which withdraws
+// the information previously advertised by o, and reports whether o can be
+// withdrawn. PrefixInformation, RouteInformation, RecursiveDNSServer,
+// DNSSearchList, PREF64, and EncryptedDNS options can be withdrawn.
+//
+// Per RFC 4862, Section 5.5.3, hosts do not reduce the valid lifetime of an
+// autonomously configured address below 2 hours in response to an
+// unauthenticated PrefixInformation option, so a withdrawn prefix is
+// deprecated immediately but may remain valid for some time.
+func Withdraw(o Option) (Option, bool) {
+	switch o := o.(type) {
+	case *PrefixInformation:
+		c := *o
+		c.ValidLifetime, c.PreferredLifetime = 0, 0
+		return &c, true
+	case *RouteInformation:
+		c := *o
+		c.RouteLifetime = 0
+		return &c, true
+	case *RecursiveDNSServer:
+		c := cloneOption(o).(*RecursiveDNSServer)
+		c.Lifetime = 0
+		return c, true
+	case *DNSSearchList:
+		c := cloneOption(o).(*DNSSearchList)
+		c.Lifetime = 0
+		return c, true
+	case *PREF64:
+		c := *o
+		c.Lifetime = 0
+		return &c, true
+	case *EncryptedDNS:
+		c := cloneOption(o).(*EncryptedDNS)
+		c.Lifetime = 0
+		return c, true
+	default:
+		return nil, false
+	}
+}
+
+// optionString returns the string representation of o, using its String
+// method if one is implemented.
+func optionString(o Option) string {
+	if s, ok := o.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("type: %03d, option: %T", o.Code(), o)
+}
+
+// joinAddrs returns a comma-separated list of addrs.
+func joinAddrs(addrs []netip.Addr) string {
+	ss := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ss = append(ss, a.String())
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// containsCode reports whether options contains an Option with code.
+func containsCode(options []Option, code uint8) bool {
+	for _, o := range options {
+		if o.Code() == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsOption reports whether options contains an Option equal to o.
+func containsOption(options []Option, o Option) bool {
+	for _, x := range options {
+		if optionEqual(x, o) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendUnique appends each element of vs to s which is not already present.
+func appendUnique[T comparable](s []T, vs ...T) []T {
+outer:
+	for _, v := range vs {
+		for _, x := range s {
+			if x == v {
+				continue outer
+			}
+		}
+
+		s = append(s, v)
+	}
+
+	return s
+}
+
+// marshalOptions marshals a slice of Options into a single byte slice.
+func marshalOptions(options []Option) ([]byte, error) {
+	return AppendOptions(nil, options)
+}
+
+// AppendOptions appends the binary form of each of options to dst and returns
+// the extended buffer.
+//
+// Options which implement OptionAppender, including all Options implemented
+// by this package, are appended without allocating if dst has sufficient
+// capacity. This enables building messages with many options, such as a
+// RouterAdvertisement with hundreds of PrefixInformation options, in a single
+// reused buffer.
+func AppendOptions(dst []byte, options []Option) ([]byte, error) {
+	for _, o := range options {
+		var err error
+		dst, err = appendOption(dst, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// appendOption appends the binary form of o to b. Options implemented by this
+// package append directly to b, while all others are marshaled or appended
+// and then verified.
+func appendOption(b []byte, o Option) ([]byte, error) {
+	if ba, ok := o.(binaryAppender); ok {
+		return ba.appendBinary(b)
+	}
+
+	start := len(b)
+	if oa, ok := o.(OptionAppender); ok {
+		var err error
+		b, err = oa.AppendBinary(b)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ob, err := o.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ob...)
+	}
+
+	if ob := b[start:]; len(ob) < 2 || ob[0] != o.Code() || int(ob[1])*8 != len(ob) {
+		return nil, fmt.Errorf("ndp: option with code %d has an invalid binary form", o.Code())
+	}
+
+	return b, nil
+}
+
+// An OptionType describes a type of Option which can be parsed by this
+// package.
+type OptionType struct {
+	// Code is the NDP option code of the Option, and Name is its
+	// human-readable name.
+	Code uint8
+	Name string
+
+	// New creates an empty Option with this code.
+	New func() Option
+}
+
+// OptionTypes returns the types of Options which can be parsed by this
+// package, including those added by RegisterOption, sorted by option code.
+// Options with other codes are parsed as RawOptions.
+func OptionTypes() []OptionType {
+	ots := builtinOptionTypes()
+
+	optionRegistry.mu.RLock()
+	for code, fn := range optionRegistry.m {
+		ots = append(ots, OptionType{
+			Code: code,
+			Name: fmt.Sprintf("Option %d", code),
+			New:  fn,
+		})
+	}
+	optionRegistry.mu.RUnlock()
+
+	sort.Slice(ots, func(i, j int) bool { return ots[i].Code < ots[j].Code })
+	return ots
+}
+
+// builtinOptionTypes returns the types of Options implemented by this package.
+func builtinOptionTypes() []OptionType {
+	return []OptionType{
+		{
+			Code: optSourceLLA,
+			Name: "Source Link-Layer Address",
+			New:  func() Option { return &LinkLayerAddress{Direction: Source} },
+		},
+		{
+			Code: optTargetLLA,
+			Name: "Target Link-Layer Address",
+			New:  func() Option { return &LinkLayerAddress{Direction: Target} },
+		},
+		{
+			Code: optPrefixInformation,
+			Name: "Prefix Information",
+			New:  func() Option { return new(PrefixInformation) },
+		},
+		{
+			Code: optMTU,
+			Name: "MTU",
+			New:  func() Option { return new(MTU) },
+		},
+		{
+			Code: optSourceAddressList,
+			Name: "Source Address List",
+			New:  func() Option { return &AddressList{Direction: Source} },
+		},
+		{
+			Code: optTargetAddressList,
+			Name: "Target Address List",
+			New:  func() Option { return &AddressList{Direction: Target} },
+		},
+		{
+			Code: optCGA,
+			Name: "CGA",
+			New:  func() Option { return new(CGA) },
+		},
+		{
+			Code: optRSASignature,
+			Name: "RSA Signature",
+			New:  func() Option { return new(RSASignature) },
+		},
+		{
+			Code: optTimestamp,
+			Name: "Timestamp",
+			New:  func() Option { return new(Timestamp) },
+		},
+		{
+			Code: optNonce,
+			Name: "Nonce",
+			New:  func() Option { return new(Nonce) },
+		},
+		{
+			Code: optPvD,
+			Name: "Provisioning Domain",
+			New:  func() Option { return new(PvD) },
+		},
+		{
+			Code: optRouteInformation,
+			Name: "Route Information",
+			New:  func() Option { return new(RouteInformation) },
+		},
+		{
+			Code: optRDNSS,
+			Name: "Recursive DNS Server",
+			New:  func() Option { return new(RecursiveDNSServer) },
+		},
+		{
+			Code: optRAFlagsExtension,
+			Name: "RA Flags Extension",
+			New:  func() Option { return new(RAFlagsExtension) },
+		},
+		{
+			Code: optDNSSL,
+			Name: "DNS Search List",
+			New:  func() Option { return new(DNSSearchList) },
+		},
+		{
+			Code: optAddressRegistration,
+			Name: "Address Registration",
+			New:  func() Option { return new(AddressRegistration) },
+		},
+		{
+			Code: optCaptivePortal,
+			Name: "Captive-Portal",
+			New:  func() Option { return new(CaptivePortal) },
+		},
+		{
+			Code: optPREF64,
+			Name: "PREF64",
+			New:  func() Option { return new(PREF64) },
+		},
+		{
+			Code: optEncryptedDNS,
+			Name: "Encrypted DNS",
+			New:  func() Option { return new(EncryptedDNS) },
+		},
+	}
+}
+
+// optionsEqual reports whether two slices of Options contain equal Options in
+// the same order.
+func optionsEqual(x, y []Option) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if !optionEqual(x[i], y[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// optionEqual reports whether two Options are equal.
+func optionEqual(x, y Option) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case *LinkLayerAddress:
+		return equalAs(x, y)
+	case *MTU:
+		return equalAs(x, y)
+	case *PrefixInformation:
+		return equalAs(x, y)
+	case *RouteInformation:
+		return equalAs(x, y)
+	case *RecursiveDNSServer:
+		return equalAs(x, y)
+	case *RAFlagsExtension:
+		return equalAs(x, y)
+	case *DNSSearchList:
+		return equalAs(x, y)
+	case *CaptivePortal:
+		return equalAs(x, y)
+	case *PREF64:
+		return equalAs(x, y)
+	case *Nonce:
+		return equalAs(x, y)
+	case *Timestamp:
+		return equalAs(x, y)
+	case *RSASignature:
+		return equalAs(x, y)
+	case *CGA:
+		return equalAs(x, y)
+	case *AddressList:
+		return equalAs(x, y)
+	case *AddressRegistration:
+		return equalAs(x, y)
+	case *PvD:
+		return equalAs(x, y)
+	case *EncryptedDNS:
+		return equalAs(x, y)
+	case *RawOption:
+		return equalAs(x, y)
+	default:
+		// Unknown Option type, fall back to comparing the binary forms.
+		xb, xerr := appendOption(nil, x)
+		yb, yerr := appendOption(nil, y)
+		return xerr == nil && yerr == nil && bytes.Equal(xb, yb)
+	}
+}
+
+// equalAs reports whether y is of type T and is equal to x.
+func equalAs[T interface{ Equal(T) bool }](x T, y Option) bool {
+	yt, ok := y.(T)
+	return ok && x.Equal(yt)
+}
+
+// cloneOptions returns a deep copy of options.
+func cloneOptions(options []Option) []Option {
+	if options == nil {
+		return nil
+	}
+
+	c := make([]Option, 0, len(options))
+	for _, o := range options {
+		c = append(c, cloneOption(o))
+	}
+
+	return c
+}
+
+// cloneOption returns a deep copy of o.
+func cloneOption(o Option) Option {
+	switch o := o.(type) {
+	case *LinkLayerAddress:
+		c := *o
+		c.Addr = bytes.Clone(o.Addr)
+		return &c
+	case *MTU:
+		c := *o
+		return &c
+	case *PrefixInformation:
+		c := *o
+		return &c
+	case *RouteInformation:
+		c := *o
+		return &c
+	case *RecursiveDNSServer:
+		c := *o
+		if o.Servers != nil {
+			c.Servers = append([]netip.Addr(nil), o.Servers...)
+		}
+		return &c
+	case *RAFlagsExtension:
+		c := *o
+		c.Flags = bytes.Clone(o.Flags)
+		return &c
+	case *DNSSearchList:
+		c := *o
+		if o.DomainNames != nil {
+			c.DomainNames = append([]string(nil), o.DomainNames...)
+		}
+		if o.Labels != nil {
+			c.Labels = make([][][]byte, 0, len(o.Labels))
+			for _, ls := range o.Labels {
+				cls := make([][]byte, 0, len(ls))
+				for _, l := range ls {
+					cls = append(cls, append([]byte(nil), l...))
+				}
+				c.Labels = append(c.Labels, cls)
+			}
+		}
+		return &c
+	case *CaptivePortal:
+		c := *o
+		return &c
+	case *PREF64:
+		c := *o
+		return &c
+	case *Nonce:
+		return &Nonce{b: bytes.Clone(o.b)}
+	case *Timestamp:
+		c := *o
+		return &c
+	case *RSASignature:
+		c := *o
+		c.Signature = bytes.Clone(o.Signature)
+		return &c
+	case *CGA:
+		c := *o
+		c.PublicKey = bytes.Clone(o.PublicKey)
+		c.Extensions = bytes.Clone(o.Extensions)
+		return &c
+	case *AddressList:
+		c := *o
+		if o.Addresses != nil {
+			c.Addresses = append([]netip.Addr(nil), o.Addresses...)
+		}
+		return &c
+	case *AddressRegistration:
+		c := *o
+		c.ROVR = bytes.Clone(o.ROVR)
+		return &c
+	case *PvD:
+		c := *o
+		if o.RouterAdvertisement != nil {
+			c.RouterAdvertisement = o.RouterAdvertisement.Clone().(*RouterAdvertisement)
+		}
+		c.Options = cloneOptions(o.Options)
+		return &c
+	case *EncryptedDNS:
+		c := *o
+		if o.Addresses != nil {
+			c.Addresses = append([]netip.Addr(nil), o.Addresses...)
+		}
+		if o.SvcParams != nil {
+			c.SvcParams = make([]SvcParam, 0, len(o.SvcParams))
+			for _, p := range o.SvcParams {
+				c.SvcParams = append(c.SvcParams, SvcParam{
+					Key:   p.Key,
+					Value: bytes.Clone(p.Value),
+				})
+			}
+		}
+		return &c
+	case *RawOption:
+		c := *o
+		c.Value = bytes.Clone(o.Value)
+		return &c
+	default:
+		// A registered Option can be copied through its binary form.
+		if c := registeredOption(o.Code()); c != nil {
+			if b, err := appendOption(nil, o); err == nil && c.UnmarshalBinary(b) == nil {
+				return c
+			}
+		}
+
+		// Unknown Option type with no known internal structure, return it
+		// as-is.
+		return o
+	}
+}
+
+// maxOptionLen is the maximum length in bytes of an option, including its type
+// and length fields.
+const maxOptionLen = math.MaxUint8 * 8
+
+// optionLength computes the NDP option length value, in units of 8 bytes, for
+// an option of n bytes including its type and length fields.
+func optionLength(n int) (uint8, error) {
+	if n%8 != 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if l := n / 8; l > math.MaxUint8 {
+		return 0, fmt.Errorf("ndp: option length of %d bytes is too large", n)
+	}
+
+	return uint8(n / 8), nil
+}
+
+// padLen returns the number of null padding bytes needed to extend an option
+// of n bytes to the next unit of 8 bytes.
+func padLen(n int) int {
+	if r := n % 8; r != 0 {
+		return 8 - r
+	}
+
+	return 0
+}
+
+// appendPadding appends null bytes to b to extend an option of n bytes to the
+// next unit of 8 bytes.
+func appendPadding(b []byte, n int) []byte {
+	return append(b, make([]byte, padLen(n))...)
+}
+
+// as16 returns the 16 byte form of ip. IPv4 addresses are stored in the leading
+// bytes and the zero value produces all zero bytes, as if ip.AsSlice were
+// copied into a 16 byte field.
+func as16(ip netip.Addr) [16]byte {
+	var b [16]byte
+	switch {
+	case ip.Is6():
+		b = ip.As16()
+	case ip.Is4():
+		b4 := ip.As4()
+		copy(b[:], b4[:])
+	}
+
+	return b
+}
+
+// An OptionError is an error which occurred while parsing a malformed Option
+// with a Parser which has TolerantOptions set.
+type OptionError struct {
+	// Index is the index of the malformed Option in the parsed Options, which
+	// is a *RawOption containing the Option's binary form. For an Option
+	// encapsulated in a PvD option, Index is relative to the PvD's Options.
+	Index int
+
+	// Code is the code of the malformed Option, and Err is the error which
+	// occurred while parsing it.
+	Code uint8
+	Err  error
+}
+
+// Error implements error.
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("ndp: malformed option %d with code %d: %v", e.Index, e.Code, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *OptionError) Unwrap() error { return e.Err }
+
+// parseOptions parses a slice of Options from a byte slice according to the
+// policies of p and appends them to options. Options already present in the
+// spare capacity of options are reused when they are of the same type as the
+// Option being parsed.
+func parseOptions(p *Parser, options []Option, b []byte) ([]Option, error) {
+	return parseOptionsUsage(p, &optionUsage{}, options, b)
+}
+
+// An optionUsage tracks the resources consumed by the options of a Message,
+// including those encapsulated in other options, for the limits of a Parser.
+type optionUsage struct {
+	count, rdnss, dnssl, labels, cost int
+
+	// inPvD is set while parsing the options encapsulated in a PvD option.
+	inPvD bool
+}
+
+// parseOptionsUsage parses options in the same way as parseOptions, adding
+// the resources they consume to u.
+func parseOptionsUsage(p *Parser, u *optionUsage, options []Option, b []byte) ([]Option, error) {
+	lim := p.limits()
+
+	for i := 0; len(b[i:]) != 0; {
+		// Two bytes: option type and option length.
+		if len(b[i:]) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		// Type processed as-is, but length is stored in units of 8 bytes,
+		// so expand it to the actual byte length.
+		t := b[i]
+		l := int(b[i+1]) * 8
+
+		// Verify that we won't advance beyond the end of the byte slice.
+		if l > len(b[i:]) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		// A zero length option cannot be skipped, so it is always fatal even
+		// when tolerating malformed options.
+		if l == 0 {
+			return nil, errors.New("ndp: option length must not be zero")
+		}
+
+		u.count++
+		if err := checkLimit("options", lim.MaxOptions, u.count); err != nil {
+			return nil, err
+		}
+		if err := checkLimit("option bytes", lim.MaxOptionSize, l); err != nil {
+			return nil, err
+		}
+
+		u.cost += optionCost(t, l)
+		if err := checkLimit("cost units", lim.MaxCost, u.cost); err != nil {
+			return nil, err
+		}
+
+		// Check for a previously allocated Option which can be reused.
+		var prev Option
+		if n := len(options); n < cap(options) {
+			prev = options[:n+1][n]
+		}
+
+		// Infer the option from its type value and use it for unmarshaling.
+		o := newOption(t, prev)
+		if o == nil {
+			o = registeredOption(t)
+		}
+		if o == nil {
+			o = reuseOption[RawOption](prev)
+		}
+
+		// Unmarshal at the current offset, up to the expected length. Options
+		// encapsulated in a PvD are subject to the same policies and limits
+		// as those of the Message.
+		var err error
+		if pvd, ok := o.(*PvD); ok {
+			if u.inPvD {
+				err = errPvDNested
+			} else {
+				err = pvd.unmarshal(p, u, b[i:i+l])
+			}
+		} else {
+			err = o.UnmarshalBinary(b[i : i+l])
+		}
+		if err != nil {
+			// Per RFC 4191, Section 2.3:
+			// "If the Reserved (10) value is received, the Route Information
+			// Option MUST be ignored."
+			if t == optRouteInformation && errors.Is(err, errPreferenceReserved) && !p.strictPreference() {
+				i += l
+				continue
+			}
+
+			// Limits apply even when tolerating malformed options.
+			var lerr *LimitError
+			if !p.tolerantOptions() || errors.As(err, &lerr) {
+				return nil, err
+			}
+
+			// Preserve the malformed option in its binary form and report
+			// the error, but continue parsing the remaining options.
+			raw := reuseOption[RawOption](prev)
+			if rerr := raw.UnmarshalBinary(b[i : i+l]); rerr != nil {
+				return nil, rerr
+			}
+
+			p.optionError(&OptionError{
+				Index: len(options),
+				Code:  t,
+				Err:   err,
+			})
+			o = raw
+		}
+
+		switch o := o.(type) {
+		case *RecursiveDNSServer:
+			u.rdnss += len(o.Servers)
+			if err := checkLimit("RDNSS servers", lim.MaxRDNSSServers, u.rdnss); err != nil {
+				return nil, err
+			}
+		case *DNSSearchList:
+			u.dnssl += len(o.DomainNames) + len(o.Labels)
+			for _, dn := range o.DomainNames {
+				u.labels += strings.Count(dn, ".") + 1
+			}
+			for _, ls := range o.Labels {
+				u.labels += len(ls)
+			}
+
+			if err := checkLimit("DNSSL domain names", lim.MaxDNSSLDomains, u.dnssl); err != nil {
+				return nil, err
+			}
+			if err := checkLimit("DNSSL labels", lim.MaxDNSSLLabels, u.labels); err != nil {
+				return nil, err
+			}
+		}
+
+		// Advance to the next option's type field.
+		i += l
+
+		options = append(options, o)
+	}
+
+	return options, nil
+}
+
+// newOption returns an empty Option for code if code is implemented by this
+// package, reusing prev if it is of the same type, or returns nil otherwise.
+func newOption(code uint8, prev Option) Option {
+	switch code {
+	case optSourceLLA, optTargetLLA:
+		return reuseOption[LinkLayerAddress](prev)
+	case optMTU:
+		return reuseOption[MTU](prev)
+	case optPrefixInformation:
+		return reuseOption[PrefixInformation](prev)
+	case optRouteInformation:
+		return reuseOption[RouteInformation](prev)
+	case optRDNSS:
+		return reuseOption[RecursiveDNSServer](prev)
+	case optRAFlagsExtension:
+		return reuseOption[RAFlagsExtension](prev)
+	case optDNSSL:
+		return reuseOption[DNSSearchList](prev)
+	case optCaptivePortal:
+		return reuseOption[CaptivePortal](prev)
+	case optPREF64:
+		return reuseOption[PREF64](prev)
+	case optNonce:
+		return reuseOption[Nonce](prev)
+	case optTimestamp:
+		return reuseOption[Timestamp](prev)
+	case optRSASignature:
+		return reuseOption[RSASignature](prev)
+	case optCGA:
+		return reuseOption[CGA](prev)
+	case optSourceAddressList, optTargetAddressList:
+		return reuseOption[AddressList](prev)
+	case optAddressRegistration:
+		return reuseOption[AddressRegistration](prev)
+	case optPvD:
+		return reuseOption[PvD](prev)
+	case optEncryptedDNS:
+		return reuseOption[EncryptedDNS](prev)
+	default:
+		return nil
+	}
+}
+
+// optionCost returns the estimated cost of parsing an option with code t and
+// length l in bytes, as described by ParseLimits.MaxCost.
+func optionCost(t uint8, l int) int {
+	const overhead = 16
+
+	switch t {
+	case optDNSSL, optPvD, optEncryptedDNS:
+		// Domain names are validated and decoded label by label.
+		return overhead + 64*l
+	default:
+		return overhead + l
+	}
+}
+
+// reuseOption returns prev if it is of type *T, or a newly allocated *T
+// otherwise.
+func reuseOption[T any, PT interface {
+	*T
+	Option
+}](prev Option) Option {
+	if o, ok := prev.(PT); ok {
+		return o
+	}
+
+	return PT(new(T))
+}
+
+// appendDomain appends the domain name dn to b using the algorithm from:
+// https://tools.ietf.org/html/rfc1035#section-3.1. Unicode names are converted
+// to Punycode using p, or are rejected if p is nil. It reports false if dn
+// cannot be encoded.
+func appendDomain(b []byte, dn string, p *idna.Profile) ([]byte, bool) {
+	if p != nil {
+		var err error
+		dn, err = p.ToASCII(dn)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	// Attach each label component of a domain name with a one byte length
+	// prefix and a null terminator.
+	for _, label := range strings.Split(dn, ".") {
+		// Label must be convertable to valid Punycode.
+		if !isASCII(label) {
+			return nil, false
+		}
+
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+
+	return append(b, 0), true
+}
+
+// appendLabels appends the labels of a domain name to b verbatim, using the
+// algorithm from: https://tools.ietf.org/html/rfc1035#section-3.1. It reports
+// false if labels cannot be encoded.
+func appendLabels(b []byte, labels [][]byte) ([]byte, bool) {
+	if len(labels) == 0 {
+		return nil, false
+	}
+
+	for _, l := range labels {
+		if len(l) == 0 || len(l) > math.MaxUint8 {
+			return nil, false
+		}
+
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+
+	return append(b, 0), true
+}
+
+// parseLabels parses the labels of a domain name from the beginning of b
+// without validating or decoding them, as the inverse of appendLabels. The
+// returned labels alias b. It returns the number of bytes consumed, or false
+// if the domain name is malformed.
+func parseLabels(b []byte) ([][]byte, int, bool) {
+	var labels [][]byte
+	for i := 0; i < len(b); {
+		length := int(b[i])
+		i++
+
+		if length == 0 {
+			if len(labels) == 0 {
+				return nil, 0, false
+			}
+
+			return labels, i, true
+		}
+
+		// The label must leave room for at least a null terminator.
+		if length >= len(b[i:]) {
+			return nil, 0, false
+		}
+
+		labels = append(labels, b[i:i+length:i+length])
+		i += length
+	}
+
+	// No null terminator.
+	return nil, 0, false
+}
+
+// parseDomain parses a domain name from the beginning of b using the
+// algorithm from: https://tools.ietf.org/html/rfc1035#section-3.1. It returns
+// the domain name and the number of bytes consumed, or false if the domain
+// name is malformed. Punycode labels are decoded using p, or are returned
+// verbatim if p is nil.
+func parseDomain(b []byte, p *idna.Profile) (string, int, bool) {
+	// A domain is comprised of a sequence of labels, which are accumulated and
+	// then separated by periods later on.
+	var labels []string
+	for i := 0; i < len(b); {
+		// Parse the length of the upcoming label.
+		length := int(b[i])
+		i++
+
+		if length == 0 {
+			// A null byte terminates the domain name, which must have at
+			// least one label.
+			if len(labels) == 0 {
+				return "", 0, false
+			}
+
+			domain := strings.Join(labels, ".")
+			if p == nil {
+				return domain, i, true
+			}
+
+			domain, err := p.ToUnicode(domain)
+			if err != nil {
+				return "", 0, false
+			}
+
+			return domain, i, true
+		}
+
+		// The label must leave room for at least a null terminator.
+		if length >= len(b[i:]) {
+			return "", 0, false
+		}
+
+		// Parse the label string and ensure it is ASCII, and that it doesn't
+		// contain invalid characters.
+		label := string(b[i : i+length])
+		if !isASCII(label) {
+			return "", 0, false
+		}
+
+		// TODO(mdlayher): much smarter validation.
+		if strings.Contains(label, ".") || strings.Contains(label, " ") {
+			return "", 0, false
+		}
+
+		// Verify that the Punycode label decodes to something sane.
+		if p != nil {
+			var err error
+			label, err = p.ToUnicode(label)
+			if err != nil {
+				return "", 0, false
+			}
+		}
+
+		// TODO(mdlayher): much smarter validation.
+		if label == "" || hasUnicodeReplacement(label) || strings.Contains(label, ".") || strings.Contains(label, " ") {
+			return "", 0, false
+		}
+
+		labels = append(labels, label)
+		i += length
+	}
+
+	// No null terminator.
+	return "", 0, false
 }
 
 // isASCII verifies that the contents of s are all ASCII characters.
@@ -4,6 +4,10 @@ package ndp
 // and unmarshaling functions.
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
 	"net"
 	"net/netip"
 	"strings"
@@ -12,6 +16,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mdlayher/ndp/internal/ndptest"
+	"golang.org/x/net/idna"
 )
 
 // An optionSub is a sub-test structure for Option marshal/unmarshal tests.
@@ -79,6 +84,26 @@ func TestOptionMarshalUnmarshal(t *testing.T) {
 			name: "nonce",
 			subs: nonceTests(),
 		},
+		{
+			name: "address list",
+			subs: alTests(),
+		},
+		{
+			name: "timestamp",
+			subs: tsTests(),
+		},
+		{
+			name: "address registration",
+			subs: arTests(),
+		},
+		{
+			name: "PvD",
+			subs: pvdTests(),
+		},
+		{
+			name: "encrypted DNS",
+			subs: dnrTests(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,7 +128,7 @@ func TestOptionMarshalUnmarshal(t *testing.T) {
 						t.Fatalf("unexpected options bytes (-want +got):\n%s", diff)
 					}
 
-					got, err := parseOptions(b)
+					got, err := parseOptions(nil, nil, b)
 					if err != nil {
 						t.Fatalf("failed to unmarshal options: %v", err)
 					}
@@ -164,6 +189,13 @@ func TestOptionUnmarshalError(t *testing.T) {
 						ndptest.Zero(16),
 					},
 				},
+				{
+					name: "unsupported length",
+					bs: [][]byte{
+						{0x01, 0x04},
+						ndptest.Zero(30),
+					},
+				},
 			},
 		},
 		{
@@ -241,6 +273,174 @@ func TestOptionUnmarshalError(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "address list",
+			o:    &AddressList{},
+			subs: []sub{
+				{
+					name: "invalid type",
+					bs: [][]byte{
+						{0x01, 0x03},
+						ndptest.Zero(22),
+					},
+				},
+				{
+					name: "no addresses",
+					bs: [][]byte{
+						{9, 1},
+						ndptest.Zero(6),
+					},
+				},
+				{
+					name: "half address",
+					bs: [][]byte{
+						{10, 2},
+						ndptest.Zero(14),
+					},
+				},
+			},
+		},
+		{
+			name: "address registration",
+			o:    &AddressRegistration{},
+			subs: []sub{
+				{
+					name: "short",
+					bs: [][]byte{
+						{33, 1},
+						ndptest.Zero(6),
+					},
+				},
+				{
+					name: "long",
+					bs: [][]byte{
+						{33, 6},
+						ndptest.Zero(46),
+					},
+				},
+			},
+		},
+		{
+			name: "PvD",
+			o:    &PvD{},
+			subs: []sub{
+				{
+					name: "short",
+					bs: [][]byte{
+						{21, 1},
+						ndptest.Zero(6),
+					},
+				},
+				{
+					name: "no FQDN terminator",
+					bs: [][]byte{
+						{21, 1},
+						{0x00, 0x00, 0x00, 0x01},
+						{1, 'a'},
+					},
+				},
+				{
+					name: "short RA header",
+					bs: [][]byte{
+						{21, 2},
+						{0x20, 0x00, 0x00, 0x01},
+						{1, 'a', 0},
+						ndptest.Zero(7),
+					},
+				},
+				{
+					name: "bad options",
+					bs: [][]byte{
+						{21, 3},
+						{0x00, 0x00, 0x00, 0x01},
+						{1, 'a', 0},
+						ndptest.Zero(7),
+						// MTU option with a misleading length.
+						{5, 2},
+						ndptest.Zero(6),
+					},
+				},
+			},
+		},
+		{
+			name: "encrypted DNS",
+			o:    &EncryptedDNS{},
+			subs: []sub{
+				{
+					name: "short",
+					bs: [][]byte{
+						{144, 1},
+						ndptest.Zero(6),
+					},
+				},
+				{
+					name: "ADN length",
+					bs: [][]byte{
+						{144, 2},
+						ndptest.Zero(6),
+						{0x00, 0x08},
+						ndptest.Zero(6),
+					},
+				},
+				{
+					name: "no addresses",
+					bs: [][]byte{
+						{144, 2},
+						ndptest.Zero(6),
+						{0x00, 0x03, 1, 'a', 0},
+						{0x00, 0x00},
+						ndptest.Zero(1),
+					},
+				},
+				{
+					name: "bad address length",
+					bs: [][]byte{
+						{144, 2},
+						ndptest.Zero(6),
+						{0x00, 0x03, 1, 'a', 0},
+						{0x00, 0x01, 0x00},
+					},
+				},
+				{
+					name: "short service parameter",
+					bs: [][]byte{
+						{144, 5},
+						ndptest.Zero(6),
+						{0x00, 0x03, 1, 'a', 0},
+						{0x00, 0x10},
+						ndptest.Zero(16),
+						{0x00, 0x02, 0x00, 0x01},
+						ndptest.Zero(5),
+					},
+				},
+			},
+		},
+		{
+			name: "RSA signature",
+			o:    &RSASignature{},
+			subs: []sub{
+				{
+					name: "short key hash",
+					bs: [][]byte{
+						{12, 2},
+						ndptest.Zero(14),
+					},
+				},
+			},
+		},
+		{
+			name: "timestamp",
+			o:    &Timestamp{},
+			subs: []sub{
+				{
+					name: "short",
+					bs: [][]byte{
+						{13, 1},
+						ndptest.Zero(6),
+					},
+				},
+			},
+		},
 		{
 			name: "rdnss",
 			o:    &RecursiveDNSServer{},
@@ -373,7 +573,7 @@ func TestOptionUnmarshalError(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			for _, st := range tt.subs {
 				t.Run(st.name, func(t *testing.T) {
-					err := tt.o.unmarshal(ndptest.Merge(st.bs))
+					err := tt.o.UnmarshalBinary(ndptest.Merge(st.bs))
 
 					if err == nil {
 						t.Fatal("expected an error, but none occurred")
@@ -494,7 +694,7 @@ func TestNewCaptivePortalErrors(t *testing.T) {
 		},
 		{
 			name: "long URI",
-			uri:  strings.Repeat("x", 256),
+			uri:  strings.Repeat("x", 2039),
 		},
 		{
 			name: "IPv4",
@@ -526,6 +726,184 @@ func TestNewCaptivePortalErrors(t *testing.T) {
 	}
 }
 
+func TestNewCaptivePortalLong(t *testing.T) {
+	for _, n := range []int{256, 2038} {
+		uri := "https://portal.example.com/?token=" + strings.Repeat("x", n-34)
+
+		cp, err := NewCaptivePortal(uri)
+		if err != nil {
+			t.Fatalf("failed to create captive portal with %d byte URI: %v", n, err)
+		}
+
+		b, err := cp.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+
+		var got CaptivePortal
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if diff := cmp.Diff(uri, got.URI); diff != "" {
+			t.Fatalf("unexpected URI (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestCaptivePortalHelpers(t *testing.T) {
+	tests := []struct {
+		name         string
+		cp           *CaptivePortal
+		url          string
+		unrestricted bool
+	}{
+		{
+			name:         "unrestricted",
+			cp:           mustCaptivePortal(""),
+			url:          Unrestricted,
+			unrestricted: true,
+		},
+		{
+			name:         "unrestricted case and padding",
+			cp:           &CaptivePortal{URI: "URN:IETF:params:capport:unrestricted\x00\x00"},
+			url:          "urn:IETF:params:capport:unrestricted",
+			unrestricted: true,
+		},
+		{
+			name: "URL",
+			cp:   &CaptivePortal{URI: "https://captive.example.com/api\x00"},
+			url:  "https://captive.example.com/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.unrestricted, tt.cp.IsUnrestricted()); diff != "" {
+				t.Fatalf("unexpected unrestricted (-want +got):\n%s", diff)
+			}
+
+			u, err := tt.cp.URL()
+			if err != nil {
+				t.Fatalf("failed to parse URL: %v", err)
+			}
+			if diff := cmp.Diff(tt.url, u.String()); diff != "" {
+				t.Fatalf("unexpected URL (-want +got):\n%s", diff)
+			}
+
+			// Unmarshaling trims padding, which Equal must ignore.
+			b, err := tt.cp.MarshalBinary()
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+
+			var got CaptivePortal
+			if err := got.UnmarshalBinary(b); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if !tt.cp.Equal(&got) || !got.Equal(tt.cp) {
+				t.Fatalf("captive portals are not equal: %q and %q", tt.cp.URI, got.URI)
+			}
+		})
+	}
+
+	if (&CaptivePortal{URI: "a"}).Equal(&CaptivePortal{URI: "b"}) {
+		t.Fatal("different captive portals are equal")
+	}
+}
+
+func TestOptionEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Option
+		ok   bool
+	}{
+		{
+			name: "LLA equal",
+			x:    &LinkLayerAddress{Direction: Source, Addr: ndptest.MAC},
+			y:    &LinkLayerAddress{Direction: Source, Addr: ndptest.MAC},
+			ok:   true,
+		},
+		{
+			name: "LLA direction",
+			x:    &LinkLayerAddress{Direction: Source, Addr: ndptest.MAC},
+			y:    &LinkLayerAddress{Direction: Target, Addr: ndptest.MAC},
+		},
+		{
+			name: "MTU",
+			x:    NewMTU(1500),
+			y:    NewMTU(9000),
+		},
+		{
+			name: "RDNSS equal",
+			x: &RecursiveDNSServer{
+				Lifetime: 10 * time.Second,
+				Servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			},
+			y: &RecursiveDNSServer{
+				Lifetime: 10 * time.Second,
+				Servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			},
+			ok: true,
+		},
+		{
+			name: "RDNSS servers",
+			x: &RecursiveDNSServer{
+				Servers: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			},
+			y: &RecursiveDNSServer{
+				Servers: []netip.Addr{netip.MustParseAddr("2001:db8::2")},
+			},
+		},
+		{
+			name: "DNSSL domains",
+			x:    &DNSSearchList{DomainNames: []string{"example.com"}},
+			y:    &DNSSearchList{DomainNames: []string{"example.com", "example.org"}},
+		},
+		{
+			name: "PREF64 equal",
+			x:    &PREF64{Lifetime: 8 * time.Second, Prefix: netip.MustParsePrefix("64:ff9b::/96")},
+			y:    &PREF64{Lifetime: 8 * time.Second, Prefix: netip.MustParsePrefix("64:ff9b::/96")},
+			ok:   true,
+		},
+		{
+			name: "raw value",
+			x:    &RawOption{Type: 10, Length: 1, Value: []byte{0, 1, 2, 3, 4, 5}},
+			y:    &RawOption{Type: 10, Length: 1, Value: []byte{0, 1, 2, 3, 4, 6}},
+		},
+		{
+			name: "different types",
+			x:    NewMTU(1500),
+			y:    &RawOption{Type: optMTU, Length: 1, Value: []byte{0, 0, 0, 0, 0x05, 0xdc}},
+		},
+		{
+			name: "nil",
+			x:    NewMTU(1500),
+		},
+		{
+			name: "typed nil",
+			x:    NewMTU(1500),
+			y:    (*MTU)(nil),
+		},
+		{
+			name: "both typed nil",
+			x:    (*PvD)(nil),
+			y:    (*PvD)(nil),
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.ok, optionEqual(tt.x, tt.y)); diff != "" {
+				t.Fatalf("unexpected x == y (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.ok, optionEqual(tt.y, tt.x)); diff != "" {
+				t.Fatalf("unexpected y == x (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func llaTests() []optionSub {
 	return []optionSub{
 		{
@@ -573,6 +951,38 @@ func llaTests() []optionSub {
 			},
 			ok: true,
 		},
+		{
+			name: "ok, EUI-64",
+			os: []Option{
+				&LinkLayerAddress{
+					Direction: Source,
+					Addr:      net.HardwareAddr{0x02, 0x00, 0x5e, 0xff, 0xfe, 0x00, 0x53, 0x01},
+				},
+			},
+			bs: [][]byte{
+				{0x01, 0x02},
+				{0x02, 0x00, 0x5e, 0xff, 0xfe, 0x00, 0x53, 0x01},
+				// Padding.
+				ndptest.Zero(6),
+			},
+			ok: true,
+		},
+		{
+			name: "ok, InfiniBand",
+			os: []Option{
+				&LinkLayerAddress{
+					Direction: Target,
+					Addr:      net.HardwareAddr(bytes.Repeat([]byte{0xab}, 20)),
+				},
+			},
+			bs: [][]byte{
+				{0x02, 0x03},
+				// Reserved.
+				{0x00, 0x00},
+				bytes.Repeat([]byte{0xab}, 20),
+			},
+			ok: true,
+		},
 	}
 }
 
@@ -619,6 +1029,37 @@ func piTests() []optionSub {
 			},
 			ok: true,
 		},
+		{
+			name: "ok, router address",
+			os: []Option{
+				&PrefixInformation{
+					// Host IP specified as the router address.
+					PrefixLength:      64,
+					OnLink:            true,
+					RouterAddress:     true,
+					ValidLifetime:     Infinity,
+					PreferredLifetime: Infinity,
+					Prefix:            ndptest.IP,
+				},
+			},
+			bs: [][]byte{
+				// Option type and length.
+				{0x03, 0x04},
+				// Prefix Length.
+				{64},
+				// Flags, O and R set.
+				{0xa0},
+				// Valid lifetime.
+				{0xff, 0xff, 0xff, 0xff},
+				// Preferred lifetime.
+				{0xff, 0xff, 0xff, 0xff},
+				// Reserved.
+				{0x00, 0x00, 0x00, 0x00},
+				// Router address.
+				ndptest.IP.AsSlice(),
+			},
+			ok: true,
+		},
 	}
 }
 
@@ -644,19 +1085,46 @@ func riTests() []optionSub {
 			},
 		},
 		{
-			name: "ok /0",
+			name: "bad, reserved preference",
 			os: []Option{
 				&RouteInformation{
-					PrefixLength:  0,
-					Preference:    High,
-					RouteLifetime: Infinity,
-					Prefix:        netip.IPv6Unspecified(),
+					Preference: prfReserved,
+					Prefix:     netip.IPv6Unspecified(),
 				},
 			},
-			bs: [][]byte{
-				// Option type and length.
-				{24, 0x01},
-				// Prefix length.
+		},
+		{
+			name: "ok /63",
+			os: []Option{
+				&RouteInformation{
+					PrefixLength:  63,
+					RouteLifetime: Infinity,
+					Prefix:        netip.MustParseAddr("2001:db8:0:fe::"),
+				},
+			},
+			bs: [][]byte{
+				{24, 2, 63, 0x00},
+				// Route lifetime.
+				{0xff, 0xff, 0xff, 0xff},
+				// Prefix, including the final partial byte.
+				{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0xfe},
+			},
+			ok: true,
+		},
+		{
+			name: "ok /0",
+			os: []Option{
+				&RouteInformation{
+					PrefixLength:  0,
+					Preference:    High,
+					RouteLifetime: Infinity,
+					Prefix:        netip.IPv6Unspecified(),
+				},
+			},
+			bs: [][]byte{
+				// Option type and length.
+				{24, 0x01},
+				// Prefix length.
 				{0},
 				// Preference.
 				{0x08},
@@ -733,13 +1201,13 @@ func roTests() []optionSub {
 			name: "ok",
 			os: []Option{
 				&RawOption{
-					Type:   10,
+					Type:   253,
 					Length: 2,
 					Value:  ndptest.Zero(14),
 				},
 			},
 			bs: [][]byte{
-				{0x0a, 0x02},
+				{0xfd, 0x02},
 				ndptest.Zero(14),
 			},
 			ok: true,
@@ -1165,6 +1633,1259 @@ func nonceTests() []optionSub {
 	}
 }
 
+func arTests() []optionSub {
+	eui64 := []byte{0x02, 0x00, 0x5e, 0xff, 0xfe, 0x00, 0x53, 0x01}
+
+	return []optionSub{
+		{
+			name: "bad, ROVR length",
+			os: []Option{&AddressRegistration{
+				ROVR: []byte{0xff},
+			}},
+		},
+		{
+			name: "bad, opaque type",
+			os: []Option{&AddressRegistration{
+				OpaqueType: 4,
+				ROVR:       eui64,
+			}},
+		},
+		{
+			name: "bad, lifetime",
+			os: []Option{&AddressRegistration{
+				Lifetime: 65536 * time.Minute,
+				ROVR:     eui64,
+			}},
+		},
+		{
+			name: "ok, ARO",
+			os: []Option{&AddressRegistration{
+				Status:   ARDuplicateAddress,
+				Lifetime: 60 * time.Minute,
+				ROVR:     eui64,
+			}},
+			bs: [][]byte{
+				{33, 2, 0x01, 0x00},
+				// Reserved.
+				{0x00, 0x00},
+				// Lifetime.
+				{0x00, 0x3c},
+				eui64,
+			},
+			ok: true,
+		},
+		{
+			name: "ok, EARO",
+			os: []Option{&AddressRegistration{
+				Status:              ARSuccess,
+				Opaque:              0xab,
+				OpaqueType:          1,
+				RequestReachability: true,
+				HasTID:              true,
+				TID:                 0x10,
+				Lifetime:            10 * time.Minute,
+				ROVR:                ndptest.Zero(16),
+			}},
+			bs: [][]byte{
+				{33, 3, 0x00, 0xab},
+				// Flags and TID.
+				{0x07, 0x10},
+				// Lifetime.
+				{0x00, 0x0a},
+				ndptest.Zero(16),
+			},
+			ok: true,
+		},
+	}
+}
+
+func pvdTests() []optionSub {
+	return []optionSub{
+		{
+			name: "bad, no FQDN",
+			os:   []Option{&PvD{}},
+		},
+		{
+			name: "bad, delay",
+			os: []Option{&PvD{
+				Delay: 0x10,
+				FQDN:  "example.com",
+			}},
+		},
+		{
+			name: "bad, RA options",
+			os: []Option{&PvD{
+				FQDN: "example.com",
+				RouterAdvertisement: &RouterAdvertisement{
+					Options: []Option{NewMTU(1500)},
+				},
+			}},
+		},
+		{
+			name: "bad, nested PvD",
+			os: []Option{&PvD{
+				FQDN:    "example.com",
+				Options: []Option{&PvD{FQDN: "example.org"}},
+			}},
+		},
+		{
+			name: "ok, FQDN only",
+			os: []Option{&PvD{
+				HTTP:     true,
+				Delay:    5,
+				Sequence: 0x0102,
+				FQDN:     "example.com",
+			}},
+			bs: [][]byte{
+				{21, 3, 0x80, 0x05},
+				// Sequence.
+				{0x01, 0x02},
+				// FQDN.
+				{7},
+				[]byte("example"),
+				{3},
+				[]byte("com"),
+				{0x00},
+				// Padding.
+				ndptest.Zero(5),
+			},
+			ok: true,
+		},
+		{
+			name: "ok, RA header and options",
+			os: []Option{&PvD{
+				Legacy:   true,
+				Sequence: 1,
+				FQDN:     "pvd.example.com",
+				RouterAdvertisement: &RouterAdvertisement{
+					CurrentHopLimit:      64,
+					ManagedConfiguration: true,
+					RouterLifetime:       30 * time.Minute,
+				},
+				Options: []Option{
+					NewMTU(1500),
+					&RecursiveDNSServer{
+						Lifetime: 1 * time.Hour,
+						Servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+					},
+				},
+			}},
+			bs: [][]byte{
+				{21, 9, 0x60, 0x00},
+				// Sequence.
+				{0x00, 0x01},
+				// FQDN.
+				{3},
+				[]byte("pvd"),
+				{7},
+				[]byte("example"),
+				{3},
+				[]byte("com"),
+				{0x00},
+				// Padding.
+				ndptest.Zero(1),
+				// RA header: type, code, and checksum.
+				{134, 0x00, 0x00, 0x00},
+				{64, 0x80},
+				// Router lifetime.
+				{0x07, 0x08},
+				// Reachable time and retransmit timer.
+				ndptest.Zero(8),
+				// MTU.
+				{0x05, 0x01, 0x00, 0x00},
+				{0x00, 0x00, 0x05, 0xdc},
+				// RDNSS.
+				{25, 3, 0x00, 0x00},
+				{0x00, 0x00, 0x0e, 0x10},
+				netip.MustParseAddr("2001:db8::1").AsSlice(),
+			},
+			ok: true,
+		},
+	}
+}
+
+func TestPvDUnmarshal(t *testing.T) {
+	t.Run("RA header type and code ignored", func(t *testing.T) {
+		b := ndptest.Merge([][]byte{
+			{21, 4, 0x20, 0x00},
+			// Sequence.
+			{0x00, 0x00},
+			// FQDN.
+			{1, 'a', 0x00},
+			// Padding.
+			ndptest.Zero(7),
+			// RA header: type, code, and checksum are ignored by receivers.
+			{0xff, 0xff, 0xff, 0xff},
+			{64, 0x00},
+			// Router lifetime.
+			{0x00, 0x1e},
+			// Reachable time and retransmit timer.
+			ndptest.Zero(8),
+		})
+
+		var p PvD
+		if err := p.UnmarshalBinary(b); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+
+		want := &PvD{
+			FQDN: "a",
+			RouterAdvertisement: &RouterAdvertisement{
+				CurrentHopLimit: 64,
+				RouterLifetime:  30 * time.Second,
+			},
+		}
+		if diff := cmp.Diff(want, &p); diff != "" {
+			t.Fatalf("unexpected PvD (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("nested PvD", func(t *testing.T) {
+		// Nested PvDs cannot be marshaled, so wrap the binary form of one in
+		// a RawOption.
+		inner, err := (&PvD{FQDN: "example.org"}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal inner PvD: %v", err)
+		}
+
+		var raw RawOption
+		if err := raw.UnmarshalBinary(inner); err != nil {
+			t.Fatalf("failed to unmarshal raw option: %v", err)
+		}
+
+		b, err := (&PvD{FQDN: "example.com", Options: []Option{&raw}}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal outer PvD: %v", err)
+		}
+
+		if err := new(PvD).UnmarshalBinary(b); !errors.Is(err, errPvDNested) {
+			t.Fatalf("expected nested PvD error, but got: %v", err)
+		}
+
+		// A tolerant Parser preserves the nested PvD as a RawOption.
+		var oerr *OptionError
+		pr := &Parser{
+			TolerantOptions: true,
+			OptionError:     func(err *OptionError) { oerr = err },
+		}
+
+		got, err := parseOptions(pr, nil, b)
+		if err != nil {
+			t.Fatalf("failed to parse options: %v", err)
+		}
+
+		want := []Option{&PvD{FQDN: "example.com", Options: []Option{&raw}}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("unexpected options (-want +got):\n%s", diff)
+		}
+		if oerr == nil || !errors.Is(oerr.Err, errPvDNested) {
+			t.Fatalf("expected nested PvD option error, but got: %v", oerr)
+		}
+	})
+}
+
+func dnrTests() []optionSub {
+	addr := netip.MustParseAddr("2001:db8::53")
+
+	return []optionSub{
+		{
+			name: "bad, no ADN",
+			os: []Option{&EncryptedDNS{
+				Addresses: []netip.Addr{addr},
+			}},
+		},
+		{
+			name: "bad, no addresses",
+			os: []Option{&EncryptedDNS{
+				ADN: "resolver.example",
+			}},
+		},
+		{
+			name: "bad, IPv4 address",
+			os: []Option{&EncryptedDNS{
+				ADN:       "resolver.example",
+				Addresses: []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+			}},
+		},
+		{
+			name: "ok",
+			os: []Option{&EncryptedDNS{
+				ServicePriority: 1,
+				Lifetime:        1 * time.Hour,
+				ADN:             "resolver.example",
+				Addresses:       []netip.Addr{addr},
+				SvcParams: []SvcParam{
+					// alpn=dot.
+					{Key: 1, Value: []byte{3, 'd', 'o', 't'}},
+					// port=853.
+					{Key: 3, Value: []byte{0x03, 0x55}},
+				},
+			}},
+			bs: [][]byte{
+				{144, 8},
+				// Service priority.
+				{0x00, 0x01},
+				// Lifetime.
+				{0x00, 0x00, 0x0e, 0x10},
+				// ADN.
+				{0x00, 0x12},
+				{8},
+				[]byte("resolver"),
+				{7},
+				[]byte("example"),
+				{0x00},
+				// Addresses.
+				{0x00, 0x10},
+				addr.AsSlice(),
+				// SvcParams.
+				{0x00, 0x0e},
+				{0x00, 0x01, 0x00, 0x04, 3, 'd', 'o', 't'},
+				{0x00, 0x03, 0x00, 0x02, 0x03, 0x55},
+				// Padding.
+				ndptest.Zero(2),
+			},
+			ok: true,
+		},
+	}
+}
+
+func tsTests() []optionSub {
+	return []optionSub{
+		{
+			name: "bad, before epoch",
+			os:   []Option{&Timestamp{Time: time.Unix(-1, 0)}},
+		},
+		{
+			name: "bad, too large",
+			os:   []Option{&Timestamp{Time: time.Unix(1<<48, 0)}},
+		},
+		{
+			name: "ok",
+			os: []Option{&Timestamp{
+				Time: time.Unix(0x01020304, int64(750*time.Millisecond)),
+			}},
+			bs: [][]byte{
+				{13, 2},
+				// Reserved.
+				ndptest.Zero(6),
+				// Seconds and fraction.
+				{0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0xc0, 0x00},
+			},
+			ok: true,
+		},
+	}
+}
+
+func TestTimestampWithinSkew(t *testing.T) {
+	var (
+		now = time.Unix(1_700_000_000, 0)
+		ts  = &Timestamp{Time: now.Add(-4 * time.Minute)}
+	)
+
+	tests := []struct {
+		name  string
+		delta time.Duration
+		ok    bool
+	}{
+		{name: "default", ok: true},
+		{name: "narrow", delta: 1 * time.Minute},
+		{name: "wide", delta: 10 * time.Minute, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ts.WithinSkew(now, tt.delta); got != tt.ok {
+				t.Fatalf("unexpected skew result: want %v, got %v", tt.ok, got)
+			}
+
+			// Skew is symmetric.
+			future := &Timestamp{Time: now.Add(now.Sub(ts.Time))}
+			if got := future.WithinSkew(now, tt.delta); got != tt.ok {
+				t.Fatalf("unexpected future skew result: want %v, got %v", tt.ok, got)
+			}
+		})
+	}
+}
+
+func alTests() []optionSub {
+	var (
+		first  = netip.MustParseAddr("2001:db8::1")
+		second = netip.MustParseAddr("fe80::2")
+	)
+
+	return []optionSub{
+		{
+			name: "bad, direction",
+			os: []Option{
+				&AddressList{
+					Direction: 10,
+					Addresses: []netip.Addr{first},
+				},
+			},
+		},
+		{
+			name: "bad, no addresses",
+			os: []Option{
+				&AddressList{Direction: Source},
+			},
+		},
+		{
+			name: "bad, IPv4 address",
+			os: []Option{
+				&AddressList{
+					Direction: Target,
+					Addresses: []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+				},
+			},
+		},
+		{
+			name: "ok, source",
+			os: []Option{
+				&AddressList{
+					Direction: Source,
+					Addresses: []netip.Addr{first},
+				},
+			},
+			bs: [][]byte{
+				{9, 3},
+				ndptest.Zero(6),
+				first.AsSlice(),
+			},
+			ok: true,
+		},
+		{
+			name: "ok, target",
+			os: []Option{
+				&AddressList{
+					Direction: Target,
+					Addresses: []netip.Addr{first, second},
+				},
+			},
+			bs: [][]byte{
+				{10, 5},
+				ndptest.Zero(6),
+				first.AsSlice(),
+				second.AsSlice(),
+			},
+			ok: true,
+		},
+	}
+}
+
+func TestPrefixInformationIPPrefix(t *testing.T) {
+	var pi PrefixInformation
+
+	// Host bits are cleared so that the option can always be marshaled.
+	pi.SetIPPrefix(netip.MustParsePrefix("2001:db8::1/64"))
+	if diff := cmp.Diff(netip.MustParsePrefix("2001:db8::/64"), pi.IPPrefix(), cmp.Comparer(prefixEqual)); diff != "" {
+		t.Fatalf("unexpected prefix (-want +got):\n%s", diff)
+	}
+	if _, err := marshalOptions([]Option{&pi}); err != nil {
+		t.Fatalf("failed to marshal prefix information: %v", err)
+	}
+
+	// Host bits set directly are not cleared.
+	pi.Prefix = netip.MustParseAddr("2001:db8::1")
+	if diff := cmp.Diff(netip.MustParsePrefix("2001:db8::1/64"), pi.IPPrefix(), cmp.Comparer(prefixEqual)); diff != "" {
+		t.Fatalf("unexpected prefix (-want +got):\n%s", diff)
+	}
+
+	pi.SetIPPrefix(netip.Prefix{})
+	if pi.Prefix.IsValid() || pi.PrefixLength != 0 {
+		t.Fatalf("invalid prefix was not cleared: %s/%d", pi.Prefix, pi.PrefixLength)
+	}
+}
+
+func TestNewPrefixInformation(t *testing.T) {
+	pi, err := NewPrefixInformation(
+		netip.MustParsePrefix("2001:db8::1/64"),
+		true, true,
+		Infinity, 10*time.Second,
+	)
+	if err != nil {
+		t.Fatalf("failed to create prefix information: %v", err)
+	}
+
+	want := &PrefixInformation{
+		PrefixLength:                   64,
+		OnLink:                         true,
+		AutonomousAddressConfiguration: true,
+		ValidLifetime:                  Infinity,
+		PreferredLifetime:              10 * time.Second,
+		Prefix:                         netip.MustParseAddr("2001:db8::"),
+	}
+	if diff := cmp.Diff(want, pi, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected prefix information (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewPrefixInformationErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		prefix           netip.Prefix
+		valid, preferred time.Duration
+	}{
+		{
+			name: "invalid",
+		},
+		{
+			name:   "IPv4",
+			prefix: netip.MustParsePrefix("192.0.2.0/24"),
+		},
+		{
+			name:   "IPv4-mapped IPv6",
+			prefix: netip.MustParsePrefix("::ffff:192.0.2.0/120"),
+		},
+		{
+			name:   "link-local",
+			prefix: netip.MustParsePrefix("fe80::/64"),
+		},
+		{
+			name:   "multicast",
+			prefix: netip.MustParsePrefix("ff02::/64"),
+		},
+		{
+			name:      "negative lifetime",
+			prefix:    netip.MustParsePrefix("2001:db8::/64"),
+			valid:     -1 * time.Second,
+			preferred: -2 * time.Second,
+		},
+		{
+			name:      "preferred exceeds valid",
+			prefix:    netip.MustParsePrefix("2001:db8::/64"),
+			valid:     10 * time.Second,
+			preferred: 20 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPrefixInformation(tt.prefix, true, true, tt.valid, tt.preferred)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("err: %v", err)
+		})
+	}
+}
+
+func TestNewMTUChecked(t *testing.T) {
+	for _, mtu := range []uint32{0, 1279} {
+		if _, err := NewMTUChecked(mtu); err == nil {
+			t.Fatalf("expected an error for MTU %d, but none occurred", mtu)
+		}
+	}
+
+	m, err := NewMTUChecked(1500)
+	if err != nil {
+		t.Fatalf("failed to create MTU: %v", err)
+	}
+	if diff := cmp.Diff(NewMTU(1500), m); diff != "" {
+		t.Fatalf("unexpected MTU (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		name string
+		mtu  uint32
+		ifi  net.Interface
+		ok   bool
+	}{
+		{
+			name: "too small",
+			mtu:  1279,
+			ifi:  net.Interface{MTU: 1500},
+		},
+		{
+			name: "exceeds interface",
+			mtu:  9000,
+			ifi:  net.Interface{Name: "eth0", MTU: 1500},
+		},
+		{
+			name: "OK equal",
+			mtu:  1500,
+			ifi:  net.Interface{MTU: 1500},
+			ok:   true,
+		},
+		{
+			name: "OK unknown interface MTU",
+			mtu:  9000,
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMTU(tt.mtu).CheckInterface(&tt.ifi)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to check MTU: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestPREF64Lifetime(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		want     time.Duration
+	}{
+		{
+			name: "default",
+			want: 30 * time.Minute,
+		},
+		{
+			name:     "less than one unit",
+			lifetime: time.Second,
+			want:     8 * time.Second,
+		},
+		{
+			name:     "round up",
+			lifetime: 1801 * time.Second,
+			want:     1808 * time.Second,
+		},
+		{
+			name:     "round up fraction",
+			lifetime: 1800*time.Second + time.Millisecond,
+			want:     1808 * time.Second,
+		},
+		{
+			name:     "exact",
+			lifetime: 9000 * time.Second,
+			want:     9000 * time.Second,
+		},
+		{
+			name:     "maximum",
+			lifetime: 24 * time.Hour,
+			want:     65528 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PREF64Lifetime(&RouterAdvertisement{RouterLifetime: tt.lifetime})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected lifetime (-want +got):\n%s", diff)
+			}
+
+			// The lifetime must survive a round trip unchanged.
+			p := &PREF64{Lifetime: got, Prefix: netip.MustParsePrefix("64:ff9b::/96")}
+			b, err := p.MarshalBinary()
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+
+			var p2 PREF64
+			if err := p2.UnmarshalBinary(b); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if diff := cmp.Diff(got, p2.Lifetime); diff != "" {
+				t.Fatalf("unexpected round trip lifetime (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOptionCodes(t *testing.T) {
+	tests := []struct {
+		code uint8
+		o    Option
+	}{
+		{OptionSourceLinkLayerAddress, &LinkLayerAddress{Direction: Source}},
+		{OptionTargetLinkLayerAddress, &LinkLayerAddress{Direction: Target}},
+		{OptionPrefixInformation, new(PrefixInformation)},
+		{OptionMTU, new(MTU)},
+		{OptionSourceAddressList, &AddressList{Direction: Source}},
+		{OptionTargetAddressList, &AddressList{Direction: Target}},
+		{OptionCGA, new(CGA)},
+		{OptionRSASignature, new(RSASignature)},
+		{OptionTimestamp, new(Timestamp)},
+		{OptionNonce, new(Nonce)},
+		{OptionPvD, new(PvD)},
+		{OptionRouteInformation, new(RouteInformation)},
+		{OptionRecursiveDNSServer, new(RecursiveDNSServer)},
+		{OptionRAFlagsExtension, new(RAFlagsExtension)},
+		{OptionDNSSearchList, new(DNSSearchList)},
+		{OptionAddressRegistration, new(AddressRegistration)},
+		{OptionCaptivePortal, new(CaptivePortal)},
+		{OptionPREF64, new(PREF64)},
+		{OptionEncryptedDNS, new(EncryptedDNS)},
+		{OptionExperiment1, &RawOption{Type: OptionExperiment1}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.o.Code(); got != tt.code {
+			t.Fatalf("%T: unexpected code %d, want %d", tt.o, got, tt.code)
+		}
+	}
+
+	// Every built-in option has a constant.
+	if got, want := len(builtinOptionTypes()), len(tests)-1; got != want {
+		t.Fatalf("unexpected number of built-in option types: %d, want %d", got, want)
+	}
+}
+
+func TestOptionString(t *testing.T) {
+	// All Options implemented by this package have a string representation.
+	for _, ot := range builtinOptionTypes() {
+		if _, ok := ot.New().(fmt.Stringer); !ok {
+			t.Fatalf("%s option does not implement fmt.Stringer", ot.Name)
+		}
+	}
+
+	tests := []struct {
+		o    Option
+		want string
+	}{
+		{
+			o:    &LinkLayerAddress{Direction: Target, Addr: ndptest.MAC},
+			want: "target link-layer address: " + ndptest.MAC.String(),
+		},
+		{
+			o:    NewMTU(1500),
+			want: "MTU: 1500",
+		},
+		{
+			o: &PrefixInformation{
+				PrefixLength:      64,
+				OnLink:            true,
+				ValidLifetime:     time.Hour,
+				PreferredLifetime: time.Minute,
+				Prefix:            netip.MustParseAddr("2001:db8::"),
+			},
+			want: "prefix information: 2001:db8::/64, flags: [on-link], valid: 1h0m0s, preferred: 1m0s",
+		},
+		{
+			o: &RouteInformation{
+				PrefixLength:  48,
+				Preference:    High,
+				RouteLifetime: time.Hour,
+				Prefix:        netip.MustParseAddr("2001:db8::"),
+			},
+			want: "route information: 2001:db8::/48, preference: High, lifetime: 1h0m0s",
+		},
+		{
+			o: &RecursiveDNSServer{
+				Lifetime: time.Hour,
+				Servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")},
+			},
+			want: "recursive DNS servers: lifetime: 1h0m0s, servers: 2001:db8::1, 2001:db8::2",
+		},
+		{
+			o:    &DNSSearchList{Lifetime: time.Hour, DomainNames: []string{"foo.example", "bar.example"}},
+			want: "DNS search list: lifetime: 1h0m0s, domain names: foo.example, bar.example",
+		},
+		{
+			o: &DNSSearchList{
+				Lifetime:  time.Hour,
+				RawLabels: true,
+				Labels:    [][][]byte{{[]byte("a b"), []byte("example")}},
+			},
+			want: `DNS search list: lifetime: 1h0m0s, domain names: "a b.example"`,
+		},
+		{
+			o:    &CaptivePortal{URI: Unrestricted},
+			want: "captive portal: " + Unrestricted,
+		},
+		{
+			o:    &PREF64{Lifetime: 8 * time.Second, Prefix: netip.MustParsePrefix("64:ff9b::/96")},
+			want: "pref64: 64:ff9b::/96, lifetime: 8s",
+		},
+		{
+			o:    &RAFlagsExtension{Flags: RAFlags{0x80, 0x00, 0x00, 0x00, 0x00, 0x01}},
+			want: "RA flags extension: [0x80 0x00 0x00 0x00 0x00 0x01]",
+		},
+		{
+			o:    &Timestamp{Time: time.Unix(1, 0)},
+			want: "timestamp: 1970-01-01T00:00:01Z",
+		},
+		{
+			o:    &AddressList{Direction: Source, Addresses: []netip.Addr{netip.MustParseAddr("2001:db8::1")}},
+			want: "source address list: 2001:db8::1",
+		},
+		{
+			o:    &PvD{FQDN: "pvd.example.com", Sequence: 1, Options: []Option{NewMTU(1500)}},
+			want: "PvD: pvd.example.com, sequence: 1, HTTP: false, legacy: false, RA header: false, options: [MTU: 1500]",
+		},
+		{
+			o:    &RawOption{Type: 253, Length: 1, Value: []byte{1, 2, 3, 4, 5, 6}},
+			want: "type: 253, value: [1 2 3 4 5 6]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, optionString(tt.o)); diff != "" {
+				t.Fatalf("unexpected string (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewRecursiveDNSServer(t *testing.T) {
+	servers := []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("2001:db8::2"),
+	}
+
+	r, err := NewRecursiveDNSServer(time.Hour, servers...)
+	if err != nil {
+		t.Fatalf("failed to create recursive DNS server: %v", err)
+	}
+
+	// The input slice must not be retained.
+	servers[0] = netip.MustParseAddr("2001:db8::ff")
+
+	want := &RecursiveDNSServer{
+		Lifetime: time.Hour,
+		Servers: []netip.Addr{
+			netip.MustParseAddr("2001:db8::1"),
+			netip.MustParseAddr("2001:db8::2"),
+		},
+	}
+	if diff := cmp.Diff(want, r, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected recursive DNS server (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		servers  []netip.Addr
+	}{
+		{
+			name:     "no servers",
+			lifetime: time.Hour,
+		},
+		{
+			name:     "IPv4",
+			lifetime: time.Hour,
+			servers:  []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+		},
+		{
+			name:     "IPv4-mapped IPv6",
+			lifetime: time.Hour,
+			servers:  []netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1")},
+		},
+		{
+			name:     "invalid",
+			lifetime: time.Hour,
+			servers:  []netip.Addr{{}},
+		},
+		{
+			name:     "negative lifetime",
+			lifetime: -1 * time.Second,
+			servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		},
+		{
+			name:     "lifetime too long",
+			lifetime: Infinity + time.Second,
+			servers:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRecursiveDNSServer(tt.lifetime, tt.servers...); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestRecursiveDNSServerCheckLifetime(t *testing.T) {
+	tests := []struct {
+		name        string
+		lifetime    time.Duration
+		maxInterval time.Duration
+		ok          bool
+	}{
+		{
+			name:        "interval too short",
+			lifetime:    time.Hour,
+			maxInterval: time.Second,
+		},
+		{
+			name:        "interval too long",
+			lifetime:    time.Hour,
+			maxInterval: time.Hour,
+		},
+		{
+			name:        "lifetime too short",
+			lifetime:    1799 * time.Second,
+			maxInterval: 600 * time.Second,
+		},
+		{
+			name:        "OK zero",
+			maxInterval: 600 * time.Second,
+			ok:          true,
+		},
+		{
+			name:        "OK minimum",
+			lifetime:    1800 * time.Second,
+			maxInterval: 600 * time.Second,
+			ok:          true,
+		},
+		{
+			name:        "OK infinity",
+			lifetime:    Infinity,
+			maxInterval: 600 * time.Second,
+			ok:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RecursiveDNSServer{Lifetime: tt.lifetime}
+			err := r.CheckLifetime(tt.maxInterval)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to check lifetime: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestDNSSearchListIDNA(t *testing.T) {
+	tests := []struct {
+		name       string
+		d          *DNSSearchList
+		wire, back string
+		ok         bool
+	}{
+		{
+			name: "default underscore",
+			d:    &DNSSearchList{},
+			wire: "_sip._tcp.example.com",
+			back: "_sip._tcp.example.com",
+			ok:   true,
+		},
+		{
+			name: "default unicode",
+			d:    &DNSSearchList{},
+			wire: "bücher.example",
+			back: "bücher.example",
+			ok:   true,
+		},
+		{
+			name: "lookup underscore",
+			d:    &DNSSearchList{Profile: idna.Lookup},
+			wire: "_sip._tcp.example.com",
+		},
+		{
+			name: "no IDNA Punycode",
+			d:    &DNSSearchList{NoIDNA: true},
+			wire: "xn--bcher-kva.example",
+			back: "xn--bcher-kva.example",
+			ok:   true,
+		},
+		{
+			name: "no IDNA unicode",
+			d:    &DNSSearchList{NoIDNA: true},
+			wire: "bücher.example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Lifetime = time.Hour
+			tt.d.DomainNames = []string{tt.wire}
+
+			b, err := tt.d.MarshalBinary()
+			if err != nil {
+				if tt.ok {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+
+				return
+			}
+			if !tt.ok {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			got := &DNSSearchList{
+				Profile: tt.d.Profile,
+				NoIDNA:  tt.d.NoIDNA,
+			}
+			if err := got.UnmarshalBinary(b); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+
+			if diff := cmp.Diff([]string{tt.back}, got.DomainNames); diff != "" {
+				t.Fatalf("unexpected domain names (-want +got):\n%s", diff)
+			}
+			if got.Profile != tt.d.Profile || got.NoIDNA != tt.d.NoIDNA {
+				t.Fatal("IDNA configuration was not preserved")
+			}
+		})
+	}
+}
+
+func TestDNSSearchListRawLabels(t *testing.T) {
+	// Labels which are not valid domain name labels, and therefore could not
+	// round trip through DomainNames.
+	b := []byte{
+		31, 3, 0x00, 0x00,
+		// Lifetime.
+		0x00, 0x00, 0x0e, 0x10,
+		// Domain names.
+		3, 'a', '.', 'b',
+		2, 0xff, 0xfe,
+		0,
+		5, 'X', 'N', '-', '-', ' ',
+		0,
+		// Padding.
+		0x00,
+	}
+
+	if err := new(DNSSearchList).UnmarshalBinary(b); err == nil {
+		t.Fatal("expected an error decoding domain names, but none occurred")
+	}
+
+	d := &DNSSearchList{RawLabels: true}
+	if err := d.UnmarshalBinary(b); err != nil {
+		t.Fatalf("failed to unmarshal raw labels: %v", err)
+	}
+
+	want := &DNSSearchList{
+		Lifetime:  time.Hour,
+		RawLabels: true,
+		Labels: [][][]byte{
+			{[]byte("a.b"), {0xff, 0xfe}},
+			{[]byte("XN-- ")},
+		},
+	}
+	if diff := cmp.Diff(want, d); diff != "" {
+		t.Fatalf("unexpected DNS search list (-want +got):\n%s", diff)
+	}
+
+	// The labels must not alias the input.
+	b[9] = 'z'
+	if diff := cmp.Diff(want, cloneOption(d)); diff != "" {
+		t.Fatalf("unexpected cloned DNS search list (-want +got):\n%s", diff)
+	}
+	b[9] = 'a'
+
+	got, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal raw labels: %v", err)
+	}
+	if diff := cmp.Diff(b, got); diff != "" {
+		t.Fatalf("unexpected DNS search list bytes (-want +got):\n%s", diff)
+	}
+
+	for _, labels := range [][][][]byte{nil, {nil}, {{{}}}, {{make([]byte, 256)}}} {
+		d := &DNSSearchList{RawLabels: true, Labels: labels}
+		if _, err := d.MarshalBinary(); err == nil {
+			t.Fatalf("expected an error marshaling labels %v, but none occurred", labels)
+		}
+	}
+}
+
+func TestRouteInformationOptions(t *testing.T) {
+	options, err := RouteInformationOptions([]Route{
+		{Prefix: netip.MustParsePrefix("::/0"), Preference: Low, Lifetime: time.Hour},
+		{Prefix: netip.MustParsePrefix("2001:db8::1/48"), Preference: High, Lifetime: Infinity},
+		{Prefix: netip.MustParsePrefix("2001:db8:ffff::/128")},
+	})
+	if err != nil {
+		t.Fatalf("failed to create options: %v", err)
+	}
+
+	b, err := marshalOptions(options)
+	if err != nil {
+		t.Fatalf("failed to marshal options: %v", err)
+	}
+
+	want := ndptest.Merge([][]byte{
+		// ::/0, no prefix bytes.
+		{24, 1, 0, 0x18},
+		{0x00, 0x00, 0x0e, 0x10},
+		// 2001:db8::/48, 8 prefix bytes.
+		{24, 2, 48, 0x08},
+		{0xff, 0xff, 0xff, 0xff},
+		{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00},
+		// 2001:db8:ffff::/128, 16 prefix bytes.
+		{24, 3, 128, 0x00},
+		{0x00, 0x00, 0x00, 0x00},
+		{0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0x00, 0x00},
+		ndptest.Zero(8),
+	})
+	if diff := cmp.Diff(want, b); diff != "" {
+		t.Fatalf("unexpected options bytes (-want +got):\n%s", diff)
+	}
+
+	for _, r := range []Route{
+		{},
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24")},
+		{Prefix: netip.MustParsePrefix("2001:db8::/64"), Preference: prfReserved},
+		{Prefix: netip.MustParsePrefix("2001:db8::/64"), Lifetime: -1},
+	} {
+		if _, err := RouteInformationOptions([]Route{r}); err == nil {
+			t.Fatalf("expected an error for route %+v, but none occurred", r)
+		}
+	}
+}
+
+func TestRouteInformationIPPrefix(t *testing.T) {
+	var ri RouteInformation
+	ri.SetIPPrefix(netip.MustParsePrefix("2001:db8::1/48"))
+
+	want := &RouteInformation{
+		PrefixLength: 48,
+		Prefix:       netip.MustParseAddr("2001:db8::"),
+	}
+	if diff := cmp.Diff(want, &ri, cmp.Comparer(addrEqual)); diff != "" {
+		t.Fatalf("unexpected route information (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(netip.MustParsePrefix("2001:db8::/48"), ri.IPPrefix(), cmp.Comparer(prefixEqual)); diff != "" {
+		t.Fatalf("unexpected prefix (-want +got):\n%s", diff)
+	}
+}
+
+func TestRAFlagsBit(t *testing.T) {
+	var f RAFlags
+	f.SetBit(9, false)
+	if f != nil {
+		t.Fatalf("clearing a bit extended flags: %v", f)
+	}
+
+	f.SetBit(9, true)
+	f.SetBit(55, true)
+	if diff := cmp.Diff(RAFlags{0x40, 0x00, 0x00, 0x00, 0x00, 0x01}, f); diff != "" {
+		t.Fatalf("unexpected flags (-want +got):\n%s", diff)
+	}
+
+	// The flags must be extended to a valid option length.
+	f.SetBit(56, true)
+	if len(f) != 14 {
+		t.Fatalf("unexpected flags length: %d", len(f))
+	}
+
+	for _, n := range []int{9, 55, 56} {
+		if !f.Bit(n) {
+			t.Fatalf("bit %d is not set", n)
+		}
+	}
+	for _, n := range []int{0, 8, 10, 57, 1000} {
+		if f.Bit(n) {
+			t.Fatalf("bit %d is set", n)
+		}
+	}
+
+	f.SetBit(9, false)
+	if f.Bit(9) {
+		t.Fatal("bit 9 was not cleared")
+	}
+
+	if _, err := marshalOptions([]Option{&RAFlagsExtension{Flags: f}}); err != nil {
+		t.Fatalf("failed to marshal RA flags extension: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected a panic setting bit 7, but none occurred")
+		}
+	}()
+	f.SetBit(7, true)
+}
+
+func TestOptionTypes(t *testing.T) {
+	ots := OptionTypes()
+	for i, ot := range ots {
+		if i > 0 && ots[i-1].Code >= ot.Code {
+			t.Fatalf("option types are not sorted: %d before %d", ots[i-1].Code, ot.Code)
+		}
+		if ot.Name == "" {
+			t.Fatalf("option type %d has no name", ot.Code)
+		}
+
+		o := ot.New()
+		if o == nil || o.Code() != ot.Code {
+			t.Fatalf("option type %d created an unexpected option: %#v", ot.Code, o)
+		}
+
+		// Each known code must be parsed as its typed Option rather than a
+		// RawOption.
+		b, err := marshalOptions([]Option{&RawOption{Type: ot.Code, Length: 1, Value: make([]byte, 6)}})
+		if err != nil {
+			t.Fatalf("failed to marshal option: %v", err)
+		}
+
+		if got, err := parseOptions(nil, nil, b); err == nil {
+			if _, ok := got[0].(*RawOption); ok {
+				t.Fatalf("option type %d was parsed as a raw option", ot.Code)
+			}
+		}
+	}
+}
+
+func TestNewNonceFrom(t *testing.T) {
+	// Identically seeded sources produce identical nonces.
+	n1, err := NewNonceFrom(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("failed to create nonce: %v", err)
+	}
+	n2, err := NewNonceFrom(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("failed to create nonce: %v", err)
+	}
+
+	if !n1.Equal(n2) {
+		t.Fatalf("nonces from identical sources are not equal: %s != %s", n1, n2)
+	}
+
+	if _, err := NewNonceFrom(strings.NewReader("short")); err == nil {
+		t.Fatal("expected an error for short source, but none occurred")
+	}
+}
+
+func TestNewNonceBytesSize(t *testing.T) {
+	for _, size := range []int{6, 14, 22} {
+		n, err := NewNonceSize(size)
+		if err != nil {
+			t.Fatalf("failed to create %d byte nonce: %v", size, err)
+		}
+
+		// A nonce persisted using Bytes is equal once restored.
+		b := n.Bytes()
+		if len(b) != size {
+			t.Fatalf("unexpected nonce length: %d", len(b))
+		}
+
+		restored, err := NewNonceBytes(b)
+		if err != nil {
+			t.Fatalf("failed to restore nonce: %v", err)
+		}
+		if !n.Equal(restored) {
+			t.Fatalf("restored nonce is not equal: %s != %s", n, restored)
+		}
+
+		// Neither the input nor the output of Bytes alias the nonce.
+		b[0]++
+		if !n.Equal(restored) || bytes.Equal(b, restored.Bytes()) {
+			t.Fatal("nonce aliases caller bytes")
+		}
+
+		if _, err := marshalOptions([]Option{n}); err != nil {
+			t.Fatalf("failed to marshal %d byte nonce: %v", size, err)
+		}
+	}
+
+	for _, size := range []int{0, 1, 5, 7, 8, 13, 15} {
+		if _, err := NewNonceSize(size); err == nil {
+			t.Fatalf("expected an error for %d byte nonce, but none occurred", size)
+		}
+		if _, err := NewNonceBytes(make([]byte, size)); err == nil {
+			t.Fatalf("expected an error for %d byte nonce, but none occurred", size)
+		}
+	}
+}
+
 func mustCaptivePortal(uri string) *CaptivePortal {
 	cp, err := NewCaptivePortal(uri)
 	if err != nil {
@@ -0,0 +1,99 @@
+package ndp
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// An OUIDatabase maps the Organizationally Unique Identifiers (OUIs) of
+// link-layer addresses to the names of the vendors they are assigned to.
+type OUIDatabase struct {
+	vendors map[[3]byte]string
+}
+
+// ParseOUIDatabase parses an OUIDatabase from r. Two common formats are
+// supported:
+//   - the IEEE registry, as published at
+//     https://standards-oui.ieee.org/oui/oui.txt, in which assignments are
+//     listed as "00-00-0C   (hex)		Cisco Systems, Inc"
+//   - the Wireshark manuf file, in which assignments are listed as
+//     "00:00:0C	Cisco	Cisco Systems, Inc"
+//
+// Lines which do not describe a 24-bit OUI assignment are ignored. An error
+// is returned if r contains no assignments at all.
+func ParseOUIDatabase(r io.Reader) (*OUIDatabase, error) {
+	db := &OUIDatabase{vendors: make(map[[3]byte]string)}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		oui, vendor, ok := parseOUILine(s.Text())
+		if !ok {
+			continue
+		}
+
+		db.vendors[oui] = vendor
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(db.vendors) == 0 {
+		return nil, errors.New("ndp: no OUI assignments found in database")
+	}
+
+	return db, nil
+}
+
+// VendorOf returns the name of the vendor which is assigned the OUI of hw, or
+// the empty string if the vendor is unknown. Locally administered addresses,
+// such as randomized addresses, are not assigned to a vendor.
+func (db *OUIDatabase) VendorOf(hw net.HardwareAddr) string {
+	if db == nil || len(hw) < 3 || hw[0]&0x02 != 0 {
+		return ""
+	}
+
+	// Ignore the multicast bit so group addresses map to the same vendor.
+	return db.vendors[[3]byte{hw[0] &^ 0x01, hw[1], hw[2]}]
+}
+
+// parseOUILine parses a single OUI assignment line from an IEEE registry or
+// Wireshark manuf file.
+func parseOUILine(line string) (oui [3]byte, vendor string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return oui, "", false
+	}
+
+	var prefix string
+	if i := strings.Index(line, "(hex)"); i != -1 {
+		// IEEE: the vendor follows the "(hex)" marker.
+		prefix = strings.TrimSpace(line[:i])
+		vendor = strings.TrimSpace(line[i+len("(hex)"):])
+	} else {
+		// Wireshark: tab-separated prefix, short name, and optional long name.
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return oui, "", false
+		}
+
+		prefix = fields[0]
+		vendor = strings.TrimSpace(fields[len(fields)-1])
+	}
+
+	// Only 24-bit OUIs are supported, so prefixes with a mask such as
+	// "00:1B:C5:00:00:00/36" are ignored.
+	prefix = strings.NewReplacer("-", "", ":", "").Replace(prefix)
+	if len(prefix) != 6 || vendor == "" {
+		return oui, "", false
+	}
+
+	if _, err := hex.Decode(oui[:], []byte(prefix)); err != nil {
+		return oui, "", false
+	}
+
+	return oui, vendor, true
+}
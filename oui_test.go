@@ -0,0 +1,75 @@
+package ndp_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/ndp"
+)
+
+func TestOUIDatabaseVendorOf(t *testing.T) {
+	const db = `
+# IEEE registry format.
+00-00-0C   (hex)		Cisco Systems, Inc
+00000C     (base 16)		Cisco Systems, Inc
+				170 WEST TASMAN DRIVE
+
+# Wireshark manuf format.
+00:50:56	VMware	VMware, Inc.
+00:1B:C5:00:00:00/36	Masked	Masked Vendor
+`
+
+	oui, err := ndp.ParseOUIDatabase(strings.NewReader(db))
+	if err != nil {
+		t.Fatalf("failed to parse OUI database: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hw     net.HardwareAddr
+		vendor string
+	}{
+		{
+			name:   "IEEE",
+			hw:     net.HardwareAddr{0x00, 0x00, 0x0c, 0x01, 0x02, 0x03},
+			vendor: "Cisco Systems, Inc",
+		},
+		{
+			name:   "Wireshark",
+			hw:     net.HardwareAddr{0x00, 0x50, 0x56, 0xef, 0xde, 0xad},
+			vendor: "VMware, Inc.",
+		},
+		{
+			name:   "multicast",
+			hw:     net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc},
+			vendor: "Cisco Systems, Inc",
+		},
+		{
+			name: "locally administered",
+			hw:   net.HardwareAddr{0x02, 0x00, 0x0c, 0x01, 0x02, 0x03},
+		},
+		{
+			name: "masked prefix",
+			hw:   net.HardwareAddr{0x00, 0x1b, 0xc5, 0x00, 0x00, 0x01},
+		},
+		{
+			name: "short",
+			hw:   net.HardwareAddr{0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oui.VendorOf(tt.hw); got != tt.vendor {
+				t.Fatalf("unexpected vendor: want %q, got %q", tt.vendor, got)
+			}
+		})
+	}
+}
+
+func TestParseOUIDatabaseEmpty(t *testing.T) {
+	if _, err := ndp.ParseOUIDatabase(strings.NewReader("# nothing here\n")); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
@@ -0,0 +1,312 @@
+package ndp
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// A PacketConn is the ICMPv6 packet connection used by a Conn for I/O. It is
+// implemented by *ipv6.PacketConn and by the in-memory connections returned
+// by Pipe.
+type PacketConn interface {
+	ReadFrom(b []byte) (int, *ipv6.ControlMessage, net.Addr, error)
+	WriteTo(b []byte, cm *ipv6.ControlMessage, dst net.Addr) (int, error)
+	Close() error
+
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+
+	JoinGroup(ifi *net.Interface, group net.Addr) error
+	LeaveGroup(ifi *net.Interface, group net.Addr) error
+	SetICMPFilter(f *ipv6.ICMPFilter) error
+	SetControlMessage(cf ipv6.ControlFlags, on bool) error
+}
+
+var _ PacketConn = &ipv6.PacketConn{}
+
+// pipeBuffer is the number of packets which may be queued in each direction
+// of a Pipe before writes block.
+const pipeBuffer = 64
+
+// Pipe creates a pair of connected, in-memory PacketConns which can be passed
+// to NewConn, so that code built on a Conn can be tested without privileges
+// or an IPv6-capable network interface.
+//
+// Every packet written to one end of the pipe is received by the other,
+// regardless of its destination address: the pipe behaves like a
+// point-to-point link. The source address of a received packet is taken from
+// the Src field of the control message it was written with, which a Conn sets
+// by default, or is the unspecified address if none was set. Received control
+// messages are populated according to the flags enabled by SetControlMessage.
+// Multicast group membership and ICMPv6 filters are accepted but have no
+// effect.
+//
+// The network interface passed to NewConn must have a nonzero MTU, which is
+// used to size the buffers of ReadFrom.
+func Pipe() (PacketConn, PacketConn) {
+	var (
+		c1 = make(chan pipePacket, pipeBuffer)
+		c2 = make(chan pipePacket, pipeBuffer)
+	)
+
+	p1 := newPipeConn(c1, c2)
+	p2 := newPipeConn(c2, c1)
+	p1.peer, p2.peer = p2, p1
+
+	return p1, p2
+}
+
+// A pipePacket is a packet sent over a Pipe.
+type pipePacket struct {
+	b   []byte
+	cm  ipv6.ControlMessage
+	dst net.IP
+}
+
+var _ PacketConn = &pipeConn{}
+
+// A pipeConn is one end of a Pipe.
+type pipeConn struct {
+	rx   <-chan pipePacket
+	tx   chan<- pipePacket
+	peer *pipeConn
+
+	closeOnce sync.Once
+	done      chan struct{}
+
+	readDeadline, writeDeadline *pipeDeadline
+
+	mu    sync.Mutex
+	flags ipv6.ControlFlags
+}
+
+func newPipeConn(rx <-chan pipePacket, tx chan<- pipePacket) *pipeConn {
+	return &pipeConn{
+		rx:            rx,
+		tx:            tx,
+		done:          make(chan struct{}),
+		readDeadline:  newPipeDeadline(),
+		writeDeadline: newPipeDeadline(),
+	}
+}
+
+// ReadFrom implements PacketConn.
+func (p *pipeConn) ReadFrom(b []byte) (int, *ipv6.ControlMessage, net.Addr, error) {
+	if err := p.check(p.readDeadline); err != nil {
+		return 0, nil, nil, err
+	}
+
+	var pkt pipePacket
+	select {
+	case pkt = <-p.rx:
+	case <-p.done:
+		return 0, nil, nil, net.ErrClosed
+	case <-p.readDeadline.wait():
+		return 0, nil, nil, os.ErrDeadlineExceeded
+	}
+
+	n := copy(b, pkt.b)
+
+	p.mu.Lock()
+	flags := p.flags
+	p.mu.Unlock()
+
+	var cm *ipv6.ControlMessage
+	if flags != 0 {
+		cm = &ipv6.ControlMessage{}
+		if flags&ipv6.FlagTrafficClass != 0 {
+			cm.TrafficClass = pkt.cm.TrafficClass
+		}
+		if flags&ipv6.FlagHopLimit != 0 {
+			cm.HopLimit = pkt.cm.HopLimit
+		}
+		if flags&ipv6.FlagSrc != 0 {
+			cm.Src = pkt.cm.Src
+		}
+		if flags&ipv6.FlagDst != 0 {
+			cm.Dst = pkt.dst
+		}
+		if flags&ipv6.FlagInterface != 0 {
+			cm.IfIndex = pkt.cm.IfIndex
+		}
+	}
+
+	src := pkt.cm.Src
+	if src == nil {
+		// No source address was specified, and there is no operating system
+		// to choose one.
+		src = net.IPv6unspecified
+	}
+
+	return n, cm, &net.IPAddr{IP: src}, nil
+}
+
+// WriteTo implements PacketConn.
+func (p *pipeConn) WriteTo(b []byte, cm *ipv6.ControlMessage, dst net.Addr) (int, error) {
+	if err := p.check(p.writeDeadline); err != nil {
+		return 0, err
+	}
+
+	pkt := pipePacket{b: append([]byte(nil), b...)}
+	if cm != nil {
+		pkt.cm = *cm
+	}
+	if ipa, ok := dst.(*net.IPAddr); ok {
+		pkt.dst = ipa.IP
+	}
+
+	select {
+	case p.tx <- pkt:
+	case <-p.peer.done:
+		// Like a datagram socket, silently drop packets which cannot be
+		// received.
+	case <-p.done:
+		return 0, net.ErrClosed
+	case <-p.writeDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	return len(b), nil
+}
+
+// check returns an error if p is closed or d has expired.
+func (p *pipeConn) check(d *pipeDeadline) error {
+	select {
+	case <-p.done:
+		return net.ErrClosed
+	case <-d.wait():
+		return os.ErrDeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+// Close implements PacketConn.
+func (p *pipeConn) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// SetDeadline implements PacketConn.
+func (p *pipeConn) SetDeadline(t time.Time) error {
+	if err := p.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return p.SetWriteDeadline(t)
+}
+
+// SetReadDeadline implements PacketConn.
+func (p *pipeConn) SetReadDeadline(t time.Time) error {
+	if err := p.check(nopDeadline); err != nil {
+		return err
+	}
+
+	p.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline implements PacketConn.
+func (p *pipeConn) SetWriteDeadline(t time.Time) error {
+	if err := p.check(nopDeadline); err != nil {
+		return err
+	}
+
+	p.writeDeadline.set(t)
+	return nil
+}
+
+// JoinGroup implements PacketConn.
+func (p *pipeConn) JoinGroup(_ *net.Interface, _ net.Addr) error { return p.check(nopDeadline) }
+
+// LeaveGroup implements PacketConn.
+func (p *pipeConn) LeaveGroup(_ *net.Interface, _ net.Addr) error { return p.check(nopDeadline) }
+
+// SetICMPFilter implements PacketConn.
+func (p *pipeConn) SetICMPFilter(_ *ipv6.ICMPFilter) error { return p.check(nopDeadline) }
+
+// SetControlMessage implements PacketConn.
+func (p *pipeConn) SetControlMessage(cf ipv6.ControlFlags, on bool) error {
+	if err := p.check(nopDeadline); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if on {
+		p.flags |= cf
+	} else {
+		p.flags &^= cf
+	}
+
+	return nil
+}
+
+// nopDeadline is a pipeDeadline which never expires.
+var nopDeadline = newPipeDeadline()
+
+// A pipeDeadline is a deadline for pipeConn operations. Its wait channel is
+// closed when the deadline expires.
+type pipeDeadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func newPipeDeadline() *pipeDeadline {
+	return &pipeDeadline{cancel: make(chan struct{})}
+}
+
+// set sets the deadline to t. A zero t clears the deadline.
+func (d *pipeDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// The timer already fired and closed cancel; wait for that to finish
+		// before replacing the channel.
+		<-d.cancel
+	}
+	d.timer = nil
+
+	// Replace an expired deadline's channel so it may be reused.
+	if isClosed(d.cancel) {
+		d.cancel = make(chan struct{})
+	}
+
+	if t.IsZero() {
+		return
+	}
+
+	dur := time.Until(t)
+	if dur <= 0 {
+		close(d.cancel)
+		return
+	}
+
+	cancel := d.cancel
+	d.timer = time.AfterFunc(dur, func() { close(cancel) })
+}
+
+// wait returns a channel which is closed when the deadline expires.
+func (d *pipeDeadline) wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+// isClosed reports whether c is closed.
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,219 @@
+package ndp
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// Host constants for router solicitation, as described in RFC 4861, Section 10.
+const (
+	rtrSolicitationInterval = 4 * time.Second
+	maxRtrSolicitations     = 3
+)
+
+var (
+	// The all-nodes and all-routers link-local multicast groups.
+	allNodes   = netip.MustParseAddr("ff02::1")
+	allRouters = netip.MustParseAddr("ff02::2")
+)
+
+// A RouterWaitConfig configures WaitForRouter. The zero value is valid and
+// uses default values.
+type RouterWaitConfig struct {
+	// Passive disables router solicitations, so that WaitForRouter never
+	// transmits and only waits for unsolicited router advertisements.
+	Passive bool
+}
+
+// WaitForRouter blocks until a valid RouterAdvertisement is received on c, and
+// returns the RouterAdvertisement and the address of the router which sent it.
+//
+// WaitForRouter joins the all-nodes multicast group for its duration and,
+// unless cfg is Passive, sends up to 3 router solicitations at 4 second
+// intervals, as described in RFC 4861, Section 6.3.7. If no router responds to
+// the solicitations, WaitForRouter continues to wait for unsolicited router
+// advertisements until ctx is canceled. If cfg is nil, default values are
+// used.
+//
+// Router advertisements which are not sent from a link-local address are
+// ignored. If c is configured to receive control messages with
+// ipv6.FlagHopLimit, router advertisements with a hop limit other than
+// HopLimit are also ignored.
+//
+// WaitForRouter sets the read deadline of c while it waits, and clears the
+// read deadline before returning; any deadline set by the caller is not
+// restored.
+func WaitForRouter(ctx context.Context, c *Conn, cfg *RouterWaitConfig) (*RouterAdvertisement, netip.Addr, error) {
+	if cfg == nil {
+		cfg = &RouterWaitConfig{}
+	}
+
+	// Router advertisements are multicast to the all-nodes group.
+	if err := c.JoinGroup(allNodes); err != nil {
+		return nil, netip.Addr{}, err
+	}
+	defer c.LeaveGroup(allNodes)
+
+	defer c.watchContext(ctx)()
+
+	rs := &RouterSolicitation{}
+	if !c.addr.IsUnspecified() && c.ifi.HardwareAddr != nil {
+		// Per the RFC, the source link-layer address option must not be
+		// included when the source address is unspecified.
+		rs.Options = append(rs.Options, &LinkLayerAddress{
+			Direction: Source,
+			Addr:      c.ifi.HardwareAddr,
+		})
+	}
+
+	for sent := 0; ; {
+		var deadline time.Time
+		if !cfg.Passive && sent < maxRtrSolicitations {
+			if err := c.WriteTo(rs, nil, allRouters); err != nil {
+				return nil, netip.Addr{}, err
+			}
+			sent++
+
+			deadline = time.Now().Add(rtrSolicitationInterval)
+		}
+
+		if err := c.SetReadDeadline(deadline); err != nil {
+			return nil, netip.Addr{}, err
+		}
+
+		// Check for cancelation only after setting the deadline, so a
+		// concurrent cancelation cannot be overwritten by the new deadline.
+		if err := ctx.Err(); err != nil {
+			return nil, netip.Addr{}, err
+		}
+
+		m, from, err := c.readMatch(func(m Message, cm *ipv6.ControlMessage, from netip.Addr) bool {
+			if _, ok := m.(*RouterAdvertisement); !ok {
+				return false
+			}
+
+			// Validate the router advertisement as described in RFC 4861,
+			// Section 6.1.2.
+			if !from.IsLinkLocalUnicast() {
+				return false
+			}
+			if cm != nil && cm.HopLimit != 0 && cm.HopLimit != HopLimit {
+				return false
+			}
+
+			return true
+		})
+		if err == nil {
+			return m.(*RouterAdvertisement), from, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, netip.Addr{}, err
+		}
+		if !isTimeout(err) {
+			return nil, netip.Addr{}, err
+		}
+
+		// Timed out waiting for a response, solicit again if possible.
+	}
+}
+
+// An RAConflict is a parameter which is advertised inconsistently by two
+// routers on the same link, as described in RFC 4861, Section 6.2.7.
+type RAConflict struct {
+	// Field is the name of the RouterAdvertisement field or Option field
+	// which is inconsistent, such as "CurrentHopLimit" or "MTU".
+	Field string
+
+	// Prefix is the prefix of a PrefixInformation option with inconsistent
+	// lifetimes, or the zero value for all other fields.
+	Prefix netip.Prefix
+
+	// X and Y are the values of Field advertised by each router.
+	X, Y any
+}
+
+// CheckRouterAdvertisements performs the consistency checks described in RFC
+// 4861, Section 6.2.7 between two RouterAdvertisements sent by different
+// routers on the same link, and returns any conflicts found. Parameters which
+// are unspecified by either RouterAdvertisement are not considered to be in
+// conflict.
+//
+// Prefix lifetimes are compared as advertised. If a router decrements its
+// lifetimes in real time, the caller must account for the times at which x
+// and y were sent.
+func CheckRouterAdvertisements(x, y *RouterAdvertisement) []RAConflict {
+	var cs []RAConflict
+	check := func(field string, prefix netip.Prefix, xv, yv any, unspecified bool) {
+		if !unspecified && xv != yv {
+			cs = append(cs, RAConflict{
+				Field:  field,
+				Prefix: prefix,
+				X:      xv,
+				Y:      yv,
+			})
+		}
+	}
+
+	check("CurrentHopLimit", netip.Prefix{}, x.CurrentHopLimit, y.CurrentHopLimit,
+		x.CurrentHopLimit == 0 || y.CurrentHopLimit == 0)
+	check("ManagedConfiguration", netip.Prefix{}, x.ManagedConfiguration, y.ManagedConfiguration, false)
+	check("OtherConfiguration", netip.Prefix{}, x.OtherConfiguration, y.OtherConfiguration, false)
+	check("ReachableTime", netip.Prefix{}, x.ReachableTime, y.ReachableTime,
+		x.ReachableTime == 0 || y.ReachableTime == 0)
+	check("RetransmitTimer", netip.Prefix{}, x.RetransmitTimer, y.RetransmitTimer,
+		x.RetransmitTimer == 0 || y.RetransmitTimer == 0)
+
+	if xm, ym := raMTU(x), raMTU(y); xm != nil && ym != nil {
+		check("MTU", netip.Prefix{}, xm.MTU, ym.MTU, false)
+	}
+
+	yp := raPrefixes(y)
+	for _, xpi := range raPrefixes(x) {
+		p := xpi.IPPrefix()
+		for _, ypi := range yp {
+			if ypi.IPPrefix() != p {
+				continue
+			}
+
+			check("ValidLifetime", p, xpi.ValidLifetime, ypi.ValidLifetime, false)
+			check("PreferredLifetime", p, xpi.PreferredLifetime, ypi.PreferredLifetime, false)
+			break
+		}
+	}
+
+	return cs
+}
+
+// WithdrawRouterAdvertisement returns a copy of ra which withdraws the
+// information previously advertised by ra, for use as the final router
+// advertisements sent by a router which is shutting down, as described in
+// RFC 4861, Section 6.2.5. The RouterLifetime is set to zero, and each option
+// which can be withdrawn is replaced as by Withdraw. Other options, such as
+// LinkLayerAddress and MTU, are retained.
+func WithdrawRouterAdvertisement(ra *RouterAdvertisement) *RouterAdvertisement {
+	c := ra.Clone().(*RouterAdvertisement)
+	c.RouterLifetime = 0
+
+	for i, o := range c.Options {
+		if w, ok := Withdraw(o); ok {
+			c.Options[i] = w
+		}
+	}
+
+	return c
+}
+
+// raMTU returns the first MTU option in ra, or nil if none is present.
+func raMTU(ra *RouterAdvertisement) *MTU {
+	m, _ := FirstOption[*MTU](ra)
+	return m
+}
+
+// raPrefixes returns the PrefixInformation options in ra.
+func raPrefixes(ra *RouterAdvertisement) []*PrefixInformation {
+	return Options[*PrefixInformation](ra)
+}
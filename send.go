@@ -0,0 +1,559 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"golang.org/x/net/ipv6"
+)
+
+// The CGA Message Type tag for SEND, as described in RFC 3971, Section 5.2.
+var cgaMessageTypeSEND = [16]byte{
+	0x08, 0x6f, 0xca, 0x5e, 0x10, 0xb2, 0x00, 0xc9,
+	0x9c, 0x8c, 0xe0, 0x01, 0x64, 0x27, 0x7c, 0x08,
+}
+
+// Lengths of the fixed fields of a CGA option, and of the CGA Parameters which
+// precede the public key.
+const (
+	cgaFixedLen  = 2 + 1 + 1
+	cgaParamsLen = 16 + 8 + 1
+)
+
+var _ Option = &CGA{}
+
+// A CGA is a CGA option, as described in RFC 3971, Section 5.1. It carries
+// the CGA Parameters used to generate and verify a Cryptographically
+// Generated Address, as described in RFC 3972, Section 3.
+type CGA struct {
+	Modifier [16]byte
+
+	// SubnetPrefix is the /64 subnet prefix of the address.
+	SubnetPrefix netip.Prefix
+
+	CollisionCount uint8
+
+	// PublicKey is the DER-encoded SubjectPublicKeyInfo of the owner of the
+	// address. Use ParsePublicKey to parse it.
+	PublicKey []byte
+
+	// Extensions contains any extension fields which follow the public key,
+	// in their binary form.
+	Extensions []byte
+}
+
+// Code implements Option.
+func (*CGA) Code() byte { return optCGA }
+
+// MarshalBinary implements Option.
+func (c *CGA) MarshalBinary() ([]byte, error) { return c.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (c *CGA) AppendBinary(b []byte) ([]byte, error) { return c.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (c *CGA) UnmarshalBinary(b []byte) error { return c.unmarshal(b) }
+
+// Equal reports whether c and x are the same CGA.
+func (c *CGA) Equal(x *CGA) bool {
+	if c == nil || x == nil {
+		return c == x
+	}
+
+	return c.Modifier == x.Modifier &&
+		c.SubnetPrefix == x.SubnetPrefix &&
+		c.CollisionCount == x.CollisionCount &&
+		bytes.Equal(c.PublicKey, x.PublicKey) &&
+		bytes.Equal(c.Extensions, x.Extensions)
+}
+
+// ParsePublicKey parses the public key of the owner of the address.
+func (c *CGA) ParsePublicKey() (crypto.PublicKey, error) {
+	return x509.ParsePKIXPublicKey(c.PublicKey)
+}
+
+// Verify verifies that addr was generated from the CGA Parameters, as
+// described in RFC 3972, Section 5. Verify does not verify that the sender
+// of a message owns the public key; that requires verifying an RSASignature
+// using the same key.
+func (c *CGA) Verify(addr netip.Addr) error {
+	if err := checkIPv6(addr); err != nil {
+		return err
+	}
+
+	if c.CollisionCount > 2 {
+		return fmt.Errorf("ndp: invalid CGA collision count: %d", c.CollisionCount)
+	}
+
+	p, err := addr.Prefix(64)
+	if err != nil {
+		return err
+	}
+	if p != c.SubnetPrefix {
+		return fmt.Errorf("ndp: CGA subnet prefix %s does not match address %s", c.SubnetPrefix, addr)
+	}
+
+	// Compare the interface identifier to Hash1, ignoring the security
+	// parameter and the "u" and "g" bits.
+	a := addr.As16()
+	sec := a[8] >> 5
+	a[8] &^= 0x03
+
+	want, err := c.Address(sec)
+	if err != nil {
+		return err
+	}
+	if want != netip.AddrFrom16(a) {
+		return errors.New("ndp: CGA parameters do not match address")
+	}
+
+	if !c.checkHash2(c.Modifier, sec) {
+		return fmt.Errorf("ndp: CGA parameters do not satisfy security parameter %d", sec)
+	}
+
+	return nil
+}
+
+// Address computes the address which is generated from the CGA Parameters
+// with security parameter sec, as described in RFC 3972, Section 4. Address
+// does not verify that the Modifier satisfies sec; use Verify for that
+// purpose.
+//
+// If the address is found to be a duplicate, the CollisionCount can be
+// incremented and Address called again, up to a CollisionCount of 2.
+func (c *CGA) Address(sec uint8) (netip.Addr, error) {
+	if sec > 7 {
+		return netip.Addr{}, fmt.Errorf("ndp: invalid CGA security parameter: %d", sec)
+	}
+
+	params, err := c.appendParams(nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	// The interface identifier is the leftmost 64 bits of Hash1, with the
+	// security parameter in the leftmost 3 bits and the "u" and "g" bits
+	// cleared.
+	var (
+		a     = c.SubnetPrefix.Masked().Addr().As16()
+		hash1 = sha1.Sum(params)
+	)
+
+	copy(a[8:], hash1[:8])
+	a[8] = a[8]&^0xe3 | sec<<5
+
+	return netip.AddrFrom16(a), nil
+}
+
+// Parameters returns the binary form of the CGA Parameters data structure, as
+// described in RFC 3972, Section 3.
+func (c *CGA) Parameters() ([]byte, error) { return c.appendParams(nil) }
+
+// checkHash2 reports whether Hash2, computed from modifier and the public key
+// and extensions of c with a zero subnet prefix and collision count, has its
+// leftmost 16*sec bits set to zero.
+func (c *CGA) checkHash2(modifier [16]byte, sec uint8) bool {
+	if sec == 0 {
+		return true
+	}
+
+	h := sha1.New()
+	_, _ = h.Write(modifier[:])
+	_, _ = h.Write(make([]byte, 9))
+	_, _ = h.Write(c.PublicKey)
+	_, _ = h.Write(c.Extensions)
+
+	var hash2 [sha1.Size]byte
+	for _, v := range h.Sum(hash2[:0])[:2*sec] {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GenerateCGA generates a Cryptographically Generated Address in the /64
+// prefix for the public key pub with security parameter sec, as described in
+// RFC 3972, Section 4. It returns a CGA option which carries the parameters
+// used to generate the address, and the address itself.
+//
+// A security parameter of sec requires on average 2^(16*sec) hash
+// computations to find a suitable modifier, so values greater than 1 are
+// impractical on most hardware. If ctx is canceled before a modifier is found,
+// GenerateCGA returns the context's error.
+func GenerateCGA(ctx context.Context, pub crypto.PublicKey, prefix netip.Prefix, sec uint8) (*CGA, netip.Addr, error) {
+	if sec > 7 {
+		return nil, netip.Addr{}, fmt.Errorf("ndp: invalid CGA security parameter: %d", sec)
+	}
+
+	pk, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+
+	c := &CGA{
+		SubnetPrefix: prefix.Masked(),
+		PublicKey:    pk,
+	}
+
+	// Validate the parameters before the potentially expensive search.
+	if _, err := c.appendParams(nil); err != nil {
+		return nil, netip.Addr{}, err
+	}
+
+	// Start the modifier search from a random value.
+	if _, err := io.ReadFull(rand.Reader, c.Modifier[:]); err != nil {
+		return nil, netip.Addr{}, fmt.Errorf("ndp: failed to generate CGA modifier: %v", err)
+	}
+
+	for i := 0; !c.checkHash2(c.Modifier, sec); i++ {
+		// Checking the context on every iteration would dominate the cost of
+		// the search.
+		if i%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, netip.Addr{}, err
+			}
+		}
+
+		// Increment the modifier as a 128-bit integer.
+		for j := len(c.Modifier) - 1; j >= 0; j-- {
+			c.Modifier[j]++
+			if c.Modifier[j] != 0 {
+				break
+			}
+		}
+	}
+
+	addr, err := c.Address(sec)
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+
+	return c, addr, nil
+}
+
+// appendParams appends the binary form of the CGA Parameters to b.
+func (c *CGA) appendParams(b []byte) ([]byte, error) {
+	if !c.SubnetPrefix.Addr().Is6() || c.SubnetPrefix.Bits() != 64 {
+		return nil, fmt.Errorf("ndp: invalid CGA subnet prefix: %s", c.SubnetPrefix)
+	}
+	if len(c.PublicKey) == 0 {
+		return nil, errors.New("ndp: CGA option requires a public key")
+	}
+
+	prefix := c.SubnetPrefix.Masked().Addr().As16()
+
+	b = append(b, c.Modifier[:]...)
+	b = append(b, prefix[:8]...)
+	b = append(b, c.CollisionCount)
+	b = append(b, c.PublicKey...)
+	return append(b, c.Extensions...), nil
+}
+
+// String returns the string representation of a CGA.
+func (c *CGA) String() string {
+	return fmt.Sprintf("CGA: subnet prefix: %s, collision count: %d, public key: %d bytes",
+		c.SubnetPrefix, c.CollisionCount, len(c.PublicKey))
+}
+
+func (c *CGA) appendBinary(b []byte) ([]byte, error) {
+	params, err := c.appendParams(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	n := cgaFixedLen + len(params)
+	pad := padLen(n)
+	l, err := optionLength(n + pad)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pad length and 1 reserved byte.
+	b = append(b, c.Code(), l, uint8(pad), 0x00)
+	b = append(b, params...)
+	return appendPadding(b, n), nil
+}
+
+func (c *CGA) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	if len(raw.Value) < 2 {
+		return errors.New("ndp: CGA option too short")
+	}
+
+	// Skip pad length and reserved byte, then remove the padding.
+	pad := int(raw.Value[0])
+	params := raw.Value[2:]
+	if pad > len(params) {
+		return errors.New("ndp: CGA option pad length is too large")
+	}
+	params = params[:len(params)-pad]
+
+	if len(params) < cgaParamsLen {
+		return errors.New("ndp: CGA parameters too short")
+	}
+
+	// The public key is a DER-encoded SEQUENCE, followed by any extensions.
+	pk := params[cgaParamsLen:]
+	n, err := derLength(pk)
+	if err != nil {
+		return err
+	}
+
+	var prefix [16]byte
+	copy(prefix[:8], params[16:24])
+
+	*c = CGA{
+		Modifier:       [16]byte(params[:16]),
+		SubnetPrefix:   netip.PrefixFrom(netip.AddrFrom16(prefix), 64),
+		CollisionCount: params[24],
+		PublicKey:      append(c.PublicKey[:0], pk[:n]...),
+		Extensions:     append(c.Extensions[:0], pk[n:]...),
+	}
+
+	// Keep Extensions nil when none are present, as when the CGA was created.
+	if len(c.Extensions) == 0 {
+		c.Extensions = nil
+	}
+
+	return nil
+}
+
+// derLength returns the total length of the DER-encoded SEQUENCE at the
+// beginning of b, including its identifier and length octets.
+func derLength(b []byte) (int, error) {
+	const sequence = 0x30
+	if len(b) < 2 || b[0] != sequence {
+		return 0, errors.New("ndp: CGA public key is not a DER SEQUENCE")
+	}
+
+	// Short form lengths are a single octet. Long form lengths specify the
+	// number of octets which follow; keys never need more than 4.
+	n, hdr := int(b[1]), 2
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 4 || len(b) < 2+octets {
+			return 0, errors.New("ndp: invalid CGA public key length")
+		}
+
+		n, hdr = 0, 2+octets
+		for _, v := range b[2:hdr] {
+			n = n<<8 | int(v)
+		}
+	}
+
+	if hdr+n > len(b) {
+		return 0, errors.New("ndp: CGA public key is truncated")
+	}
+
+	return hdr + n, nil
+}
+
+// Length of the fixed fields of an RSA Signature option: type, length,
+// reserved, and key hash.
+const rsaSigFixedLen = 2 + 2 + 16
+
+var _ Option = &RSASignature{}
+
+// An RSASignature is an RSA Signature option, as described in RFC 3971,
+// Section 5.2.
+//
+// The length of the signature is determined by the signer's key, so when an
+// RSASignature is parsed, Signature also contains any padding which follows
+// the signature. Verify removes the padding before verifying the signature.
+type RSASignature struct {
+	// KeyHash is the leftmost 128 bits of the SHA-1 hash of the signer's
+	// public key, as computed by RSAKeyHash.
+	KeyHash [16]byte
+
+	// Signature is the RSASSA-PKCS1-v1_5 signature of the data returned by
+	// RSASignatureCoverage.
+	Signature []byte
+}
+
+// Code implements Option.
+func (*RSASignature) Code() byte { return optRSASignature }
+
+// MarshalBinary implements Option.
+func (s *RSASignature) MarshalBinary() ([]byte, error) { return s.appendBinary(nil) }
+
+// AppendBinary implements OptionAppender.
+func (s *RSASignature) AppendBinary(b []byte) ([]byte, error) { return s.appendBinary(b) }
+
+// UnmarshalBinary implements Option.
+func (s *RSASignature) UnmarshalBinary(b []byte) error { return s.unmarshal(b) }
+
+// Equal reports whether s and x are the same RSASignature.
+func (s *RSASignature) Equal(x *RSASignature) bool {
+	if s == nil || x == nil {
+		return s == x
+	}
+
+	return s.KeyHash == x.KeyHash && bytes.Equal(s.Signature, x.Signature)
+}
+
+// Verify verifies the signature of the ICMPv6 message b, sent from src to dst,
+// using pub. b must be the binary form of the message which carried s,
+// beginning with its ICMPv6 header.
+func (s *RSASignature) Verify(pub *rsa.PublicKey, b []byte, src, dst netip.Addr) error {
+	kh, err := RSAKeyHash(pub)
+	if err != nil {
+		return err
+	}
+	if kh != s.KeyHash {
+		return errors.New("ndp: RSA signature key hash does not match public key")
+	}
+
+	if len(s.Signature) < pub.Size() {
+		return errors.New("ndp: RSA signature is too short for public key")
+	}
+
+	data, err := RSASignatureCoverage(b, src, dst)
+	if err != nil {
+		return err
+	}
+
+	h := sha1.Sum(data)
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA1, h[:], s.Signature[:pub.Size()])
+}
+
+// String returns the string representation of an RSASignature.
+func (s *RSASignature) String() string {
+	return fmt.Sprintf("RSA signature: key hash: %x, signature: %d bytes", s.KeyHash, len(s.Signature))
+}
+
+func (s *RSASignature) appendBinary(b []byte) ([]byte, error) {
+	if len(s.Signature) == 0 {
+		return nil, errors.New("ndp: RSA signature option requires a non-empty signature")
+	}
+
+	n := rsaSigFixedLen + len(s.Signature)
+	l, err := optionLength(n + padLen(n))
+	if err != nil {
+		return nil, err
+	}
+
+	// 2 reserved bytes.
+	b = append(b, s.Code(), l, 0x00, 0x00)
+	b = append(b, s.KeyHash[:]...)
+	b = append(b, s.Signature...)
+	return appendPadding(b, n), nil
+}
+
+func (s *RSASignature) unmarshal(b []byte) error {
+	raw, err := parseRawOption(b)
+	if err != nil {
+		return err
+	}
+
+	// Reserved and key hash, followed by at least some signature.
+	if len(raw.Value) <= rsaSigFixedLen-2 {
+		return errors.New("ndp: RSA signature option too short")
+	}
+
+	*s = RSASignature{
+		KeyHash:   [16]byte(raw.Value[2:18]),
+		Signature: append(s.Signature[:0], raw.Value[18:]...),
+	}
+
+	return nil
+}
+
+// RSAKeyHash computes the key hash of pub for use in an RSASignature, as
+// described in RFC 3971, Section 5.2: the leftmost 128 bits of the SHA-1 hash
+// of pub in its DER-encoded SubjectPublicKeyInfo form.
+func RSAKeyHash(pub *rsa.PublicKey) ([16]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return [16]byte{}, err
+	}
+
+	h := sha1.Sum(der)
+	return [16]byte(h[:16]), nil
+}
+
+// RSASignatureCoverage returns the data covered by the RSA Signature option of
+// the ICMPv6 message b, sent from src to dst, as described in RFC 3971,
+// Section 5.2. It is the data which must be signed by the sender and verified
+// by the receiver using RSASSA-PKCS1-v1_5 with SHA-1.
+//
+// b must be the binary form of a message, beginning with its ICMPv6 header,
+// which contains an RSA Signature option. The message header, including the
+// checksum as it appears in b, and all options which precede the RSA Signature
+// option are covered.
+func RSASignatureCoverage(b []byte, src, dst netip.Addr) ([]byte, error) {
+	if err := checkIPv6(src); err != nil {
+		return nil, err
+	}
+	if err := checkIPv6(dst); err != nil {
+		return nil, err
+	}
+
+	if len(b) < icmpLen {
+		return nil, errors.New("ndp: ICMPv6 message too short")
+	}
+
+	i, ok := optionsOffset(ipv6.ICMPType(b[0]))
+	if !ok {
+		return nil, fmt.Errorf("ndp: ICMPv6 type %d does not carry NDP options", b[0])
+	}
+	if len(b) < i {
+		return nil, fmt.Errorf("ndp: %s message too short", ipv6.ICMPType(b[0]))
+	}
+
+	// Find the offset of the RSA Signature option.
+	for ; ; i += int(b[i+1]) * 8 {
+		if len(b[i:]) < 2 || b[i+1] == 0 || int(b[i+1])*8 > len(b[i:]) {
+			return nil, errors.New("ndp: RSA signature option not found")
+		}
+
+		if b[i] == optRSASignature {
+			break
+		}
+	}
+
+	var (
+		s = src.As16()
+		d = dst.As16()
+	)
+
+	data := make([]byte, 0, 3*16+i)
+	data = append(data, cgaMessageTypeSEND[:]...)
+	data = append(data, s[:]...)
+	data = append(data, d[:]...)
+	return append(data, b[:i]...), nil
+}
+
+// optionsOffset returns the offset of the options of a message of type t,
+// including its ICMPv6 header, or false if t does not carry NDP options.
+func optionsOffset(t ipv6.ICMPType) (int, bool) {
+	switch t {
+	case ipv6.ICMPTypeNeighborAdvertisement:
+		return icmpLen + naLen, true
+	case ipv6.ICMPTypeNeighborSolicitation:
+		return icmpLen + nsLen, true
+	case ipv6.ICMPTypeRouterAdvertisement:
+		return icmpLen + raLen, true
+	case ipv6.ICMPTypeRouterSolicitation:
+		return icmpLen + rsLen, true
+	case ipv6.ICMPTypeInverseNeighborDiscoverySolicitation,
+		ipv6.ICMPTypeInverseNeighborDiscoveryAdvertisement:
+		return icmpLen + indLen, true
+	default:
+		return 0, false
+	}
+}
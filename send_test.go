@@ -0,0 +1,331 @@
+package ndp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/ndp"
+	"github.com/mdlayher/ndp/internal/ndptest"
+)
+
+func TestRSASignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	kh, err := ndp.RSAKeyHash(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to compute key hash: %v", err)
+	}
+
+	var (
+		src = netip.MustParseAddr("fe80::1")
+		dst = netip.MustParseAddr("ff02::1:ff00:2")
+
+		// The signature is computed over a message with a placeholder
+		// signature of the correct length, which is then filled in.
+		sig = &ndp.RSASignature{
+			KeyHash:   kh,
+			Signature: make([]byte, key.Size()),
+		}
+		ns = &ndp.NeighborSolicitation{
+			TargetAddress: ndptest.IP,
+			Options: []ndp.Option{
+				&ndp.LinkLayerAddress{Direction: ndp.Source, Addr: ndptest.MAC},
+				sig,
+			},
+		}
+	)
+
+	b, err := ndp.MarshalMessage(ns)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	data, err := ndp.RSASignatureCoverage(b, src, dst)
+	if err != nil {
+		t.Fatalf("failed to compute coverage: %v", err)
+	}
+
+	h := sha1.Sum(data)
+	sig.Signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, h[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	b, err = ndp.MarshalMessage(ns)
+	if err != nil {
+		t.Fatalf("failed to marshal signed message: %v", err)
+	}
+
+	m, err := ndp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	got := m.(*ndp.NeighborSolicitation).Options[1].(*ndp.RSASignature)
+	if err := got.Verify(&key.PublicKey, b, src, dst); err != nil {
+		t.Fatalf("failed to verify signature: %v", err)
+	}
+
+	// Any change to the covered data invalidates the signature.
+	if err := got.Verify(&key.PublicKey, b, src, netip.MustParseAddr("ff02::1")); err == nil {
+		t.Fatal("expected an error for wrong destination, but none occurred")
+	}
+
+	// Modify the target address.
+	b[8] ^= 0xff
+	if err := got.Verify(&key.PublicKey, b, src, dst); err == nil {
+		t.Fatal("expected an error for modified message, but none occurred")
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := got.Verify(&other.PublicKey, b, src, dst); err == nil {
+		t.Fatal("expected an error for wrong key, but none occurred")
+	}
+}
+
+func TestRSASignatureCoverageError(t *testing.T) {
+	var (
+		src = netip.MustParseAddr("fe80::1")
+		dst = netip.MustParseAddr("fe80::2")
+	)
+
+	tests := []struct {
+		name string
+		m    ndp.Message
+		b    []byte
+	}{
+		{
+			name: "no options",
+			m:    &ndp.NodeInformationQuery{},
+		},
+		{
+			name: "no signature",
+			m:    &ndp.RouterSolicitation{},
+		},
+		{
+			name: "truncated neighbor advertisement",
+			b:    []byte{136, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.b
+			if b == nil {
+				var err error
+				b, err = ndp.MarshalMessage(tt.m)
+				if err != nil {
+					t.Fatalf("failed to marshal message: %v", err)
+				}
+			}
+
+			if _, err := ndp.RSASignatureCoverage(b, src, dst); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestCGA(t *testing.T) {
+	// Use a deterministic key and modifier so the test is reproducible.
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pk, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+
+	cga, addr := testCGA(t, netip.MustParsePrefix("2001:db8::/64"), pk, 1)
+	if err := cga.Verify(addr); err != nil {
+		t.Fatalf("failed to verify CGA: %v", err)
+	}
+
+	b, err := ndp.MarshalMessage(&ndp.RouterSolicitation{Options: []ndp.Option{cga}})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	m, err := ndp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	got := m.(*ndp.RouterSolicitation).Options[0].(*ndp.CGA)
+	if diff := cmp.Diff(cga, got, cmp.Comparer(prefixEqual)); diff != "" {
+		t.Fatalf("unexpected CGA (-want +got):\n%s", diff)
+	}
+
+	key, err := got.ParsePublicKey()
+	if err != nil {
+		t.Fatalf("failed to parse public key: %v", err)
+	}
+	if !priv.Public().(ed25519.PublicKey).Equal(key) {
+		t.Fatal("unexpected public key")
+	}
+
+	tests := []struct {
+		name string
+		addr netip.Addr
+		fn   func(c *ndp.CGA)
+	}{
+		{
+			name: "collision count",
+			addr: addr,
+			fn:   func(c *ndp.CGA) { c.CollisionCount = 1 },
+		},
+		{
+			name: "invalid collision count",
+			addr: addr,
+			fn:   func(c *ndp.CGA) { c.CollisionCount = 3 },
+		},
+		{
+			name: "subnet prefix",
+			addr: addr,
+			fn:   func(c *ndp.CGA) { c.SubnetPrefix = netip.MustParsePrefix("2001:db8:1::/64") },
+		},
+		{
+			name: "security parameter",
+			addr: func() netip.Addr {
+				// Claim a security parameter of 7, which the modifier
+				// cannot satisfy.
+				a := addr.As16()
+				a[8] |= 0xe0
+				return netip.AddrFrom16(a)
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *cga
+			if tt.fn != nil {
+				tt.fn(&c)
+			}
+
+			if err := c.Verify(tt.addr); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestGenerateCGA(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	prefix := netip.MustParsePrefix("2001:db8::/64")
+
+	for _, sec := range []uint8{0, 1} {
+		c, addr, err := ndp.GenerateCGA(context.Background(), priv.Public(), prefix, sec)
+		if err != nil {
+			t.Fatalf("failed to generate CGA: %v", err)
+		}
+		if err := c.Verify(addr); err != nil {
+			t.Fatalf("failed to verify CGA with sec %d: %v", sec, err)
+		}
+
+		// The "u" and "g" bits are ignored.
+		ug := addr.As16()
+		ug[8] |= 0x03
+		if err := c.Verify(netip.AddrFrom16(ug)); err != nil {
+			t.Fatalf("failed to verify CGA with u and g bits: %v", err)
+		}
+
+		// Address must agree with an independent implementation.
+		want, wantAddr := testCGA(t, prefix, c.PublicKey, sec)
+		got, err := want.Address(sec)
+		if err != nil {
+			t.Fatalf("failed to compute address: %v", err)
+		}
+		if diff := cmp.Diff(wantAddr, got, cmp.Comparer(addrEqual)); diff != "" {
+			t.Fatalf("unexpected address (-want +got):\n%s", diff)
+		}
+
+		// After a collision, a new address is computed.
+		c.CollisionCount++
+		next, err := c.Address(sec)
+		if err != nil {
+			t.Fatalf("failed to compute address: %v", err)
+		}
+		if next == addr {
+			t.Fatal("collision count did not change the address")
+		}
+		if err := c.Verify(next); err != nil {
+			t.Fatalf("failed to verify CGA after collision: %v", err)
+		}
+	}
+
+	params, err := (&ndp.CGA{SubnetPrefix: prefix, PublicKey: []byte{0xff}}).Parameters()
+	if err != nil {
+		t.Fatalf("failed to encode parameters: %v", err)
+	}
+	want := append(make([]byte, 16), 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0xff)
+	if diff := cmp.Diff(want, params); diff != "" {
+		t.Fatalf("unexpected parameters (-want +got):\n%s", diff)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := ndp.GenerateCGA(ctx, priv.Public(), prefix, 7); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, but got: %v", err)
+	}
+	if _, _, err := ndp.GenerateCGA(context.Background(), priv.Public(), prefix, 8); err == nil {
+		t.Fatal("expected an error for invalid security parameter, but none occurred")
+	}
+
+	// An invalid prefix must be rejected before searching for a modifier,
+	// which is infeasible with a high security parameter.
+	if _, _, err := ndp.GenerateCGA(context.Background(), priv.Public(), netip.MustParsePrefix("2001:db8::/48"), 7); err == nil {
+		t.Fatal("expected an error for invalid prefix, but none occurred")
+	}
+}
+
+// testCGA generates a CGA with security parameter sec for prefix and
+// public key pk, as described in RFC 3972, Section 4.
+func testCGA(t *testing.T, prefix netip.Prefix, pk []byte, sec uint8) (*ndp.CGA, netip.Addr) {
+	t.Helper()
+
+	var modifier [16]byte
+	for {
+		b := append(modifier[:], make([]byte, 9)...)
+		h := sha1.Sum(append(b, pk...))
+		if sec == 0 || bytes.Equal(h[:2*sec], make([]byte, 2*sec)) {
+			break
+		}
+
+		// Increment the modifier as a 128-bit integer.
+		for i := len(modifier) - 1; i >= 0; i-- {
+			modifier[i]++
+			if modifier[i] != 0 {
+				break
+			}
+		}
+	}
+
+	p := prefix.Addr().As16()
+	params := append(modifier[:], p[:8]...)
+	params = append(params, 0)
+	h := sha1.Sum(append(params, pk...))
+
+	copy(p[8:], h[:8])
+	p[8] = p[8]&0x1c | sec<<5
+
+	return &ndp.CGA{
+		Modifier:     modifier,
+		SubnetPrefix: prefix,
+		PublicKey:    pk,
+	}, netip.AddrFrom16(p)
+}
@@ -0,0 +1,172 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// pcapng block types and constants, as described in
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html.
+const (
+	pcapngSHB            = 0x0a0d0d0a
+	pcapngIDB            = 0x00000001
+	pcapngEPB            = 0x00000006
+	pcapngByteOrderMagic = 0x1a2b3c4d
+	pcapngOptEndOfOpt    = 0
+	pcapngOptIfName      = 2
+	pcapngLinkTypeIPv6   = 229
+
+	// Lengths of the fixed portions of each block, including the block type
+	// and both block lengths.
+	pcapngSHBLen = 28
+	pcapngIDBLen = 20
+	pcapngEPBLen = 32
+)
+
+// Tap mirrors every ICMPv6 packet sent and received by the Conn to w in the
+// pcapng format, so that NDP traffic may be captured without interrupting
+// normal operation. Each packet is written with an IPv6 header and the time
+// at which it was sent or received. Calling Tap again replaces any existing
+// tap, and a nil w stops mirroring.
+//
+// Packets are written to w synchronously with sending and receiving, so w
+// should not block. If an error occurs while writing a packet to w, mirroring
+// stops. The destination address of received packets is only known if c is
+// configured to receive control messages with ipv6.FlagDst; otherwise, the
+// address of the Conn is used.
+func (c *Conn) Tap(w io.Writer) error {
+	if w == nil {
+		c.tap.Store(nil)
+		return nil
+	}
+
+	t := &tap{w: w}
+	if err := t.writeHeader(c.ifi.Name); err != nil {
+		return err
+	}
+
+	c.tap.Store(t)
+	return nil
+}
+
+// mirror writes an ICMPv6 packet to the Conn's tap, if one is present.
+func (c *Conn) mirror(b []byte, cm *ipv6.ControlMessage, src, dst netip.Addr) {
+	t := c.tap.Load()
+	if t == nil {
+		return
+	}
+
+	hopLimit := HopLimit
+	if cm != nil && cm.HopLimit != 0 {
+		hopLimit = cm.HopLimit
+	}
+
+	if err := t.writePacket(time.Now(), b, hopLimit, src, dst); err != nil {
+		// Stop mirroring, unless the tap was already replaced.
+		c.tap.CompareAndSwap(t, nil)
+	}
+}
+
+// A tap writes packets to an io.Writer in the pcapng format.
+type tap struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// writeHeader writes the pcapng section header and a description of the
+// interface named ifName.
+func (t *tap) writeHeader(ifName string) error {
+	le := binary.LittleEndian
+
+	b := le.AppendUint32(nil, pcapngSHB)
+	b = le.AppendUint32(b, pcapngSHBLen)
+	b = le.AppendUint32(b, pcapngByteOrderMagic)
+	// Version 1.0, and unspecified section length.
+	b = le.AppendUint16(b, 1)
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint64(b, ^uint64(0))
+	b = le.AppendUint32(b, pcapngSHBLen)
+
+	// Interface description with no snapshot length limit and an interface
+	// name option padded to 32 bits, followed by the end of options.
+	l := uint32(pcapngIDBLen + 4 + pad4(len(ifName)) + 4)
+
+	b = le.AppendUint32(b, pcapngIDB)
+	b = le.AppendUint32(b, l)
+	b = le.AppendUint16(b, pcapngLinkTypeIPv6)
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint32(b, 0)
+	b = le.AppendUint16(b, pcapngOptIfName)
+	b = le.AppendUint16(b, uint16(len(ifName)))
+	b = append(b, ifName...)
+	b = append(b, make([]byte, pad4(len(ifName))-len(ifName))...)
+	b = le.AppendUint16(b, pcapngOptEndOfOpt)
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint32(b, l)
+
+	_, err := t.w.Write(b)
+	return err
+}
+
+// writePacket writes the ICMPv6 message b, sent at time ts from src to dst,
+// as an IPv6 packet.
+func (t *tap) writePacket(ts time.Time, b []byte, hopLimit int, src, dst netip.Addr) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The operating system calculates the checksum of sent messages, so
+	// calculate it for the copy as well if it is not yet present.
+	if len(b) >= icmpLen && b[2] == 0 && b[3] == 0 {
+		im := icmp.Message{
+			Type: ipv6.ICMPType(b[0]),
+			Code: int(b[1]),
+			Body: &icmp.RawBody{Data: b[icmpLen:]},
+		}
+
+		if cb, err := im.Marshal(icmp.IPv6PseudoHeader(src.AsSlice(), dst.AsSlice())); err == nil {
+			b = cb
+		}
+	}
+
+	var (
+		le = binary.LittleEndian
+		n  = ipv6Len + len(b)
+		l  = uint32(pcapngEPBLen + pad4(n))
+		us = uint64(ts.UnixMicro())
+	)
+
+	p := le.AppendUint32(t.buf[:0], pcapngEPB)
+	p = le.AppendUint32(p, l)
+	p = le.AppendUint32(p, 0)
+	p = le.AppendUint32(p, uint32(us>>32))
+	p = le.AppendUint32(p, uint32(us))
+	p = le.AppendUint32(p, uint32(n))
+	p = le.AppendUint32(p, uint32(n))
+
+	// IPv6 header: version 6, payload length, next header ICMPv6, hop limit,
+	// and addresses.
+	const icmpv6 = 58
+	p = append(p, 0x60, 0x00, 0x00, 0x00)
+	p = binary.BigEndian.AppendUint16(p, uint16(len(b)))
+	p = append(p, icmpv6, uint8(hopLimit))
+	p = append(p, src.AsSlice()...)
+	p = append(p, dst.AsSlice()...)
+	p = append(p, b...)
+	p = append(p, make([]byte, pad4(n)-n)...)
+
+	p = le.AppendUint32(p, l)
+	t.buf = p
+
+	_, err := t.w.Write(p)
+	return err
+}
+
+// pad4 rounds n up to a multiple of 4 bytes.
+func pad4(n int) int { return (n + 3) &^ 3 }
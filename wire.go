@@ -0,0 +1,160 @@
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Lengths in bytes of the binary forms of Messages which carry no Options or
+// other variable length data, including their 4 byte ICMPv6 headers, as
+// defined by their RFCs.
+const (
+	NeighborAdvertisementLen    = 24
+	NeighborSolicitationLen     = 24
+	RouterAdvertisementLen      = 16
+	RouterSolicitationLen       = 8
+	InverseNeighborDiscoveryLen = 8
+	NodeInformationLen          = 16
+	RouterRenumberingLen        = 16
+	ICMPErrorLen                = 8
+)
+
+// Lengths in bytes of the binary forms of the prefix control operations, use
+// prefixes, and match results which follow the header of a
+// RouterRenumbering message, as defined by RFC 2894.
+const (
+	PrefixControlOperationLen = 24
+	UsePrefixLen              = 32
+	MatchResultLen            = 24
+)
+
+// Lengths in bytes of the binary forms of Options, including their type and
+// length fields, as defined by their RFCs. Variable length Options have the
+// minimum length of their fixed fields: for example, a RecursiveDNSServer is
+// RecursiveDNSServerLen bytes followed by 16 bytes for each server.
+const (
+	LinkLayerAddressLen    = 8
+	MTULen                 = 8
+	PrefixInformationLen   = 32
+	RouteInformationLen    = 8
+	RecursiveDNSServerLen  = 8
+	AddressListLen         = 8
+	NonceLen               = 8
+	TimestampLen           = 16
+	AddressRegistrationLen = 16
+	PREF64Len              = 16
+)
+
+// CheckWireLengths verifies that the binary forms of the Messages and Options
+// in this package have the lengths defined by their RFCs, as described by the
+// length constants in this package. It returns an error describing the first
+// mismatch, which indicates a regression in the wire format.
+//
+// CheckWireLengths is intended to be called from tests, by this package and
+// by packages which depend on a stable wire format.
+func CheckWireLengths() error {
+	var (
+		target = netip.MustParseAddr("fe80::1")
+		prefix = netip.MustParsePrefix("2001:db8::/64")
+		mac    = net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x53, 0x01}
+	)
+
+	msg := func(m Message) func() ([]byte, error) {
+		return func() ([]byte, error) { return MarshalMessage(m) }
+	}
+	opt := func(o Option) func() ([]byte, error) {
+		return func() ([]byte, error) { return marshalOptions([]Option{o}) }
+	}
+
+	// Router renumbering prefix control operations and match results are
+	// measured by their contribution to the message length.
+	rr := func(rr *RouterRenumbering, n int) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			b, err := MarshalMessage(rr)
+			if err != nil {
+				return nil, err
+			}
+
+			return b[n:], nil
+		}
+	}
+
+	checks := []struct {
+		name    string
+		want    int
+		marshal func() ([]byte, error)
+	}{
+		{"NeighborAdvertisement", NeighborAdvertisementLen, msg(&NeighborAdvertisement{TargetAddress: target})},
+		{"NeighborSolicitation", NeighborSolicitationLen, msg(&NeighborSolicitation{TargetAddress: target})},
+		{"RouterAdvertisement", RouterAdvertisementLen, msg(&RouterAdvertisement{})},
+		{"RouterSolicitation", RouterSolicitationLen, msg(&RouterSolicitation{})},
+		{"InverseNeighborSolicitation", InverseNeighborDiscoveryLen, msg(&InverseNeighborSolicitation{})},
+		{"InverseNeighborAdvertisement", InverseNeighborDiscoveryLen, msg(&InverseNeighborAdvertisement{})},
+		{"NodeInformationQuery", NodeInformationLen, msg(&NodeInformationQuery{})},
+		{"NodeInformationReply", NodeInformationLen, msg(&NodeInformationReply{})},
+		{"RouterRenumbering", RouterRenumberingLen, msg(&RouterRenumbering{Code: RRSequenceNumberReset})},
+		{"ICMPError", ICMPErrorLen, msg(&ICMPError{ErrorType: 1})},
+		{
+			"PrefixControlOperation",
+			PrefixControlOperationLen,
+			rr(&RouterRenumbering{
+				Code:       RRCommand,
+				Operations: []PrefixControlOperation{{OpCode: RRAdd, MatchPrefix: prefix, MaxLength: 64}},
+			}, RouterRenumberingLen),
+		},
+		{
+			"UsePrefix",
+			UsePrefixLen,
+			rr(&RouterRenumbering{
+				Code: RRCommand,
+				Operations: []PrefixControlOperation{{
+					OpCode:      RRAdd,
+					MatchPrefix: prefix,
+					MaxLength:   64,
+					UsePrefixes: []UsePrefix{{Prefix: prefix}},
+				}},
+			}, RouterRenumberingLen+PrefixControlOperationLen),
+		},
+		{
+			"MatchResult",
+			MatchResultLen,
+			rr(&RouterRenumbering{
+				Code:    RRResult,
+				Results: []MatchResult{{MatchedPrefix: prefix}},
+			}, RouterRenumberingLen),
+		},
+		{"LinkLayerAddress", LinkLayerAddressLen, opt(&LinkLayerAddress{Direction: Source, Addr: mac})},
+		{"MTU", MTULen, opt(NewMTU(1500))},
+		{
+			"PrefixInformation",
+			PrefixInformationLen,
+			opt(&PrefixInformation{PrefixLength: 64, Prefix: prefix.Addr()}),
+		},
+		{"PREF64", PREF64Len, opt(&PREF64{Prefix: netip.MustParsePrefix("64:ff9b::/96")})},
+		{"Timestamp", TimestampLen, opt(&Timestamp{Time: time.Unix(0, 0)})},
+		{"AddressRegistration", AddressRegistrationLen, opt(&AddressRegistration{ROVR: make([]byte, 8)})},
+		{"RouteInformation", RouteInformationLen, opt(&RouteInformation{})},
+		{
+			"RecursiveDNSServer",
+			RecursiveDNSServerLen + 16,
+			opt(&RecursiveDNSServer{Servers: []netip.Addr{target}}),
+		},
+		{"Nonce", NonceLen, opt(&Nonce{b: make([]byte, 6)})},
+		{"AddressList", AddressListLen + 16, opt(&AddressList{Direction: Source, Addresses: []netip.Addr{target}})},
+	}
+
+	for _, c := range checks {
+		b, err := c.marshal()
+		if err != nil {
+			return fmt.Errorf("ndp: failed to marshal %s: %v", c.name, err)
+		}
+
+		if len(b) != c.want {
+			return fmt.Errorf("ndp: %s has length %d, but must have length %d", c.name, len(b), c.want)
+		}
+	}
+
+	return nil
+}